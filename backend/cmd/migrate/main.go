@@ -1,41 +1,153 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/pressly/goose/v3"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/database"
 )
 
+const usage = `Usage: go run cmd/migrate/main.go [-migrations-dir migrations] COMMAND [ARGS]
+
+Commands:
+  up              Apply all pending migrations
+  down [N]        Roll back the last N migrations (default 1)
+  status          Show applied and pending migrations
+  redo            Roll back the last migration and apply it again
+  goto VERSION    Migrate up or down to VERSION (0 rolls back everything)
+  version         Print the current schema version
+  create NAME     Create a new SQL migration file`
+
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: go run cmd/migrate/main.go [up|down]")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+
+	cfg, err := config.Parse(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ Failed to load configuration: %v", err)
+	}
+
+	args := flag.Args()
+	if len(args) < 1 {
+		log.Fatal(usage)
+	}
+
+	command := args[0]
+
+	// create only touches the filesystem, so it must work without a database
+	if command == "create" {
+		if len(args) < 2 {
+			log.Fatal("Usage: go run cmd/migrate/main.go create NAME")
+		}
+		paths, err := database.CreateMigration(cfg.MigrationsDir, args[1])
+		if err != nil {
+			log.Fatalf("❌ Failed to create migration: %v", err)
+		}
+		for _, path := range paths {
+			fmt.Printf("📝 Created %s\n", path)
+		}
+		return
+	}
+
+	db, dialect, err := database.Open(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		log.Fatalf("❌ Failed to connect to database: %v", err)
+	}
+
+	migrator, err := database.NewMigrator(db, dialect, cfg.MigrationsDir)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize migrations: %v", err)
 	}
 
-	cfg := config.Load()
-	direction := os.Args[1]
+	ctx := context.Background()
 
-	switch direction {
+	switch command {
 	case "up":
-		runMigrationsUp(cfg)
+		fmt.Printf("🔄 Running migrations UP against %s\n", dialect)
+		report(migrator.Up(ctx))
+		fmt.Println("✅ Migrations completed successfully")
 	case "down":
-		runMigrationsDown(cfg)
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("❌ Invalid number of migrations: %s", args[1])
+			}
+		}
+		fmt.Printf("🔄 Rolling back %d migration(s) against %s\n", n, dialect)
+		report(migrator.Down(ctx, n))
+		fmt.Println("✅ Migrations rollback completed successfully")
+	case "redo":
+		fmt.Printf("🔄 Redoing last migration against %s\n", dialect)
+		report(migrator.Redo(ctx))
+		fmt.Println("✅ Migration redo completed successfully")
+	case "goto":
+		if len(args) < 2 {
+			log.Fatal("Usage: go run cmd/migrate/main.go goto VERSION")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			log.Fatalf("❌ Invalid version: %s", args[1])
+		}
+		fmt.Printf("🔄 Migrating to version %d against %s\n", version, dialect)
+		report(migrator.Goto(ctx, version))
+		fmt.Printf("✅ Database is at version %d\n", version)
+	case "status":
+		printStatus(ctx, migrator)
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			log.Fatalf("❌ Failed to get version: %v", err)
+		}
+		fmt.Printf("📌 Current version: %d\n", version)
 	default:
-		log.Fatal("Invalid direction. Use 'up' or 'down'")
+		log.Fatalf("Invalid command %q\n\n%s", command, usage)
 	}
 }
 
-func runMigrationsUp(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations UP against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations completed successfully")
+// report prints the result of every migration that ran and exits on error
+func report(results []*goose.MigrationResult, err error) {
+	for _, res := range results {
+		fmt.Println("   ", res)
+	}
+	if err != nil {
+		log.Fatalf("❌ Migration failed: %v", err)
+	}
 }
 
-func runMigrationsDown(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations DOWN against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations rollback completed successfully")
+func printStatus(ctx context.Context, migrator *database.Migrator) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		log.Fatalf("❌ Failed to get migration status: %v", err)
+	}
+
+	fmt.Println("📊 Migration status:")
+	fmt.Printf("    %-24s %-10s %s\n", "Applied At", "State", "Migration")
+	for _, st := range statuses {
+		appliedAt := "Pending"
+		state := "pending"
+		if st.Applied {
+			appliedAt = st.AppliedAt.Format(time.DateTime)
+			state = "applied"
+		}
+		if st.Modified {
+			state = "MODIFIED"
+		}
+		fmt.Printf("    %-24s %-10s %s\n", appliedAt, state, st.Name)
+	}
 }
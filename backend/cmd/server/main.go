@@ -2,54 +2,252 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/database"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/health"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/mail"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/oidc"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/version"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/lockout"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/security"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/totp"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/cors"
 )
 
 func main() {
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Parse(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if *printConfig {
+		out, _ := json.MarshalIndent(cfg.Redacted(), "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Initialize Gin router
 	if cfg.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
 	router := gin.New()
+	// Login lockouts are counted per client IP, so forwarding headers are
+	// only believed when they come from a configured proxy
+	if err := router.SetTrustedProxies(cfg.TrustedProxyList()); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
+	// Database connection; failures surface through /readyz instead of
+	// preventing startup
+	db, dialect, err := database.Open(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+
+	metrics := middleware.NewMetrics(db)
 
 	// Add middleware
-	router.Use(gin.Logger())
+	router.Use(middleware.RequestLogger(logger))
+	router.Use(metrics.Middleware())
 	router.Use(gin.Recovery())
-	router.Use(middleware.CORS())
+	corsMiddleware, err := middleware.CORS(cfg,
+		cors.Route{PathPrefix: "/health", Methods: []string{http.MethodGet}},
+		cors.Route{PathPrefix: "/livez", Methods: []string{http.MethodGet}},
+		cors.Route{PathPrefix: "/readyz", Methods: []string{http.MethodGet}},
+	)
+	if err != nil {
+		log.Fatalf("Invalid CORS configuration: %v", err)
+	}
+	router.Use(corsMiddleware)
 
-	// Health check endpoint
+	checks := health.NewRegistry(cfg.HealthCheckTimeout)
+	checks.Register("database", health.DatabasePing(db))
+	if migrator, err := database.NewMigrator(db, dialect, cfg.MigrationsDir); err != nil {
+		logger.Warn("migration check disabled", slog.String("error", err.Error()))
+	} else {
+		checks.Register("migrations", health.MigrationsCurrent(migrator))
+	}
+	if cfg.RedisAddr != "" {
+		checks.Register("redis", health.RedisPing(cfg.RedisAddr))
+	}
+
+	// Health check endpoints
 	router.GET("/health", handlers.HealthCheck)
+	router.GET("/livez", handlers.HealthCheck)
+	router.GET("/readyz", handlers.Readiness(checks))
+
+	// Prometheus metrics
+	router.GET("/metrics", metrics.Handler())
+
+	// Authentication
+	signingKeys, err := auth.LoadKeys(cfg.JWTSigningKeyFile, cfg.JWTVerifyKeyFiles)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
+	revocations := jwtservice.NewSQLRevocationStore(db)
+	go purgePeriodically("token revocations", revocations.Purge, logger)
+
+	loginAttempts := lockout.NewSQLStore(db)
+	go purgePeriodically("login attempts", func(ctx context.Context) error {
+		return loginAttempts.Purge(ctx, time.Now().Add(-cfg.LoginFailureWindow))
+	}, logger)
+	limiter := lockout.New(lockout.Config{
+		MaxAccountFailures: cfg.LoginMaxFailures,
+		MaxIPFailures:      cfg.LoginMaxIPFailures,
+		Window:             cfg.LoginFailureWindow,
+		LockoutDuration:    cfg.LoginLockoutDuration,
+		Store:              loginAttempts,
+		Audit: func(ctx context.Context, e lockout.Event) {
+			logger.Warn("audit",
+				slog.String("event", e.Type),
+				slog.String("key", e.Key),
+				slog.Int("failures", e.Failures),
+				slog.Time("until", e.Until),
+				slog.String("actor", e.Actor),
+			)
+		},
+	})
+
+	passwords, err := security.NewPasswordServiceWithConfig(security.HashConfig{
+		Algorithm:  cfg.PasswordHashAlgorithm,
+		BcryptCost: cfg.BcryptCost,
+		Argon2: security.Argon2Params{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+		},
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure password hashing: %v", err)
+	}
+
+	passwordPolicy := security.UserPasswordPolicy
+	passwordPolicy.MinLength = cfg.PasswordMinLength
+	passwordPolicy.MaxLength = cfg.PasswordMaxLength
+	if cfg.BreachedPasswordsFile != "" {
+		breached, err := security.LoadBreachedPasswords(cfg.BreachedPasswordsFile)
+		if err != nil {
+			log.Fatalf("Failed to load breached passwords: %v", err)
+		}
+		passwordPolicy.Breached = breached
+	}
+
+	var mailer mail.Mailer
+	switch {
+	case cfg.SMTPAddr != "":
+		mailer = &mail.SMTPMailer{Addr: cfg.SMTPAddr, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.MailFrom}
+	case cfg.MailDir != "":
+		mailer = &mail.FileMailer{Dir: cfg.MailDir, From: cfg.MailFrom}
+	default:
+		logger.Warn("no smtp_addr or mail_dir set, outgoing mail is only logged")
+		mailer = &mail.LogMailer{Logger: logger}
+	}
+
+	userTokens := auth.NewSQLTokenStore(db)
+	go purgePeriodically("user tokens", userTokens.Purge, logger)
+
+	permissions := auth.NewPolicy()
+	authService, err := auth.NewService(auth.NewSQLUserStore(db), auth.Config{
+		Tokens: jwtservice.Config{
+			SecretKey:  cfg.JWTSecret,
+			Keys:       signingKeys,
+			AccessTTL:  cfg.JWTAccessTTL,
+			RefreshTTL: cfg.JWTRefreshTTL,
+			MFATTL:     cfg.MFATokenTTL,
+			Store:      revocations,
+		},
+		Passwords:      passwords,
+		PasswordPolicy: &passwordPolicy,
+		Lockout:        limiter,
+
+		Mailer:          mailer,
+		UserTokens:      userTokens,
+		AppURL:          cfg.AppURL,
+		VerificationTTL: cfg.EmailVerificationTTL,
+		ResetTTL:        cfg.PasswordResetTTL,
+		Logger:          logger,
+
+		TOTP: totp.New(totp.Config{Issuer: cfg.TOTPIssuer}),
+		MFA:  auth.NewSQLMFAStore(db),
+
+		Identities:  auth.NewSQLIdentityStore(db),
+		APIKeys:     auth.NewSQLAPIKeyStore(db),
+		Permissions: permissions,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create auth service: %v", err)
+	}
 
 	// API routes
 	api := router.Group("/api/v1")
 	{
 		api.GET("/ping", handlers.Ping)
-		// Add more routes as needed
+		handlers.NewAuthHandler(authService).RegisterRoutes(api.Group("/auth"))
+		handlers.NewAdminHandler(authService, permissions).RegisterRoutes(api.Group("/admin"))
+		if provider := discoverOIDC(cfg, logger); provider != nil {
+			secure := strings.HasPrefix(cfg.OIDCRedirectURL, "https://")
+			handlers.NewOIDCHandler(authService, provider, secure).RegisterRoutes(api.Group("/auth/oidc"))
+		}
+	}
+
+	// Debug endpoints are opt-in, refused in production by config
+	// validation and only served to admins
+	if cfg.DebugEndpoints {
+		debug := router.Group("/debug",
+			middleware.RequireAuthOrAPIKey(authService, authService),
+			middleware.RequirePermission(permissions, auth.PermConfigRead),
+		)
+		debug.GET("/config", handlers.ConfigDump(cfg))
 	}
 
+	// Public keys for services that verify our tokens
+	router.GET("/.well-known/jwks.json", gin.WrapH(authService.JWKSHandler()))
+
 	// Create HTTP server
 	server := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: router,
+		Addr:         ":" + cfg.Port,
+		Handler:      router,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("🚀 Server starting on port %s", cfg.Port)
+		log.Printf("🚀 Server %s (%s) starting on port %s", version.Version, version.Commit, cfg.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
@@ -61,8 +259,8 @@ func main() {
 	<-quit
 	log.Println("🛑 Shutting down server...")
 
-	// Give outstanding requests 10 seconds to complete
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Give outstanding requests time to complete
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
@@ -71,3 +269,39 @@ func main() {
 
 	log.Println("✅ Server exited")
 }
+
+// discoverOIDC connects to the configured OpenID Connect provider. An
+// unreachable provider only disables provider login, so it is logged
+// instead of preventing startup.
+func discoverOIDC(cfg *config.Config, logger *slog.Logger) *oidc.Provider {
+	if cfg.OIDCIssuerURL == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	provider, err := oidc.New(ctx, oidc.Config{
+		IssuerURL:    cfg.OIDCIssuerURL,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+	})
+	if err != nil {
+		logger.Error("oidc login disabled", slog.String("issuer", cfg.OIDCIssuerURL), slog.String("error", err.Error()))
+		return nil
+	}
+	return provider
+}
+
+// purgePeriodically calls purge every hour to delete expired rows of what
+func purgePeriodically(what string, purge func(context.Context) error, logger *slog.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := purge(context.Background()); err != nil {
+			logger.Warn("failed to purge "+what, slog.String("error", err.Error()))
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrAPIKeyNotFound is returned for API keys that do not exist or belong to
+// another user
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey is a stored API key. Only the hash of the secret part is kept;
+// Prefix is shown in listings so users can tell their keys apart.
+type APIKey struct {
+	ID     int      `json:"id"`
+	UserID int      `json:"-"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"`
+	Hash   string   `json:"-"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is zero for keys that do not expire
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// APIKeyStore persists API keys
+type APIKeyStore interface {
+	// Create stores key and sets its ID
+	Create(ctx context.Context, key *APIKey) error
+	// GetByPrefix returns the key with prefix or ErrAPIKeyNotFound
+	GetByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	// ListForUser returns the keys of a user, newest first
+	ListForUser(ctx context.Context, userID int) ([]*APIKey, error)
+	// Delete removes a key of the user or returns ErrAPIKeyNotFound
+	Delete(ctx context.Context, userID, id int) error
+	// Touch records that the key was used at the given time
+	Touch(ctx context.Context, id int, at time.Time) error
+}
+
+// MemoryAPIKeyStore is an APIKeyStore kept in process memory
+type MemoryAPIKeyStore struct {
+	mu     sync.RWMutex
+	keys   map[int]*APIKey
+	nextID int
+}
+
+// NewMemoryAPIKeyStore creates an empty MemoryAPIKeyStore
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: make(map[int]*APIKey), nextID: 1}
+}
+
+// Create implements APIKeyStore
+func (s *MemoryAPIKeyStore) Create(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.ID = s.nextID
+	s.nextID++
+	stored := *key
+	stored.Scopes = append([]string(nil), key.Scopes...)
+	s.keys[key.ID] = &stored
+	return nil
+}
+
+// GetByPrefix implements APIKeyStore
+func (s *MemoryAPIKeyStore) GetByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.Prefix == prefix {
+			found := *key
+			return &found, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+// ListForUser implements APIKeyStore
+func (s *MemoryAPIKeyStore) ListForUser(ctx context.Context, userID int) ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []*APIKey{}
+	for _, key := range s.keys {
+		if key.UserID == userID {
+			found := *key
+			keys = append(keys, &found)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+// Delete implements APIKeyStore
+func (s *MemoryAPIKeyStore) Delete(ctx context.Context, userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok || key.UserID != userID {
+		return ErrAPIKeyNotFound
+	}
+	delete(s.keys, id)
+	return nil
+}
+
+// Touch implements APIKeyStore
+func (s *MemoryAPIKeyStore) Touch(ctx context.Context, id int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[id]; ok {
+		key.LastUsedAt = at
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to find with
+// secret scanners
+const APIKeyPrefix = "ck_"
+
+// TokenTypeAPIKey is the TokenType of claims built from an API key. Such
+// claims are never signed; they only exist in the request context.
+const TokenTypeAPIKey = "api_key"
+
+// apiKeyTouchInterval limits how often LastUsedAt is written for a busy key
+const apiKeyTouchInterval = time.Minute
+
+// ErrInvalidAPIKey is returned for API keys that are malformed, unknown,
+// expired or whose owner no longer exists
+var ErrInvalidAPIKey = errors.New("invalid or expired api key")
+
+// CreatedAPIKey is returned once when a key is created. Key is the only copy
+// of the full secret.
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey creates an API key for a user. Every scope must be a
+// permission the user currently has; a zero expiresAt creates a key that
+// does not expire.
+func (s *Service) CreateAPIKey(ctx context.Context, userID int, name string, scopes []string, expiresAt time.Time) (*CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return nil, fmt.Errorf("%w: name must be between 1 and 255 characters", ErrInvalidInput)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidInput)
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidInput)
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	subject := s.permissions.Subject(&jwtservice.Claims{UserID: user.ID, Roles: user.Roles})
+	for _, scope := range scopes {
+		if scope == "" || strings.Contains(scope, ",") {
+			return nil, fmt.Errorf("%w: invalid scope %q", ErrInvalidInput, scope)
+		}
+		if !subject.Can(scope) {
+			return nil, fmt.Errorf("%w: scope %q exceeds your permissions", ErrInvalidInput, scope)
+		}
+	}
+
+	prefix := APIKeyPrefix + strings.ToLower(rand.Text()[:12])
+	secret := prefix + "_" + rand.Text()
+	key := &APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hashToken(secret),
+		Scopes:    append([]string(nil), scopes...),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	if err := s.apiKeys.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: key, Key: secret}, nil
+}
+
+// APIKeys lists the keys of a user without their secrets
+func (s *Service) APIKeys(ctx context.Context, userID int) ([]*APIKey, error) {
+	return s.apiKeys.ListForUser(ctx, userID)
+}
+
+// RevokeAPIKey deletes a key of the user. Requests using it fail
+// immediately.
+func (s *Service) RevokeAPIKey(ctx context.Context, userID, id int) error {
+	return s.apiKeys.Delete(ctx, userID, id)
+}
+
+// ValidateAPIKey checks an API key and returns claims for its owner, so that
+// handlers see the same principal as for a bearer token. The claims carry
+// no roles: the permissions are the key's scopes that the owner still has,
+// so a key never outlives a demotion.
+func (s *Service) ValidateAPIKey(ctx context.Context, secret string) (*jwtservice.Claims, error) {
+	prefix, ok := apiKeyPrefix(secret)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.apiKeys.GetByPrefix(ctx, prefix)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(key.Hash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if !key.ExpiresAt.IsZero() && !key.ExpiresAt.After(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.users.GetByID(ctx, key.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if now.Sub(key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeys.Touch(ctx, key.ID, now); err != nil {
+			s.logger.WarnContext(ctx, "failed to record api key use",
+				slog.Int("api_key_id", key.ID), slog.String("error", err.Error()))
+		}
+	}
+
+	owner := s.permissions.Subject(&jwtservice.Claims{UserID: user.ID, Roles: user.Roles})
+	var permissions []string
+	for _, scope := range key.Scopes {
+		if owner.Can(scope) {
+			permissions = append(permissions, scope)
+		}
+	}
+
+	claims := &jwtservice.Claims{
+		UserID:      user.ID,
+		Email:       user.Email,
+		TokenType:   TokenTypeAPIKey,
+		Permissions: permissions,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       key.Prefix,
+			Subject:  strconv.Itoa(user.ID),
+			IssuedAt: jwt.NewNumericDate(key.CreatedAt),
+		},
+	}
+	if !key.ExpiresAt.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(key.ExpiresAt)
+	}
+	return claims, nil
+}
+
+// apiKeyPrefix returns the public prefix of a key in the format
+// ck_<prefix>_<secret>
+func apiKeyPrefix(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", false
+	}
+	return APIKeyPrefix + prefix, true
+}
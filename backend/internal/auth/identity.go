@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/oidc"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/userdomain"
+)
+
+// External login errors
+var (
+	ErrUnverifiedIdentity  = errors.New("the identity provider has not verified this email")
+	ErrAccountLinkConflict = errors.New("email is registered but not verified; log in with your password and verify it first")
+)
+
+// IdentityStore links accounts at external identity providers to users
+type IdentityStore interface {
+	// FindUser returns the ID of the user linked to the provider account,
+	// or ErrUserNotFound
+	FindUser(ctx context.Context, issuer, subject string) (int, error)
+	Link(ctx context.Context, issuer, subject string, userID int) error
+}
+
+// MemoryIdentityStore is an IdentityStore kept in process memory
+type MemoryIdentityStore struct {
+	mu    sync.RWMutex
+	links map[[2]string]int
+}
+
+// NewMemoryIdentityStore creates an empty MemoryIdentityStore
+func NewMemoryIdentityStore() *MemoryIdentityStore {
+	return &MemoryIdentityStore{links: make(map[[2]string]int)}
+}
+
+// FindUser implements IdentityStore
+func (s *MemoryIdentityStore) FindUser(ctx context.Context, issuer, subject string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userID, ok := s.links[[2]string{issuer, subject}]
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+	return userID, nil
+}
+
+// Link implements IdentityStore
+func (s *MemoryIdentityStore) Link(ctx context.Context, issuer, subject string, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.links[[2]string{issuer, subject}] = userID
+	return nil
+}
+
+// LoginWithIdentity logs in the user linked to a verified provider account
+// and returns our own tokens.
+//
+// An unknown provider account is linked to the user with the same email if
+// the provider verified that email, or to a new user if there is none. An
+// existing user whose email we have not verified is not linked, because
+// whoever registered it may not own the address.
+//
+// Users with two-factor authentication get a *MFARequiredError as with
+// Login.
+func (s *Service) LoginWithIdentity(ctx context.Context, identity *oidc.Identity) (*Tokens, error) {
+	user, err := s.identityUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireMFA(ctx, user); err != nil {
+		return nil, err
+	}
+
+	pair, err := s.tokens.IssueTokenPair(s.subject(user))
+	if err != nil {
+		return nil, err
+	}
+	tokens := newTokens(pair)
+	tokens.User = user
+	return tokens, nil
+}
+
+// identityUser finds or creates the user for identity
+func (s *Service) identityUser(ctx context.Context, identity *oidc.Identity) (*userdomain.User, error) {
+	userID, err := s.identities.FindUser(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return s.users.GetByID(ctx, userID)
+	}
+	if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+
+	if !identity.EmailVerified {
+		return nil, ErrUnverifiedIdentity
+	}
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+	user, err := s.users.GetByEmail(ctx, email)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		user, err = s.createExternalUser(ctx, email, identity.Name)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case !user.EmailVerified:
+		return nil, ErrAccountLinkConflict
+	}
+
+	if err := s.identities.Link(ctx, identity.Issuer, identity.Subject, user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createExternalUser registers a user that logs in through a provider. The
+// password is random, so it stays unusable until the user resets it.
+func (s *Service) createExternalUser(ctx context.Context, email, name string) (*userdomain.User, error) {
+	if err := userdomain.ValidateEmail(email); err != nil {
+		return nil, ErrUnverifiedIdentity
+	}
+	name = strings.TrimSpace(name)
+	if userdomain.ValidateName(name) != nil {
+		name = email[:strings.Index(email, "@")]
+		if userdomain.ValidateName(name) != nil {
+			name = "User"
+		}
+	}
+
+	hash, err := s.passwords.HashPassword(rand.Text())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &userdomain.User{
+		Email:         email,
+		Name:          name,
+		Password:      hash,
+		Roles:         append([]string(nil), DefaultRoles...),
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+)
+
+// LoadKeys builds a key set from a PEM signing key and a comma-separated list
+// of PEM keys kept for verification during rotation. It returns nil when
+// signingKeyFile is empty, meaning tokens are signed with the shared secret.
+// Key IDs are derived from the keys themselves.
+func LoadKeys(signingKeyFile, verifyKeyFiles string) (*jwtservice.KeySet, error) {
+	if signingKeyFile == "" {
+		return nil, nil
+	}
+
+	active, err := jwtservice.LoadKeyFile("", signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var verifiers []*jwtservice.Key
+	for _, path := range strings.Split(verifyKeyFiles, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := jwtservice.LoadKeyFile("", path)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = append(verifiers, key)
+	}
+
+	return jwtservice.NewKeySet(active, verifiers...)
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeEd25519Key(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(private)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return path
+}
+
+func TestLoadKeys(t *testing.T) {
+	keys, err := LoadKeys("", "")
+	if err != nil || keys != nil {
+		t.Errorf("Expected no key set without a signing key, got %v, %v", keys, err)
+	}
+
+	dir := t.TempDir()
+	current := writeEd25519Key(t, dir, "current.pem")
+	previous := writeEd25519Key(t, dir, "previous.pem")
+
+	keys, err = LoadKeys(current, " "+previous+", ")
+	if err != nil {
+		t.Fatalf("Expected keys to load, got %v", err)
+	}
+	if got := len(keys.JWKS().Keys); got != 2 {
+		t.Errorf("Expected 2 published keys, got %d", got)
+	}
+
+	if _, err := LoadKeys(filepath.Join(dir, "missing.pem"), ""); err == nil {
+		t.Error("Expected error for missing key file")
+	}
+}
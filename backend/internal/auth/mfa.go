@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/totp"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/userdomain"
+)
+
+// Two-factor errors
+var (
+	ErrInvalidMFACode    = errors.New("invalid authentication code")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+)
+
+// MFARequiredError is returned by Login when the password was correct but
+// the user has two-factor authentication enabled. Token must be passed to
+// VerifyMFA together with a code.
+type MFARequiredError struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+func (e *MFARequiredError) Error() string {
+	return "second authentication factor required"
+}
+
+// MFAEnrollment is returned when a user starts setting up two-factor
+// authentication
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth URI to show as a QR code
+	URI string `json:"otpauth_uri"`
+}
+
+// MFAStatus describes the two-factor state of a user
+type MFAStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// BeginMFA generates a new TOTP secret for a user. It takes effect once
+// confirmed with ConfirmMFA.
+func (s *Service) BeginMFA(ctx context.Context, userID int) (*MFAEnrollment, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa, err := s.mfa.Get(ctx, userID); err == nil && mfa.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	} else if err != nil && !errors.Is(err, ErrMFANotEnrolled) {
+		return nil, err
+	}
+
+	secret := totp.GenerateSecret()
+	if err := s.mfa.Begin(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+	return &MFAEnrollment{Secret: secret, URI: s.totp.URI(user.Email, secret)}, nil
+}
+
+// ConfirmMFA enables two-factor authentication after checking a code for
+// the secret from BeginMFA. It returns the recovery codes, which are not
+// stored in plain text and cannot be shown again.
+func (s *Service) ConfirmMFA(ctx context.Context, userID int, code string) ([]string, error) {
+	mfa, err := s.mfa.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok, err := s.totp.Verify(mfa.Secret, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes := totp.GenerateRecoveryCodes(totp.DefaultRecoveryCodes)
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+	if err := s.mfa.Enable(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA turns two-factor authentication off after checking a TOTP or
+// recovery code. Failed codes count towards the login lockout so a stolen
+// access token cannot be used to guess them.
+func (s *Service) DisableMFA(ctx context.Context, userID int, code string) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	mfa, err := s.mfa.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled {
+		return ErrMFANotEnrolled
+	}
+
+	if err := s.lockout.Check(ctx, user.Email, ""); err != nil {
+		return err
+	}
+	ok, err := s.checkSecondFactor(ctx, mfa, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.mfaFailed(ctx, user.Email, "")
+	}
+	return s.mfa.Delete(ctx, userID)
+}
+
+// MFAStatus reports whether a user has two-factor authentication enabled
+func (s *Service) MFAStatus(ctx context.Context, userID int) (MFAStatus, error) {
+	mfa, err := s.mfa.Get(ctx, userID)
+	if errors.Is(err, ErrMFANotEnrolled) {
+		return MFAStatus{}, nil
+	}
+	if err != nil {
+		return MFAStatus{}, err
+	}
+	return MFAStatus{Enabled: mfa.Enabled, RecoveryCodesLeft: mfa.RecoveryCodesLeft}, nil
+}
+
+// VerifyMFA completes a login that returned *MFARequiredError. code is a
+// TOTP code or an unused recovery code. Failures count towards the login
+// lockout of the account and ip.
+func (s *Service) VerifyMFA(ctx context.Context, mfaToken, code, ip string) (*Tokens, error) {
+	claims, err := s.tokens.ValidateMFAToken(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lockout.Check(ctx, claims.Email, ip); err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.mfa.Get(ctx, claims.UserID)
+	if errors.Is(err, ErrMFANotEnrolled) {
+		// Disabled since the password step
+		return nil, jwtservice.ErrTokenRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.checkSecondFactor(ctx, mfa, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.mfaFailed(ctx, claims.Email, ip)
+	}
+	if err := s.lockout.Success(ctx, claims.Email); err != nil {
+		return nil, err
+	}
+
+	pair, err := s.tokens.CompleteMFA(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	tokens := newTokens(pair)
+	tokens.User = user
+	return tokens, nil
+}
+
+// requireMFA returns a *MFARequiredError if user has two-factor
+// authentication enabled, nil if not
+func (s *Service) requireMFA(ctx context.Context, user *userdomain.User) error {
+	mfa, err := s.mfa.Get(ctx, user.ID)
+	if errors.Is(err, ErrMFANotEnrolled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled {
+		return nil
+	}
+
+	token, expiresAt, err := s.tokens.IssueMFAToken(s.subject(user))
+	if err != nil {
+		return fmt.Errorf("failed to issue mfa token: %w", err)
+	}
+	return &MFARequiredError{Token: token, ExpiresAt: expiresAt}
+}
+
+// checkSecondFactor accepts a TOTP code for a step after the last used one,
+// or an unused recovery code
+func (s *Service) checkSecondFactor(ctx context.Context, mfa *MFA, code string) (bool, error) {
+	if totp.LooksLikeRecoveryCode(code) {
+		return s.mfa.UseRecoveryCode(ctx, mfa.UserID, totp.HashRecoveryCode(code))
+	}
+
+	step, ok, err := s.totp.Verify(mfa.Secret, code, time.Now())
+	if err != nil || !ok {
+		return false, err
+	}
+	return s.mfa.UseStep(ctx, mfa.UserID, step)
+}
+
+// mfaFailed records a failed code and returns ErrInvalidMFACode
+func (s *Service) mfaFailed(ctx context.Context, email, ip string) error {
+	if err := s.lockout.Failure(ctx, email, ip); err != nil {
+		return err
+	}
+	return ErrInvalidMFACode
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrMFANotEnrolled is returned when a user has not set up two-factor
+// authentication, or has not confirmed it yet where that is required
+var ErrMFANotEnrolled = errors.New("two-factor authentication is not set up")
+
+// MFA is the two-factor state of a user
+type MFA struct {
+	UserID int
+	// Secret is the base32 TOTP secret
+	Secret string
+	// Enabled is false until the user confirmed enrollment with a code
+	Enabled bool
+	// LastStep is the last accepted TOTP time step
+	LastStep          int64
+	RecoveryCodesLeft int
+}
+
+// MFAStore persists TOTP secrets and recovery code hashes. UseStep and
+// UseRecoveryCode must be atomic so that a code is accepted only once.
+type MFAStore interface {
+	// Get returns ErrMFANotEnrolled if the user never started enrollment
+	Get(ctx context.Context, userID int) (*MFA, error)
+	// Begin stores an unconfirmed secret, replacing any previous one
+	Begin(ctx context.Context, userID int, secret string) error
+	// Enable confirms enrollment at the given step and replaces the
+	// recovery codes
+	Enable(ctx context.Context, userID int, step int64, recoveryHashes []string) error
+	// UseStep records step as used. It returns false if it is not after the
+	// last used step.
+	UseStep(ctx context.Context, userID int, step int64) (bool, error)
+	// UseRecoveryCode marks a code as used. It returns false if the code is
+	// unknown or was used before.
+	UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error)
+	// Delete turns two-factor authentication off
+	Delete(ctx context.Context, userID int) error
+}
+
+// MemoryMFAStore is an MFAStore kept in process memory
+type MemoryMFAStore struct {
+	mu       sync.Mutex
+	mfa      map[int]MFA
+	recovery map[int]map[string]bool
+}
+
+// NewMemoryMFAStore creates an empty MemoryMFAStore
+func NewMemoryMFAStore() *MemoryMFAStore {
+	return &MemoryMFAStore{
+		mfa:      make(map[int]MFA),
+		recovery: make(map[int]map[string]bool),
+	}
+}
+
+// Get implements MFAStore
+func (s *MemoryMFAStore) Get(ctx context.Context, userID int) (*MFA, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mfa, ok := s.mfa[userID]
+	if !ok {
+		return nil, ErrMFANotEnrolled
+	}
+	mfa.RecoveryCodesLeft = len(s.recovery[userID])
+	return &mfa, nil
+}
+
+// Begin implements MFAStore
+func (s *MemoryMFAStore) Begin(ctx context.Context, userID int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mfa[userID] = MFA{UserID: userID, Secret: secret}
+	delete(s.recovery, userID)
+	return nil
+}
+
+// Enable implements MFAStore
+func (s *MemoryMFAStore) Enable(ctx context.Context, userID int, step int64, recoveryHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mfa, ok := s.mfa[userID]
+	if !ok {
+		return ErrMFANotEnrolled
+	}
+	mfa.Enabled = true
+	mfa.LastStep = step
+	s.mfa[userID] = mfa
+
+	codes := make(map[string]bool, len(recoveryHashes))
+	for _, hash := range recoveryHashes {
+		codes[hash] = true
+	}
+	s.recovery[userID] = codes
+	return nil
+}
+
+// UseStep implements MFAStore
+func (s *MemoryMFAStore) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mfa, ok := s.mfa[userID]
+	if !ok || step <= mfa.LastStep {
+		return false, nil
+	}
+	mfa.LastStep = step
+	s.mfa[userID] = mfa
+	return true, nil
+}
+
+// UseRecoveryCode implements MFAStore. Used codes are simply deleted.
+func (s *MemoryMFAStore) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.recovery[userID][hash] {
+		return false, nil
+	}
+	delete(s.recovery[userID], hash)
+	return true, nil
+}
+
+// Delete implements MFAStore
+func (s *MemoryMFAStore) Delete(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.mfa, userID)
+	delete(s.recovery, userID)
+	return nil
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/policy"
+)
+
+// Roles assigned to users
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleUser   = "user"
+)
+
+// DefaultRoles are given to newly registered users
+var DefaultRoles = []string{RoleUser}
+
+// Permissions checked by the API
+const (
+	PermUsersRead  = "users:read"
+	PermUsersWrite = "users:write"
+	PermPostsRead  = "posts:read"
+	PermPostsWrite = "posts:write"
+	// PermMessagesModerate lets the lab03 chat's moderators edit and delete
+	// any message
+	PermMessagesModerate = "messages:moderate"
+	// PermConfigRead allows reading the effective configuration on
+	// /debug/config when debug endpoints are enabled
+	PermConfigRead = "config:read"
+)
+
+// NewPolicy returns the role table used by the backend
+func NewPolicy() *policy.Policy {
+	return policy.New(map[string][]string{
+		RoleAdmin:  {"*"},
+		RoleEditor: {"posts:*", PermMessagesModerate},
+		RoleUser:   {PermPostsRead},
+	})
+}
+
+// joinRoles and splitRoles store roles in a single comma-separated column
+func joinRoles(roles []string) string {
+	return strings.Join(roles, ",")
+}
+
+func splitRoles(column string) []string {
+	var roles []string
+	for _, role := range strings.Split(column, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
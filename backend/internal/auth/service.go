@@ -0,0 +1,506 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/mail"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/lockout"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/policy"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/security"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/totp"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/userdomain"
+)
+
+// Service errors
+var (
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+	ErrInvalidInput         = errors.New("invalid input")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+)
+
+// Default lifetimes of one-time tokens
+const (
+	DefaultVerificationTTL = 24 * time.Hour
+	DefaultResetTTL        = time.Hour
+)
+
+// Tokens is the result of a successful login or refresh
+type Tokens struct {
+	jwtservice.TokenPair
+	TokenType string           `json:"token_type"`
+	ExpiresIn int              `json:"expires_in"`
+	User      *userdomain.User `json:"user,omitempty"`
+}
+
+// Config configures a Service
+type Config struct {
+	Tokens jwtservice.Config
+	// Passwords defaults to security.NewPasswordService()
+	Passwords *security.PasswordService
+	// PasswordPolicy defaults to security.UserPasswordPolicy
+	PasswordPolicy *security.PasswordPolicy
+	// Lockout throttles failed logins. It defaults to a limiter with an
+	// in-memory store.
+	Lockout *lockout.Limiter
+
+	// Mailer sends verification and password reset links. It defaults to
+	// logging them through Logger.
+	Mailer mail.Mailer
+	// UserTokens defaults to a MemoryTokenStore
+	UserTokens TokenStore
+	// AppURL is the frontend base URL that links in emails point to
+	AppURL          string
+	VerificationTTL time.Duration
+	ResetTTL        time.Duration
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
+
+	// TOTP verifies two-factor codes. It defaults to the RFC 6238 settings
+	// used by common authenticator apps.
+	TOTP *totp.TOTP
+	// MFA defaults to a MemoryMFAStore
+	MFA MFAStore
+	// Identities links external provider accounts and defaults to a
+	// MemoryIdentityStore
+	Identities IdentityStore
+	// APIKeys defaults to a MemoryAPIKeyStore
+	APIKeys APIKeyStore
+	// Permissions limits the scopes of API keys to what their owner may do.
+	// It defaults to NewPolicy().
+	Permissions *policy.Policy
+}
+
+// Service registers and authenticates users and issues bearer tokens
+type Service struct {
+	users     UserStore
+	passwords *security.PasswordService
+	policy    security.PasswordPolicy
+	lockout   *lockout.Limiter
+	tokens    *jwtservice.JWTService
+
+	mailer          mail.Mailer
+	userTokens      TokenStore
+	appURL          string
+	verificationTTL time.Duration
+	resetTTL        time.Duration
+	logger          *slog.Logger
+
+	totp       *totp.TOTP
+	mfa        MFAStore
+	identities IdentityStore
+
+	apiKeys     APIKeyStore
+	permissions *policy.Policy
+}
+
+// NewService creates a Service configured by cfg
+func NewService(users UserStore, cfg Config) (*Service, error) {
+	jwt, err := jwtservice.New(cfg.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{
+		users:     users,
+		passwords: cfg.Passwords,
+		policy:    security.UserPasswordPolicy,
+		lockout:   cfg.Lockout,
+		tokens:    jwt,
+
+		mailer:          cfg.Mailer,
+		userTokens:      cfg.UserTokens,
+		appURL:          strings.TrimSuffix(cfg.AppURL, "/"),
+		verificationTTL: cfg.VerificationTTL,
+		resetTTL:        cfg.ResetTTL,
+		logger:          cfg.Logger,
+
+		totp:       cfg.TOTP,
+		mfa:        cfg.MFA,
+		identities: cfg.Identities,
+
+		apiKeys:     cfg.APIKeys,
+		permissions: cfg.Permissions,
+	}
+	if s.passwords == nil {
+		s.passwords = security.NewPasswordService()
+	}
+	if cfg.PasswordPolicy != nil {
+		s.policy = *cfg.PasswordPolicy
+	}
+	if s.lockout == nil {
+		s.lockout = lockout.New(lockout.Config{})
+	}
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
+	if s.mailer == nil {
+		s.mailer = &mail.LogMailer{Logger: s.logger}
+	}
+	if s.userTokens == nil {
+		s.userTokens = NewMemoryTokenStore()
+	}
+	if s.verificationTTL <= 0 {
+		s.verificationTTL = DefaultVerificationTTL
+	}
+	if s.resetTTL <= 0 {
+		s.resetTTL = DefaultResetTTL
+	}
+	if s.totp == nil {
+		s.totp = totp.New(totp.Config{})
+	}
+	if s.mfa == nil {
+		s.mfa = NewMemoryMFAStore()
+	}
+	if s.identities == nil {
+		s.identities = NewMemoryIdentityStore()
+	}
+	if s.apiKeys == nil {
+		s.apiKeys = NewMemoryAPIKeyStore()
+	}
+	if s.permissions == nil {
+		s.permissions = NewPolicy()
+	}
+	return s, nil
+}
+
+// Register validates and stores a new user and emails them a verification
+// link. Validation failures wrap ErrInvalidInput and carry a message that is
+// safe to show to the client; password rule violations also wrap
+// security.ValidationErrors.
+//
+// A failure to send the email is only logged, since the user can request
+// another link once logged in.
+func (s *Service) Register(ctx context.Context, email, name, password string) (*userdomain.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	name = strings.TrimSpace(name)
+	if err := userdomain.ValidateEmail(email); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if err := userdomain.ValidateName(name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if err := s.policy.Validate(password, email, name); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	hash, err := s.passwords.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &userdomain.User{
+		Email:     email,
+		Name:      name,
+		Password:  hash,
+		Roles:     append([]string(nil), DefaultRoles...),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	if err := s.sendVerification(ctx, user); err != nil {
+		s.logger.WarnContext(ctx, "failed to send verification email",
+			slog.Int("user_id", user.ID), slog.String("error", err.Error()))
+	}
+	return user, nil
+}
+
+// RequestEmailVerification emails a new verification link to a user
+func (s *Service) RequestEmailVerification(ctx context.Context, userID int) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+	return s.sendVerification(ctx, user)
+}
+
+// ConfirmEmail redeems a verification token and returns the verified user
+func (s *Service) ConfirmEmail(ctx context.Context, token string) (*userdomain.User, error) {
+	userID, err := s.userTokens.Consume(ctx, PurposeVerifyEmail, hashToken(token), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.SetEmailVerified(ctx, userID); err != nil {
+		return nil, err
+	}
+	if err := s.userTokens.DeleteForUser(ctx, userID, PurposeVerifyEmail); err != nil {
+		return nil, err
+	}
+	return s.users.GetByID(ctx, userID)
+}
+
+// RequestPasswordReset emails a password reset link if email belongs to a
+// user. Unknown addresses are ignored without an error so the endpoint does
+// not reveal who is registered.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.users.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	link, err := s.issueToken(ctx, user.ID, PurposeResetPassword, s.resetTTL, "/reset-password")
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below within %s to choose a new password:\n\n%s\n\n"+
+			"If you did not ask for a password reset, you can ignore this email.\n",
+			user.Name, s.resetTTL, link),
+	})
+}
+
+// ResetPassword redeems a reset token and sets a new password. Every
+// session of the user is revoked and any login lockout is lifted.
+//
+// The password is checked against the policy before the token is used up,
+// so a rejected password can be corrected with the same link.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	hash := hashToken(token)
+	userID, err := s.userTokens.Lookup(ctx, PurposeResetPassword, hash, time.Now())
+	if err != nil {
+		return err
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.policy.Validate(password, user.Email, user.Name); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	if _, err := s.userTokens.Consume(ctx, PurposeResetPassword, hash, time.Now()); err != nil {
+		return err
+	}
+	passwordHash, err := s.passwords.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.users.UpdatePassword(ctx, user.ID, passwordHash); err != nil {
+		return err
+	}
+	if err := s.tokens.RevokeSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.userTokens.DeleteForUser(ctx, user.ID, PurposeResetPassword); err != nil {
+		return err
+	}
+	// The reset link proves the user controls the address
+	if !user.EmailVerified {
+		if err := s.users.SetEmailVerified(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+	return s.lockout.Success(ctx, user.Email)
+}
+
+// sendVerification emails a new verification link to user
+func (s *Service) sendVerification(ctx context.Context, user *userdomain.User) error {
+	link, err := s.issueToken(ctx, user.ID, PurposeVerifyEmail, s.verificationTTL, "/verify-email")
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below within %s to verify your email address:\n\n%s\n",
+			user.Name, s.verificationTTL, link),
+	})
+}
+
+// issueToken stores a new one-time token and returns the frontend link at
+// path that carries it
+func (s *Service) issueToken(ctx context.Context, userID int, purpose string, ttl time.Duration, path string) (string, error) {
+	token, hash := newOneTimeToken()
+	err := s.userTokens.Create(ctx, OneTimeToken{
+		Hash:      hash,
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.appURL + path + "?token=" + url.QueryEscape(token), nil
+}
+
+// Login checks the credentials and starts a new token family. Password
+// hashes made with an outdated algorithm or cost are replaced on the way.
+//
+// Failed attempts are counted per email and per client IP; while either is
+// backing off or locked, Login returns a *lockout.LockedError without
+// checking the password.
+//
+// Users with two-factor authentication get a *MFARequiredError instead of
+// tokens; see VerifyMFA.
+func (s *Service) Login(ctx context.Context, email, password, ip string) (*Tokens, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := s.lockout.Check(ctx, email, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		// Take as long as a wrong password so timing does not reveal
+		// whether the email is registered
+		s.passwords.DummyVerify(password)
+		return nil, s.loginFailed(ctx, email, ip)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.passwords.VerifyPassword(password, user.Password) {
+		return nil, s.loginFailed(ctx, email, ip)
+	}
+	if s.passwords.NeedsRehash(user.Password) {
+		if err := s.rehash(ctx, user, password); err != nil {
+			return nil, err
+		}
+	}
+	// The failures are only cleared once the login is complete, which for
+	// two-factor users is in VerifyMFA. Clearing them after the password
+	// would let someone who knows it guess codes without ever locking.
+	if err := s.requireMFA(ctx, user); err != nil {
+		return nil, err
+	}
+	if err := s.lockout.Success(ctx, email); err != nil {
+		return nil, err
+	}
+
+	pair, err := s.tokens.IssueTokenPair(s.subject(user))
+	if err != nil {
+		return nil, err
+	}
+	tokens := newTokens(pair)
+	tokens.User = user
+	return tokens, nil
+}
+
+// loginFailed records a failed attempt and returns ErrInvalidCredentials
+func (s *Service) loginFailed(ctx context.Context, email, ip string) error {
+	if err := s.lockout.Failure(ctx, email, ip); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// LockoutStatus returns the failed-login record of a user
+func (s *Service) LockoutStatus(ctx context.Context, userID int) (lockout.Record, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return lockout.Record{}, err
+	}
+	return s.lockout.Status(ctx, lockout.AccountKey(user.Email))
+}
+
+// UnlockUser lifts a login lockout of a user on behalf of actor
+func (s *Service) UnlockUser(ctx context.Context, userID int, actor string) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.lockout.Unlock(ctx, lockout.AccountKey(user.Email), actor)
+}
+
+// UnlockIP lifts a login lockout of a client IP on behalf of actor
+func (s *Service) UnlockIP(ctx context.Context, ip, actor string) error {
+	return s.lockout.Unlock(ctx, lockout.IPKey(ip), actor)
+}
+
+// rehash stores a fresh hash of the verified password
+func (s *Service) rehash(ctx context.Context, user *userdomain.User, password string) error {
+	hash, err := s.passwords.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.users.UpdatePassword(ctx, user.ID, hash); err != nil {
+		return err
+	}
+	user.Password = hash
+	return nil
+}
+
+// Refresh rotates refreshToken. The new tokens carry the current roles of
+// the user, so role changes take effect on the next refresh. Errors from
+// jwtservice (expired, revoked, reused) are returned unchanged.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	pair, err := s.tokens.RefreshSubject(ctx, refreshToken, func(ctx context.Context, claims *jwtservice.Claims) (jwtservice.Subject, error) {
+		user, err := s.users.GetByID(ctx, claims.UserID)
+		if err != nil {
+			return jwtservice.Subject{}, err
+		}
+		return s.subject(user), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newTokens(pair), nil
+}
+
+// subject is who tokens for user are issued to. The permissions of the
+// user's roles are included, so services verifying the tokens, such as the
+// lab03 chat, do not need the role table.
+func (s *Service) subject(user *userdomain.User) jwtservice.Subject {
+	granted := s.permissions.Subject(&jwtservice.Claims{UserID: user.ID, Roles: user.Roles})
+	return jwtservice.Subject{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Roles:       user.Roles,
+		Permissions: granted.Permissions,
+	}
+}
+
+// Logout revokes every token issued from the same login as claims
+func (s *Service) Logout(ctx context.Context, claims *jwtservice.Claims) error {
+	if claims.FamilyID == "" {
+		return s.tokens.RevokeToken(ctx, claims)
+	}
+	return s.tokens.RevokeFamily(ctx, claims.FamilyID)
+}
+
+// JWKSHandler publishes the public keys used to verify access tokens
+func (s *Service) JWKSHandler() http.Handler {
+	return s.tokens.JWKSHandler()
+}
+
+// CurrentUser loads the user a token was issued to
+func (s *Service) CurrentUser(ctx context.Context, claims *jwtservice.Claims) (*userdomain.User, error) {
+	return s.users.GetByID(ctx, claims.UserID)
+}
+
+// User loads a user by ID
+func (s *Service) User(ctx context.Context, id int) (*userdomain.User, error) {
+	return s.users.GetByID(ctx, id)
+}
+
+// ValidateToken checks an access token, including whether it was revoked
+func (s *Service) ValidateToken(token string) (*jwtservice.Claims, error) {
+	return s.tokens.ValidateToken(token)
+}
+
+func newTokens(pair *jwtservice.TokenPair) *Tokens {
+	return &Tokens{
+		TokenPair: *pair,
+		TokenType: "Bearer",
+		ExpiresIn: int(time.Until(pair.AccessExpiresAt).Round(time.Second).Seconds()),
+	}
+}
@@ -0,0 +1,684 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/mail"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/oidc"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/lockout"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/security"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/totp"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+
+	service, err := NewService(NewMemoryUserStore(), Config{Tokens: jwtservice.Config{SecretKey: "test-secret"}})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	return service
+}
+
+func TestNewServiceRequiresSecret(t *testing.T) {
+	if _, err := NewService(NewMemoryUserStore(), Config{}); err == nil {
+		t.Error("Expected error for empty secret")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	user, err := service.Register(ctx, "  Alice@Example.com ", "Alice", "Password123")
+	if err != nil {
+		t.Fatalf("Expected registration to succeed, got %v", err)
+	}
+	if user.ID == 0 {
+		t.Error("Expected user ID to be set")
+	}
+	if user.Email != "alice@example.com" {
+		t.Errorf("Expected normalized email, got %q", user.Email)
+	}
+	if user.Password == "Password123" {
+		t.Error("Expected password to be stored hashed")
+	}
+
+	if _, err := service.Register(ctx, "alice@example.com", "Alice", "Password123"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken, got %v", err)
+	}
+	_, err = service.Register(ctx, "bob@example.com", "Bob", "weak")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput, got %v", err)
+	}
+	var violations security.ValidationErrors
+	if !errors.As(err, &violations) || len(violations) != 3 {
+		t.Errorf("Expected every violated password rule, got %v", err)
+	}
+
+	if _, err := service.Register(ctx, "carol@example.com", "Carol", "Carol2024x"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected password containing the name to be rejected, got %v", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := service.Register(ctx, "alice@example.com", "Alice", "Password123"); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	tokens, err := service.Login(ctx, "ALICE@example.com", "Password123", "")
+	if err != nil {
+		t.Fatalf("Expected login to succeed, got %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.TokenType != "Bearer" {
+		t.Errorf("Expected bearer token, got %+v", tokens)
+	}
+
+	claims, err := service.ValidateToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Expected issued token to validate, got %v", err)
+	}
+	if claims.UserID != tokens.User.ID {
+		t.Errorf("Expected user ID %d, got %d", tokens.User.ID, claims.UserID)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != RoleUser {
+		t.Errorf("Expected roles [%s], got %v", RoleUser, claims.Roles)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{"wrong password", "alice@example.com", "Password124"},
+		{"unknown user", "bob@example.com", "Password123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Login(ctx, tt.email, tt.password, ""); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginRehashesOutdatedPasswords(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryUserStore()
+
+	oldHasher, _ := security.NewPasswordServiceWithConfig(security.HashConfig{Algorithm: security.AlgorithmBcrypt, BcryptCost: 4})
+	oldService, err := NewService(users, Config{
+		Tokens:    jwtservice.Config{SecretKey: "test-secret"},
+		Passwords: oldHasher,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	user, err := oldService.Register(ctx, "alice@example.com", "Alice", "Password123")
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	oldHash := user.Password
+
+	newHasher, _ := security.NewPasswordServiceWithConfig(security.HashConfig{
+		Algorithm: security.AlgorithmArgon2id,
+		Argon2:    security.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1},
+	})
+	service, _ := NewService(users, Config{
+		Tokens:    jwtservice.Config{SecretKey: "test-secret"},
+		Passwords: newHasher,
+	})
+
+	if _, err := service.Login(ctx, "alice@example.com", "wrong", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	if stored, _ := users.GetByID(ctx, user.ID); stored.Password != oldHash {
+		t.Error("Expected failed login to leave the hash unchanged")
+	}
+
+	if _, err := service.Login(ctx, "alice@example.com", "Password123", ""); err != nil {
+		t.Fatalf("Expected login with bcrypt hash to succeed, got %v", err)
+	}
+	stored, _ := users.GetByID(ctx, user.ID)
+	if !strings.HasPrefix(stored.Password, "$argon2id$") {
+		t.Errorf("Expected hash to be upgraded to argon2id, got %q", stored.Password)
+	}
+	if _, err := service.Login(ctx, "alice@example.com", "Password123", ""); err != nil {
+		t.Errorf("Expected login with upgraded hash to succeed, got %v", err)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	service, err := NewService(NewMemoryUserStore(), Config{
+		Tokens:  jwtservice.Config{SecretKey: "test-secret"},
+		Lockout: lockout.New(lockout.Config{MaxAccountFailures: 2, FreeFailures: 5}),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	user, _ := service.Register(ctx, "alice@example.com", "Alice", "Password123")
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.Login(ctx, "alice@example.com", "wrong", "10.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+		}
+	}
+
+	// The correct password is not even checked while locked
+	if _, err := service.Login(ctx, "Alice@Example.com", "Password123", "10.0.0.2"); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("Expected ErrLocked, got %v", err)
+	}
+	status, err := service.LockoutStatus(ctx, user.ID)
+	if err != nil || status.LockedUntil.IsZero() {
+		t.Errorf("Expected lockout status, got %+v, %v", status, err)
+	}
+
+	if err := service.UnlockUser(ctx, user.ID, "admin@example.com"); err != nil {
+		t.Fatalf("Expected unlock to succeed, got %v", err)
+	}
+	if _, err := service.Login(ctx, "alice@example.com", "Password123", "10.0.0.2"); err != nil {
+		t.Errorf("Expected login after unlock to succeed, got %v", err)
+	}
+	if err := service.UnlockUser(ctx, user.ID+1, "admin@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := service.Register(ctx, "alice@example.com", "Alice", "Password123"); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	tokens, err := service.Login(ctx, "alice@example.com", "Password123", "")
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	refreshed, err := service.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Expected refresh to succeed, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, tokens.AccessToken); !errors.Is(err, jwtservice.ErrWrongTokenType) {
+		t.Errorf("Expected access token to be refused for refresh, got %v", err)
+	}
+
+	claims, err := service.ValidateToken(refreshed.AccessToken)
+	if err != nil {
+		t.Fatalf("Expected refreshed token to validate, got %v", err)
+	}
+
+	if err := service.Logout(ctx, claims); err != nil {
+		t.Fatalf("Expected logout to succeed, got %v", err)
+	}
+	if _, err := service.ValidateToken(refreshed.AccessToken); !errors.Is(err, jwtservice.ErrTokenRevoked) {
+		t.Errorf("Expected access token to be revoked after logout, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, refreshed.RefreshToken); !errors.Is(err, jwtservice.ErrTokenRevoked) {
+		t.Errorf("Expected refresh token to be revoked after logout, got %v", err)
+	}
+}
+
+func TestRefreshUsesCurrentRoles(t *testing.T) {
+	store := NewMemoryUserStore()
+	service, err := NewService(store, Config{Tokens: jwtservice.Config{SecretKey: "test-secret"}})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	ctx := context.Background()
+
+	user, err := service.Register(ctx, "alice@example.com", "Alice", "Password123")
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	tokens, err := service.Login(ctx, "alice@example.com", "Password123", "")
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	// Promote Alice after she logged in
+	store.mu.Lock()
+	stored := store.users[user.ID]
+	stored.Roles = []string{RoleEditor}
+	store.users[user.ID] = stored
+	store.mu.Unlock()
+
+	refreshed, err := service.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Expected refresh to succeed, got %v", err)
+	}
+	claims, err := service.ValidateToken(refreshed.AccessToken)
+	if err != nil {
+		t.Fatalf("Expected refreshed token to validate, got %v", err)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != RoleEditor {
+		t.Errorf("Expected roles [%s] after refresh, got %v", RoleEditor, claims.Roles)
+	}
+	// Services without the role table rely on the permissions in the token
+	if !slices.Contains(claims.Permissions, PermMessagesModerate) {
+		t.Errorf("Expected %s among the permissions, got %v", PermMessagesModerate, claims.Permissions)
+	}
+}
+
+// recordingMailer keeps sent messages in memory
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+// lastToken returns the token in the link of the last message sent to "to"
+func (m *recordingMailer) lastToken(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To != to {
+			continue
+		}
+		link, err := url.Parse(linkPattern.FindString(m.sent[i].Body))
+		if err != nil || link.Query().Get("token") == "" {
+			t.Fatalf("Expected a link with a token in %q", m.sent[i].Body)
+		}
+		return link.Query().Get("token")
+	}
+	t.Fatalf("Expected a message to %s", to)
+	return ""
+}
+
+func newMailingService(t *testing.T) (*Service, *recordingMailer) {
+	t.Helper()
+
+	mailer := &recordingMailer{}
+	service, err := NewService(NewMemoryUserStore(), Config{
+		Tokens: jwtservice.Config{SecretKey: "test-secret"},
+		Mailer: mailer,
+		AppURL: "https://app.example.com/",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	return service, mailer
+}
+
+func TestEmailVerification(t *testing.T) {
+	service, mailer := newMailingService(t)
+	ctx := context.Background()
+
+	user, err := service.Register(ctx, "alice@example.com", "Alice", "Password123")
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if user.EmailVerified {
+		t.Error("Expected new user to be unverified")
+	}
+	first := mailer.lastToken(t, "alice@example.com")
+	if !strings.Contains(mailer.sent[0].Body, "https://app.example.com/verify-email?token=") {
+		t.Errorf("Expected verification link in %q", mailer.sent[0].Body)
+	}
+
+	if err := service.RequestEmailVerification(ctx, user.ID); err != nil {
+		t.Fatalf("Expected new verification email, got %v", err)
+	}
+	second := mailer.lastToken(t, "alice@example.com")
+
+	if _, err := service.ConfirmEmail(ctx, "bogus"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+	verified, err := service.ConfirmEmail(ctx, second)
+	if err != nil {
+		t.Fatalf("Expected confirmation to succeed, got %v", err)
+	}
+	if !verified.EmailVerified {
+		t.Error("Expected email to be verified")
+	}
+	if _, err := service.ConfirmEmail(ctx, first); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected older links to be invalidated, got %v", err)
+	}
+	if err := service.RequestEmailVerification(ctx, user.ID); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Errorf("Expected ErrEmailAlreadyVerified, got %v", err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	service, mailer := newMailingService(t)
+	ctx := context.Background()
+
+	if _, err := service.Register(ctx, "alice@example.com", "Alice", "Password123"); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	session, err := service.Login(ctx, "alice@example.com", "Password123", "")
+	if err != nil {
+		t.Fatalf("Failed to login: %v", err)
+	}
+
+	// Unknown addresses look the same to the caller but send nothing
+	sent := len(mailer.sent)
+	if err := service.RequestPasswordReset(ctx, "nobody@example.com"); err != nil {
+		t.Errorf("Expected no error for an unknown email, got %v", err)
+	}
+	if len(mailer.sent) != sent {
+		t.Error("Expected no email for an unknown address")
+	}
+
+	if err := service.RequestPasswordReset(ctx, "Alice@Example.com"); err != nil {
+		t.Fatalf("Expected reset request to succeed, got %v", err)
+	}
+	token := mailer.lastToken(t, "alice@example.com")
+
+	// A rejected password does not use up the token
+	if err := service.ResetPassword(ctx, token, "short"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput, got %v", err)
+	}
+	if err := service.ResetPassword(ctx, token, "NewPassword456"); err != nil {
+		t.Fatalf("Expected reset to succeed, got %v", err)
+	}
+	if err := service.ResetPassword(ctx, token, "OtherPassword789"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the token to be single-use, got %v", err)
+	}
+
+	if _, err := service.ValidateToken(session.AccessToken); !errors.Is(err, jwtservice.ErrTokenRevoked) {
+		t.Errorf("Expected old access token to be revoked, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, session.RefreshToken); !errors.Is(err, jwtservice.ErrTokenRevoked) {
+		t.Errorf("Expected old refresh token to be revoked, got %v", err)
+	}
+	if _, err := service.Login(ctx, "alice@example.com", "Password123", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected old password to be rejected, got %v", err)
+	}
+	tokens, err := service.Login(ctx, "alice@example.com", "NewPassword456", "")
+	if err != nil {
+		t.Fatalf("Expected login with new password to succeed, got %v", err)
+	}
+	if !tokens.User.EmailVerified {
+		t.Error("Expected a password reset to verify the email")
+	}
+	if _, err := service.ValidateToken(tokens.AccessToken); err != nil {
+		t.Errorf("Expected new session to be valid, got %v", err)
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	generator := totp.New(totp.Config{})
+
+	user, _ := service.Register(ctx, "alice@example.com", "Alice", "Password123")
+	enrollment, err := service.BeginMFA(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Expected enrollment to start, got %v", err)
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || !strings.Contains(enrollment.URI, enrollment.Secret) {
+		t.Errorf("Unexpected otpauth URI %q", enrollment.URI)
+	}
+
+	// Until confirmed the password alone still logs in
+	if _, err := service.Login(ctx, "alice@example.com", "Password123", ""); err != nil {
+		t.Fatalf("Expected login before confirmation to succeed, got %v", err)
+	}
+	if _, err := service.ConfirmMFA(ctx, user.ID, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected ErrInvalidMFACode, got %v", err)
+	}
+
+	// Confirm with the previous period's code so the current one stays
+	// usable for the login below
+	previous, _ := generator.Code(enrollment.Secret, time.Now().Add(-30*time.Second))
+	recovery, err := service.ConfirmMFA(ctx, user.ID, previous)
+	if err != nil {
+		t.Fatalf("Expected confirmation to succeed, got %v", err)
+	}
+	if len(recovery) != totp.DefaultRecoveryCodes {
+		t.Errorf("Expected %d recovery codes, got %d", totp.DefaultRecoveryCodes, len(recovery))
+	}
+	if _, err := service.BeginMFA(ctx, user.ID); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Errorf("Expected ErrMFAAlreadyEnabled, got %v", err)
+	}
+
+	login := func() string {
+		t.Helper()
+		_, err := service.Login(ctx, "alice@example.com", "Password123", "")
+		var required *MFARequiredError
+		if !errors.As(err, &required) {
+			t.Fatalf("Expected *MFARequiredError, got %v", err)
+		}
+		return required.Token
+	}
+
+	pending := login()
+	if _, err := service.ValidateToken(pending); err == nil {
+		t.Error("Expected the mfa token to be refused as an access token")
+	}
+	if _, err := service.VerifyMFA(ctx, pending, "000000", ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected ErrInvalidMFACode, got %v", err)
+	}
+
+	code, _ := generator.Code(enrollment.Secret, time.Now())
+	tokens, err := service.VerifyMFA(ctx, pending, code, "")
+	if err != nil {
+		t.Fatalf("Expected verification to succeed, got %v", err)
+	}
+	if _, err := service.ValidateToken(tokens.AccessToken); err != nil {
+		t.Errorf("Expected access token to be valid, got %v", err)
+	}
+	if _, err := service.VerifyMFA(ctx, pending, code, ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected a used code to be rejected, got %v", err)
+	}
+
+	// Recovery codes work once each
+	pending = login()
+	if _, err := service.VerifyMFA(ctx, pending, strings.ToUpper(recovery[0]), ""); err != nil {
+		t.Fatalf("Expected recovery code to be accepted, got %v", err)
+	}
+	pending = login()
+	if _, err := service.VerifyMFA(ctx, pending, recovery[0], ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Expected recovery code to be single-use, got %v", err)
+	}
+	if status, _ := service.MFAStatus(ctx, user.ID); !status.Enabled || status.RecoveryCodesLeft != totp.DefaultRecoveryCodes-1 {
+		t.Errorf("Unexpected MFA status %+v", status)
+	}
+
+	if err := service.DisableMFA(ctx, user.ID, recovery[1]); err != nil {
+		t.Fatalf("Expected disabling to succeed, got %v", err)
+	}
+	if _, err := service.VerifyMFA(ctx, pending, recovery[2], ""); !errors.Is(err, jwtservice.ErrTokenRevoked) {
+		t.Errorf("Expected pending login to be void after disabling, got %v", err)
+	}
+	if _, err := service.Login(ctx, "alice@example.com", "Password123", ""); err != nil {
+		t.Errorf("Expected password login after disabling, got %v", err)
+	}
+}
+
+func TestTwoFactorLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	service, err := NewService(NewMemoryUserStore(), Config{
+		Tokens:  jwtservice.Config{SecretKey: "test-secret"},
+		Lockout: lockout.New(lockout.Config{MaxAccountFailures: 3, FreeFailures: 5}),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	user, _ := service.Register(ctx, "alice@example.com", "Alice", "Password123")
+	enrollment, _ := service.BeginMFA(ctx, user.ID)
+	code, _ := totp.New(totp.Config{}).Code(enrollment.Secret, time.Now())
+	if _, err := service.ConfirmMFA(ctx, user.ID, code); err != nil {
+		t.Fatalf("Failed to enable MFA: %v", err)
+	}
+
+	_, err = service.Login(ctx, "alice@example.com", "Password123", "")
+	var required *MFARequiredError
+	if !errors.As(err, &required) {
+		t.Fatalf("Expected *MFARequiredError, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := service.VerifyMFA(ctx, required.Token, "000000", ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("Expected ErrInvalidMFACode, got %v", err)
+		}
+	}
+	if _, err := service.VerifyMFA(ctx, required.Token, "000000", ""); !errors.Is(err, lockout.ErrLocked) {
+		t.Errorf("Expected guessing codes to lock the account, got %v", err)
+	}
+}
+
+func TestTwoFactorPasswordStepKeepsFailures(t *testing.T) {
+	ctx := context.Background()
+	service, err := NewService(NewMemoryUserStore(), Config{
+		Tokens:  jwtservice.Config{SecretKey: "test-secret"},
+		Lockout: lockout.New(lockout.Config{MaxAccountFailures: 3, FreeFailures: 5}),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	user, _ := service.Register(ctx, "alice@example.com", "Alice", "Password123")
+	enrollment, _ := service.BeginMFA(ctx, user.ID)
+	code, _ := totp.New(totp.Config{}).Code(enrollment.Secret, time.Now())
+	if _, err := service.ConfirmMFA(ctx, user.ID, code); err != nil {
+		t.Fatalf("Failed to enable MFA: %v", err)
+	}
+
+	// Logging in with the password again between guesses must not reset
+	// the count of wrong codes
+	for i := 0; i < 3; i++ {
+		_, err := service.Login(ctx, "alice@example.com", "Password123", "")
+		var required *MFARequiredError
+		if !errors.As(err, &required) {
+			t.Fatalf("Expected *MFARequiredError, got %v", err)
+		}
+		if _, err := service.VerifyMFA(ctx, required.Token, "000000", ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("Expected ErrInvalidMFACode, got %v", err)
+		}
+	}
+	if _, err := service.Login(ctx, "alice@example.com", "Password123", ""); !errors.Is(err, lockout.ErrLocked) {
+		t.Errorf("Expected the account to be locked, got %v", err)
+	}
+}
+
+func TestLoginWithIdentity(t *testing.T) {
+	service, mailer := newMailingService(t)
+	ctx := context.Background()
+	const issuer = "https://idp.example.com"
+
+	// A new provider account creates a verified user
+	tokens, err := service.LoginWithIdentity(ctx, &oidc.Identity{
+		Issuer: issuer, Subject: "sub-alice", Email: "Alice@Example.com", EmailVerified: true, Name: "Alice",
+	})
+	if err != nil {
+		t.Fatalf("Expected login to succeed, got %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.User.Email != "alice@example.com" || !tokens.User.EmailVerified {
+		t.Errorf("Unexpected tokens for new user: %+v", tokens)
+	}
+	if _, err := service.Login(ctx, "alice@example.com", "", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected created user to have no usable password, got %v", err)
+	}
+
+	// The link is by subject, so a changed email still finds the user
+	again, err := service.LoginWithIdentity(ctx, &oidc.Identity{Issuer: issuer, Subject: "sub-alice", Email: "alice@new.example.com"})
+	if err != nil || again.User.ID != tokens.User.ID {
+		t.Errorf("Expected linked user %d, got %+v, %v", tokens.User.ID, again, err)
+	}
+
+	if _, err := service.LoginWithIdentity(ctx, &oidc.Identity{Issuer: issuer, Subject: "sub-mallory", Email: "bob@example.com"}); !errors.Is(err, ErrUnverifiedIdentity) {
+		t.Errorf("Expected ErrUnverifiedIdentity, got %v", err)
+	}
+
+	// An existing user is linked only once their own email is verified
+	bob, _ := service.Register(ctx, "bob@example.com", "Bob", "Password123")
+	bobIdentity := &oidc.Identity{Issuer: issuer, Subject: "sub-bob", Email: "bob@example.com", EmailVerified: true}
+	if _, err := service.LoginWithIdentity(ctx, bobIdentity); !errors.Is(err, ErrAccountLinkConflict) {
+		t.Errorf("Expected ErrAccountLinkConflict, got %v", err)
+	}
+	if _, err := service.ConfirmEmail(ctx, mailer.lastToken(t, "bob@example.com")); err != nil {
+		t.Fatalf("Expected email confirmation to succeed, got %v", err)
+	}
+	linked, err := service.LoginWithIdentity(ctx, bobIdentity)
+	if err != nil || linked.User.ID != bob.ID {
+		t.Errorf("Expected login as existing user %d, got %+v, %v", bob.ID, linked, err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	users := NewMemoryUserStore()
+	service, err := NewService(users, Config{Tokens: jwtservice.Config{SecretKey: "test-secret"}})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	ctx := context.Background()
+
+	user, _ := service.Register(ctx, "alice@example.com", "Alice", "Password123")
+	if _, err := service.CreateAPIKey(ctx, user.ID, "ci", []string{PermUsersRead}, time.Time{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected scopes beyond the user's permissions to be rejected, got %v", err)
+	}
+	if _, err := service.CreateAPIKey(ctx, user.ID, "ci", nil, time.Time{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected a key without scopes to be rejected, got %v", err)
+	}
+	if _, err := service.CreateAPIKey(ctx, user.ID, "ci", []string{PermPostsRead}, time.Now().Add(-time.Minute)); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected a key expiring in the past to be rejected, got %v", err)
+	}
+
+	created, err := service.CreateAPIKey(ctx, user.ID, "ci", []string{PermPostsRead}, time.Time{})
+	if err != nil {
+		t.Fatalf("Expected key creation to succeed, got %v", err)
+	}
+	if !strings.HasPrefix(created.Key, created.Prefix+"_") || created.Hash == created.Key {
+		t.Errorf("Expected key %q to start with prefix %q and be stored hashed", created.Key, created.Prefix)
+	}
+
+	claims, err := service.ValidateAPIKey(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("Expected key to validate, got %v", err)
+	}
+	if claims.UserID != user.ID || claims.Email != user.Email || claims.TokenType != TokenTypeAPIKey {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if !NewPolicy().Subject(claims).Can(PermPostsRead) || NewPolicy().Subject(claims).Can(PermPostsWrite) {
+		t.Errorf("Expected the key to grant exactly its scopes, got %v roles %v", claims.Permissions, claims.Roles)
+	}
+	keys, _ := service.APIKeys(ctx, user.ID)
+	if len(keys) != 1 || keys[0].LastUsedAt.IsZero() {
+		t.Errorf("Expected the key's last use to be recorded, got %+v", keys)
+	}
+
+	for _, bad := range []string{"", "ck_", created.Prefix + "_WRONG", strings.TrimPrefix(created.Key, APIKeyPrefix)} {
+		if _, err := service.ValidateAPIKey(ctx, bad); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("ValidateAPIKey(%q) error = %v, want ErrInvalidAPIKey", bad, err)
+		}
+	}
+
+	// Scopes the owner lost are dropped from the key
+	demoted := users.users[user.ID]
+	demoted.Roles = nil
+	users.users[user.ID] = demoted
+	if claims, _ := service.ValidateAPIKey(ctx, created.Key); len(claims.Permissions) != 0 {
+		t.Errorf("Expected no permissions after demotion, got %v", claims.Permissions)
+	}
+
+	if err := service.RevokeAPIKey(ctx, user.ID, created.ID); err != nil {
+		t.Fatalf("Expected revocation to succeed, got %v", err)
+	}
+	if _, err := service.ValidateAPIKey(ctx, created.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected revoked key to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,467 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/userdomain"
+)
+
+// SQLUserStore is a UserStore backed by the users table
+type SQLUserStore struct {
+	db *sql.DB
+}
+
+// NewSQLUserStore creates a SQLUserStore. The schema is managed by the
+// migrations in backend/migrations.
+func NewSQLUserStore(db *sql.DB) *SQLUserStore {
+	return &SQLUserStore{db: db}
+}
+
+const userColumns = "id, email, name, password_hash, roles, email_verified, created_at, updated_at"
+
+// Create implements UserStore
+func (s *SQLUserStore) Create(ctx context.Context, user *userdomain.User) error {
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO users (email, name, password_hash, roles, email_verified, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		user.Email, user.Name, user.Password, joinRoles(user.Roles), user.EmailVerified, user.CreatedAt, user.UpdatedAt,
+	).Scan(&user.ID)
+	if isUniqueViolation(err) {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// GetByEmail implements UserStore
+func (s *SQLUserStore) GetByEmail(ctx context.Context, email string) (*userdomain.User, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE email = $1", email)
+	return scanUser(row)
+}
+
+// GetByID implements UserStore
+func (s *SQLUserStore) GetByID(ctx context.Context, id int) (*userdomain.User, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+	return scanUser(row)
+}
+
+// UpdatePassword implements UserStore
+func (s *SQLUserStore) UpdatePassword(ctx context.Context, id int, hash string) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE users SET password_hash = $1, updated_at = $2 WHERE id = $3",
+		hash, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SetEmailVerified implements UserStore
+func (s *SQLUserStore) SetEmailVerified(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE users SET email_verified = TRUE, updated_at = $1 WHERE id = $2",
+		time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func scanUser(row *sql.Row) (*userdomain.User, error) {
+	var user userdomain.User
+	var roles string
+	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Password, &roles, &user.EmailVerified, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	user.Roles = splitRoles(roles)
+	return &user, nil
+}
+
+// SQLTokenStore is a TokenStore backed by the user_tokens table
+type SQLTokenStore struct {
+	db *sql.DB
+}
+
+// NewSQLTokenStore creates a SQLTokenStore. The schema is managed by the
+// migrations in backend/migrations.
+func NewSQLTokenStore(db *sql.DB) *SQLTokenStore {
+	return &SQLTokenStore{db: db}
+}
+
+// Create implements TokenStore
+func (s *SQLTokenStore) Create(ctx context.Context, token OneTimeToken) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		token.Hash, token.UserID, token.Purpose, token.ExpiresAt.UTC(), time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+	return nil
+}
+
+// Lookup implements TokenStore
+func (s *SQLTokenStore) Lookup(ctx context.Context, purpose, hash string, now time.Time) (int, error) {
+	var userID int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3`,
+		hash, purpose, now.UTC(),
+	).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up token: %w", err)
+	}
+	return userID, nil
+}
+
+// Consume implements TokenStore. The conditional update lets only one of
+// several concurrent requests redeem a token.
+func (s *SQLTokenStore) Consume(ctx context.Context, purpose, hash string, now time.Time) (int, error) {
+	var userID int
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE user_tokens SET used_at = $1
+		WHERE token_hash = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id`,
+		now.UTC(), hash, purpose,
+	).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume token: %w", err)
+	}
+	return userID, nil
+}
+
+// DeleteForUser implements TokenStore
+func (s *SQLTokenStore) DeleteForUser(ctx context.Context, userID int, purpose string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2", userID, purpose)
+	if err != nil {
+		return fmt.Errorf("failed to delete tokens: %w", err)
+	}
+	return nil
+}
+
+// Purge deletes expired tokens. Call it periodically to keep the table small.
+func (s *SQLTokenStore) Purge(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM user_tokens WHERE expires_at <= $1", time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to purge tokens: %w", err)
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint failure from
+// either supported driver
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
+
+// SQLMFAStore is an MFAStore backed by the user_mfa and mfa_recovery_codes
+// tables
+type SQLMFAStore struct {
+	db *sql.DB
+}
+
+// NewSQLMFAStore creates a SQLMFAStore. The schema is managed by the
+// migrations in backend/migrations.
+func NewSQLMFAStore(db *sql.DB) *SQLMFAStore {
+	return &SQLMFAStore{db: db}
+}
+
+// Get implements MFAStore
+func (s *SQLMFAStore) Get(ctx context.Context, userID int) (*MFA, error) {
+	mfa := MFA{UserID: userID}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT secret, enabled, last_step,
+			(SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL)
+		FROM user_mfa WHERE user_id = $1`,
+		userID,
+	).Scan(&mfa.Secret, &mfa.Enabled, &mfa.LastStep, &mfa.RecoveryCodesLeft)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mfa: %w", err)
+	}
+	return &mfa, nil
+}
+
+// Begin implements MFAStore
+func (s *SQLMFAStore) Begin(ctx context.Context, userID int, secret string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO user_mfa (user_id, secret, enabled, last_step, created_at, updated_at)
+			VALUES ($1, $2, FALSE, 0, $3, $3)
+			ON CONFLICT (user_id) DO UPDATE SET
+				secret = excluded.secret, enabled = FALSE, last_step = 0, updated_at = excluded.updated_at`,
+			userID, secret, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store mfa secret: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		return nil
+	})
+}
+
+// Enable implements MFAStore
+func (s *SQLMFAStore) Enable(ctx context.Context, userID int, step int64, recoveryHashes []string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			"UPDATE user_mfa SET enabled = TRUE, last_step = $1, updated_at = $2 WHERE user_id = $3",
+			step, time.Now().UTC(), userID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to enable mfa: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return ErrMFANotEnrolled
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		for _, hash := range recoveryHashes {
+			_, err := tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash)
+			if err != nil {
+				return fmt.Errorf("failed to store recovery code: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// UseStep implements MFAStore. The condition on last_step makes concurrent
+// logins with the same code race for a single row update.
+func (s *SQLMFAStore) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE user_mfa SET last_step = $1, updated_at = $2 WHERE user_id = $3 AND last_step < $1",
+		step, time.Now().UTC(), userID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp step: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp step: %w", err)
+	}
+	return n == 1, nil
+}
+
+// UseRecoveryCode implements MFAStore
+func (s *SQLMFAStore) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE mfa_recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL",
+		time.Now().UTC(), userID, hash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return n == 1, nil
+}
+
+// Delete implements MFAStore
+func (s *SQLMFAStore) Delete(ctx context.Context, userID int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM user_mfa WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to delete mfa: %w", err)
+		}
+		return nil
+	})
+}
+
+// inTx runs fn in a transaction that is committed if fn succeeds
+func (s *SQLMFAStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SQLIdentityStore is an IdentityStore backed by the user_identities table
+type SQLIdentityStore struct {
+	db *sql.DB
+}
+
+// NewSQLIdentityStore creates a SQLIdentityStore. The schema is managed by
+// the migrations in backend/migrations.
+func NewSQLIdentityStore(db *sql.DB) *SQLIdentityStore {
+	return &SQLIdentityStore{db: db}
+}
+
+// FindUser implements IdentityStore
+func (s *SQLIdentityStore) FindUser(ctx context.Context, issuer, subject string) (int, error) {
+	var userID int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2", issuer, subject,
+	).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load identity: %w", err)
+	}
+	return userID, nil
+}
+
+// Link implements IdentityStore
+func (s *SQLIdentityStore) Link(ctx context.Context, issuer, subject string, userID int) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_identities (issuer, subject, user_id, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO UPDATE SET user_id = excluded.user_id`,
+		issuer, subject, userID, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
+
+// SQLAPIKeyStore is an APIKeyStore backed by the api_keys table
+type SQLAPIKeyStore struct {
+	db *sql.DB
+}
+
+// NewSQLAPIKeyStore creates a SQLAPIKeyStore. The schema is managed by the
+// migrations in backend/migrations.
+func NewSQLAPIKeyStore(db *sql.DB) *SQLAPIKeyStore {
+	return &SQLAPIKeyStore{db: db}
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at"
+
+// Create implements APIKeyStore
+func (s *SQLAPIKeyStore) Create(ctx context.Context, key *APIKey) error {
+	// Scopes are stored comma-separated like roles
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		key.UserID, key.Name, key.Prefix, key.Hash, joinRoles(key.Scopes), nullTime(key.ExpiresAt), key.CreatedAt.UTC(),
+	).Scan(&key.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+// GetByPrefix implements APIKeyStore
+func (s *SQLAPIKeyStore) GetByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = $1", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load api key: %w", err)
+	}
+	keys, err := scanAPIKeys(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrAPIKeyNotFound
+	}
+	return keys[0], nil
+}
+
+// ListForUser implements APIKeyStore
+func (s *SQLAPIKeyStore) ListForUser(ctx context.Context, userID int) ([]*APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return scanAPIKeys(rows)
+}
+
+// Delete implements APIKeyStore
+func (s *SQLAPIKeyStore) Delete(ctx context.Context, userID, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM api_keys WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete api key: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Touch implements APIKeyStore
+func (s *SQLAPIKeyStore) Touch(ctx context.Context, id int, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $1 WHERE id = $2", at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to record api key use: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKeys(rows *sql.Rows) ([]*APIKey, error) {
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		var key APIKey
+		var scopes string
+		var expiresAt, lastUsedAt sql.NullTime
+		err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &scopes, &expiresAt, &lastUsedAt, &key.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load api key: %w", err)
+		}
+		key.Scopes = splitRoles(scopes)
+		key.ExpiresAt = expiresAt.Time
+		key.LastUsedAt = lastUsedAt.Time
+		keys = append(keys, &key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
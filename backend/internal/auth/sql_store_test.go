@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/database"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/userdomain"
+)
+
+func newTestSQLStore(t *testing.T) *SQLUserStore {
+	t.Helper()
+	return NewSQLUserStore(newTestDB(t))
+}
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, dialect, err := database.Open("sqlite://:memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	// The tables come from the migrations the backend ships with
+	migrator, err := database.NewMigrator(db, dialect, "../../migrations")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	return db
+}
+
+func TestSQLUserStore(t *testing.T) {
+	store := newTestSQLStore(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	user := &userdomain.User{
+		Email:     "alice@example.com",
+		Name:      "Alice",
+		Password:  "hash",
+		Roles:     []string{RoleUser, RoleEditor},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := store.Create(ctx, user); err != nil {
+		t.Fatalf("Expected create to succeed, got %v", err)
+	}
+	if user.ID == 0 {
+		t.Fatal("Expected user ID to be set")
+	}
+
+	duplicate := *user
+	if err := store.Create(ctx, &duplicate); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken, got %v", err)
+	}
+
+	byEmail, err := store.GetByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("Expected GetByEmail to succeed, got %v", err)
+	}
+	if byEmail.ID != user.ID || byEmail.Password != "hash" || !byEmail.CreatedAt.Equal(now) {
+		t.Errorf("Expected %+v, got %+v", user, byEmail)
+	}
+
+	byID, err := store.GetByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Expected GetByID to succeed, got %v", err)
+	}
+	if byID.Email != user.Email {
+		t.Errorf("Expected email %q, got %q", user.Email, byID.Email)
+	}
+	if len(byID.Roles) != 2 || byID.Roles[0] != RoleUser || byID.Roles[1] != RoleEditor {
+		t.Errorf("Expected roles %v, got %v", user.Roles, byID.Roles)
+	}
+
+	if err := store.UpdatePassword(ctx, user.ID, "new-hash"); err != nil {
+		t.Fatalf("Expected UpdatePassword to succeed, got %v", err)
+	}
+	if updated, _ := store.GetByID(ctx, user.ID); updated.Password != "new-hash" {
+		t.Errorf("Expected updated hash, got %q", updated.Password)
+	}
+	if err := store.UpdatePassword(ctx, user.ID+1, "hash"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	if byID.EmailVerified {
+		t.Error("Expected new user to be unverified")
+	}
+	if err := store.SetEmailVerified(ctx, user.ID); err != nil {
+		t.Fatalf("Expected SetEmailVerified to succeed, got %v", err)
+	}
+	if updated, _ := store.GetByID(ctx, user.ID); !updated.EmailVerified {
+		t.Error("Expected email to be verified")
+	}
+
+	if _, err := store.GetByID(ctx, user.ID+1); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := store.GetByEmail(ctx, "bob@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestTokenStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) TokenStore{
+		"memory": func(t *testing.T) TokenStore { return NewMemoryTokenStore() },
+		"sql": func(t *testing.T) TokenStore {
+			db := newTestDB(t)
+			users := NewSQLUserStore(db)
+			for _, email := range []string{"alice@example.com", "bob@example.com"} {
+				now := time.Now()
+				users.Create(context.Background(), &userdomain.User{Email: email, Name: "Test", Password: "hash", CreatedAt: now, UpdatedAt: now})
+			}
+			return NewSQLTokenStore(db)
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			now := time.Now()
+
+			for _, token := range []OneTimeToken{
+				{Hash: "valid", UserID: 1, Purpose: PurposeResetPassword, ExpiresAt: now.Add(time.Hour)},
+				{Hash: "second", UserID: 1, Purpose: PurposeResetPassword, ExpiresAt: now.Add(time.Hour)},
+				{Hash: "expired", UserID: 1, Purpose: PurposeResetPassword, ExpiresAt: now.Add(-time.Second)},
+				{Hash: "other", UserID: 2, Purpose: PurposeResetPassword, ExpiresAt: now.Add(time.Hour)},
+			} {
+				if err := store.Create(ctx, token); err != nil {
+					t.Fatalf("Expected Create to succeed, got %v", err)
+				}
+			}
+
+			if id, err := store.Lookup(ctx, PurposeResetPassword, "valid", now); err != nil || id != 1 {
+				t.Errorf("Expected Lookup to return user 1, got %d, %v", id, err)
+			}
+			if _, err := store.Consume(ctx, PurposeVerifyEmail, "valid", now); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken for another purpose, got %v", err)
+			}
+			if _, err := store.Consume(ctx, PurposeResetPassword, "expired", now); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken for an expired token, got %v", err)
+			}
+			if id, err := store.Consume(ctx, PurposeResetPassword, "valid", now); err != nil || id != 1 {
+				t.Fatalf("Expected Consume to return user 1, got %d, %v", id, err)
+			}
+			if _, err := store.Consume(ctx, PurposeResetPassword, "valid", now); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken for a used token, got %v", err)
+			}
+
+			if err := store.DeleteForUser(ctx, 1, PurposeResetPassword); err != nil {
+				t.Fatalf("Expected DeleteForUser to succeed, got %v", err)
+			}
+			if _, err := store.Lookup(ctx, PurposeResetPassword, "second", now); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected deleted token to be invalid, got %v", err)
+			}
+			if _, err := store.Lookup(ctx, PurposeResetPassword, "other", now); err != nil {
+				t.Errorf("Expected tokens of other users to stay valid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMFAStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) MFAStore{
+		"memory": func(t *testing.T) MFAStore { return NewMemoryMFAStore() },
+		"sql": func(t *testing.T) MFAStore {
+			db := newTestDB(t)
+			now := time.Now()
+			NewSQLUserStore(db).Create(context.Background(), &userdomain.User{Email: "alice@example.com", Name: "Alice", Password: "hash", CreatedAt: now, UpdatedAt: now})
+			return NewSQLMFAStore(db)
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+
+			if _, err := store.Get(ctx, 1); !errors.Is(err, ErrMFANotEnrolled) {
+				t.Errorf("Expected ErrMFANotEnrolled, got %v", err)
+			}
+			if err := store.Begin(ctx, 1, "SECRET"); err != nil {
+				t.Fatalf("Expected Begin to succeed, got %v", err)
+			}
+			if mfa, err := store.Get(ctx, 1); err != nil || mfa.Enabled || mfa.Secret != "SECRET" {
+				t.Errorf("Expected unconfirmed secret, got %+v, %v", mfa, err)
+			}
+
+			if err := store.Enable(ctx, 1, 100, []string{"code-a", "code-b"}); err != nil {
+				t.Fatalf("Expected Enable to succeed, got %v", err)
+			}
+			mfa, err := store.Get(ctx, 1)
+			if err != nil || !mfa.Enabled || mfa.LastStep != 100 || mfa.RecoveryCodesLeft != 2 {
+				t.Errorf("Expected enabled MFA with 2 codes, got %+v, %v", mfa, err)
+			}
+
+			for _, tt := range []struct {
+				step int64
+				want bool
+			}{{100, false}, {99, false}, {101, true}, {101, false}} {
+				if ok, err := store.UseStep(ctx, 1, tt.step); err != nil || ok != tt.want {
+					t.Errorf("UseStep(%d) = %v, %v, want %v", tt.step, ok, err, tt.want)
+				}
+			}
+
+			if ok, _ := store.UseRecoveryCode(ctx, 1, "code-a"); !ok {
+				t.Error("Expected recovery code to be accepted")
+			}
+			if ok, _ := store.UseRecoveryCode(ctx, 1, "code-a"); ok {
+				t.Error("Expected recovery code to be single-use")
+			}
+			if ok, _ := store.UseRecoveryCode(ctx, 1, "unknown"); ok {
+				t.Error("Expected unknown recovery code to be rejected")
+			}
+			if mfa, _ := store.Get(ctx, 1); mfa.RecoveryCodesLeft != 1 {
+				t.Errorf("Expected 1 recovery code left, got %d", mfa.RecoveryCodesLeft)
+			}
+
+			if err := store.Delete(ctx, 1); err != nil {
+				t.Fatalf("Expected Delete to succeed, got %v", err)
+			}
+			if _, err := store.Get(ctx, 1); !errors.Is(err, ErrMFANotEnrolled) {
+				t.Errorf("Expected ErrMFANotEnrolled after Delete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIdentityStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) IdentityStore{
+		"memory": func(t *testing.T) IdentityStore { return NewMemoryIdentityStore() },
+		"sql": func(t *testing.T) IdentityStore {
+			db := newTestDB(t)
+			users := NewSQLUserStore(db)
+			for _, email := range []string{"alice@example.com", "bob@example.com"} {
+				now := time.Now()
+				users.Create(context.Background(), &userdomain.User{Email: email, Name: "Test", Password: "hash", CreatedAt: now, UpdatedAt: now})
+			}
+			return NewSQLIdentityStore(db)
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+
+			if _, err := store.FindUser(ctx, "https://idp.example.com", "alice"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("Expected ErrUserNotFound, got %v", err)
+			}
+			if err := store.Link(ctx, "https://idp.example.com", "alice", 1); err != nil {
+				t.Fatalf("Expected Link to succeed, got %v", err)
+			}
+			if id, err := store.FindUser(ctx, "https://idp.example.com", "alice"); err != nil || id != 1 {
+				t.Errorf("Expected user 1, got %d, %v", id, err)
+			}
+			if _, err := store.FindUser(ctx, "https://other.example.com", "alice"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("Expected subjects to be scoped by issuer, got %v", err)
+			}
+
+			if err := store.Link(ctx, "https://idp.example.com", "alice", 2); err != nil {
+				t.Fatalf("Expected relinking to succeed, got %v", err)
+			}
+			if id, _ := store.FindUser(ctx, "https://idp.example.com", "alice"); id != 2 {
+				t.Errorf("Expected relinked user 2, got %d", id)
+			}
+		})
+	}
+}
+
+func TestAPIKeyStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) APIKeyStore{
+		"memory": func(t *testing.T) APIKeyStore { return NewMemoryAPIKeyStore() },
+		"sql": func(t *testing.T) APIKeyStore {
+			db := newTestDB(t)
+			users := NewSQLUserStore(db)
+			for _, email := range []string{"alice@example.com", "bob@example.com"} {
+				now := time.Now()
+				users.Create(context.Background(), &userdomain.User{Email: email, Name: "Test", Password: "hash", CreatedAt: now, UpdatedAt: now})
+			}
+			return NewSQLAPIKeyStore(db)
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+
+			first := &APIKey{UserID: 1, Name: "ci", Prefix: "ck_first", Hash: "h1", Scopes: []string{"posts:read", "users:read"}, CreatedAt: now}
+			second := &APIKey{UserID: 1, Name: "cli", Prefix: "ck_second", Hash: "h2", Scopes: []string{"posts:read"}, ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+			other := &APIKey{UserID: 2, Name: "bob", Prefix: "ck_other", Hash: "h3", Scopes: []string{"posts:read"}, CreatedAt: now}
+			for _, key := range []*APIKey{first, second, other} {
+				if err := store.Create(ctx, key); err != nil {
+					t.Fatalf("Expected Create to succeed, got %v", err)
+				}
+			}
+			if first.ID == 0 || first.ID == second.ID {
+				t.Errorf("Expected distinct IDs, got %d and %d", first.ID, second.ID)
+			}
+
+			got, err := store.GetByPrefix(ctx, "ck_first")
+			if err != nil {
+				t.Fatalf("Expected GetByPrefix to succeed, got %v", err)
+			}
+			if got.UserID != 1 || got.Hash != "h1" || len(got.Scopes) != 2 || !got.ExpiresAt.IsZero() || !got.LastUsedAt.IsZero() {
+				t.Errorf("Unexpected key %+v", got)
+			}
+			if got, _ := store.GetByPrefix(ctx, "ck_second"); !got.ExpiresAt.Equal(now.Add(time.Hour)) {
+				t.Errorf("Expected expiry %v, got %v", now.Add(time.Hour), got.ExpiresAt)
+			}
+			if _, err := store.GetByPrefix(ctx, "ck_missing"); !errors.Is(err, ErrAPIKeyNotFound) {
+				t.Errorf("Expected ErrAPIKeyNotFound, got %v", err)
+			}
+
+			if err := store.Touch(ctx, first.ID, now); err != nil {
+				t.Fatalf("Expected Touch to succeed, got %v", err)
+			}
+			if got, _ := store.GetByPrefix(ctx, "ck_first"); !got.LastUsedAt.Equal(now) {
+				t.Errorf("Expected last use %v, got %v", now, got.LastUsedAt)
+			}
+
+			keys, err := store.ListForUser(ctx, 1)
+			if err != nil || len(keys) != 2 || keys[0].ID != second.ID {
+				t.Errorf("Expected both keys of user 1 newest first, got %v, %v", keys, err)
+			}
+
+			if err := store.Delete(ctx, 2, first.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+				t.Errorf("Expected ErrAPIKeyNotFound deleting another user's key, got %v", err)
+			}
+			if err := store.Delete(ctx, 1, first.ID); err != nil {
+				t.Fatalf("Expected Delete to succeed, got %v", err)
+			}
+			if _, err := store.GetByPrefix(ctx, "ck_first"); !errors.Is(err, ErrAPIKeyNotFound) {
+				t.Errorf("Expected deleted key to be gone, got %v", err)
+			}
+		})
+	}
+}
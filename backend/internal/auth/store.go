@@ -0,0 +1,124 @@
+// Package auth implements user registration, login and bearer token handling
+// for the main backend on top of the shared jwtservice, security and
+// userdomain packages in pkg/auth.
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/userdomain"
+)
+
+// Store errors
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("email is already registered")
+)
+
+// UserStore persists users. The Password field of stored users holds the
+// password hash, never the plain text password.
+type UserStore interface {
+	// Create inserts user and sets its ID. It returns ErrEmailTaken if the
+	// email is already registered.
+	Create(ctx context.Context, user *userdomain.User) error
+	GetByEmail(ctx context.Context, email string) (*userdomain.User, error)
+	GetByID(ctx context.Context, id int) (*userdomain.User, error)
+	// UpdatePassword replaces the password hash of a user
+	UpdatePassword(ctx context.Context, id int, hash string) error
+	// SetEmailVerified records that the user proved ownership of their email
+	SetEmailVerified(ctx context.Context, id int) error
+}
+
+// MemoryUserStore is a UserStore kept in process memory, used in tests and
+// local development without a database
+type MemoryUserStore struct {
+	mu      sync.RWMutex
+	users   map[int]userdomain.User
+	byEmail map[string]int
+	nextID  int
+}
+
+// NewMemoryUserStore creates an empty MemoryUserStore
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		users:   make(map[int]userdomain.User),
+		byEmail: make(map[string]int),
+		nextID:  1,
+	}
+}
+
+// Create implements UserStore
+func (s *MemoryUserStore) Create(ctx context.Context, user *userdomain.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	email := strings.ToLower(user.Email)
+	if _, ok := s.byEmail[email]; ok {
+		return ErrEmailTaken
+	}
+
+	user.ID = s.nextID
+	s.nextID++
+	s.users[user.ID] = *user
+	s.byEmail[email] = user.ID
+	return nil
+}
+
+// GetByEmail implements UserStore
+func (s *MemoryUserStore) GetByEmail(ctx context.Context, email string) (*userdomain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byEmail[strings.ToLower(email)]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	user := s.users[id]
+	return &user, nil
+}
+
+// GetByID implements UserStore
+func (s *MemoryUserStore) GetByID(ctx context.Context, id int) (*userdomain.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// UpdatePassword implements UserStore
+func (s *MemoryUserStore) UpdatePassword(ctx context.Context, id int, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Password = hash
+	user.UpdatedAt = time.Now()
+	s.users[id] = user
+	return nil
+}
+
+// SetEmailVerified implements UserStore
+func (s *MemoryUserStore) SetEmailVerified(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.EmailVerified = true
+	user.UpdatedAt = time.Now()
+	s.users[id] = user
+	return nil
+}
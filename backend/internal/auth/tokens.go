@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// One-time token purposes
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+// ErrInvalidToken is returned for one-time tokens that are unknown, used,
+// expired or issued for another purpose
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// OneTimeToken is a stored email verification or password reset token. Only
+// the hash of the token sent to the user is kept.
+type OneTimeToken struct {
+	Hash      string
+	UserID    int
+	Purpose   string
+	ExpiresAt time.Time
+}
+
+// TokenStore persists one-time tokens. Consume must be atomic so that a
+// token can be redeemed only once, even by concurrent requests.
+type TokenStore interface {
+	Create(ctx context.Context, token OneTimeToken) error
+	// Lookup returns the user of a valid token without using it up
+	Lookup(ctx context.Context, purpose, hash string, now time.Time) (int, error)
+	// Consume marks a valid token as used and returns its user
+	Consume(ctx context.Context, purpose, hash string, now time.Time) (int, error)
+	// DeleteForUser removes every token of a user with the given purpose
+	DeleteForUser(ctx context.Context, userID int, purpose string) error
+}
+
+// newOneTimeToken returns a random token for the user and its hash for the
+// store
+func newOneTimeToken() (token, hash string) {
+	token = rand.Text()
+	return token, hashToken(token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryTokenStore is a TokenStore kept in process memory
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]OneTimeToken
+}
+
+// NewMemoryTokenStore creates an empty MemoryTokenStore
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]OneTimeToken)}
+}
+
+// Create implements TokenStore
+func (s *MemoryTokenStore) Create(ctx context.Context, token OneTimeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for hash, t := range s.tokens {
+		if !t.ExpiresAt.After(now) {
+			delete(s.tokens, hash)
+		}
+	}
+	s.tokens[token.Hash] = token
+	return nil
+}
+
+// Lookup implements TokenStore
+func (s *MemoryTokenStore) Lookup(ctx context.Context, purpose, hash string, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[hash]
+	if !ok || t.Purpose != purpose || !t.ExpiresAt.After(now) {
+		return 0, ErrInvalidToken
+	}
+	return t.UserID, nil
+}
+
+// Consume implements TokenStore. Used tokens are simply deleted.
+func (s *MemoryTokenStore) Consume(ctx context.Context, purpose, hash string, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[hash]
+	if !ok || t.Purpose != purpose || !t.ExpiresAt.After(now) {
+		return 0, ErrInvalidToken
+	}
+	delete(s.tokens, hash)
+	return t.UserID, nil
+}
+
+// DeleteForUser implements TokenStore
+func (s *MemoryTokenStore) DeleteForUser(ctx context.Context, userID int, purpose string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.tokens {
+		if t.UserID == userID && t.Purpose == purpose {
+			delete(s.tokens, hash)
+		}
+	}
+	return nil
+}
@@ -1,13 +1,20 @@
 package config
 
 import (
+	"flag"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
 	// Test default values
-	cfg := Load()
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
 
 	if cfg.Env != "development" {
 		t.Errorf("Expected default env to be 'development', got '%s'", cfg.Env)
@@ -35,7 +42,10 @@ func TestLoadWithEnvVars(t *testing.T) {
 		os.Unsetenv("JWT_SECRET")
 	}()
 
-	cfg := Load()
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
 
 	if cfg.Env != "test" {
 		t.Errorf("Expected env to be 'test', got '%s'", cfg.Env)
@@ -55,14 +65,14 @@ func TestGetEnvAsInt(t *testing.T) {
 	os.Setenv("TEST_INT", "42")
 	defer os.Unsetenv("TEST_INT")
 
-	result := getEnvAsInt("TEST_INT", 10)
-	if result != 42 {
-		t.Errorf("Expected 42, got %d", result)
+	result, err := getEnvAsInt("TEST_INT", 10)
+	if err != nil || result != 42 {
+		t.Errorf("Expected 42, got %d (%v)", result, err)
 	}
 
 	// Test with fallback
-	result = getEnvAsInt("NON_EXISTENT", 10)
-	if result != 10 {
+	result, err = getEnvAsInt("NON_EXISTENT", 10)
+	if err != nil || result != 10 {
 		t.Errorf("Expected fallback value 10, got %d", result)
 	}
 }
@@ -72,14 +82,254 @@ func TestGetEnvAsBool(t *testing.T) {
 	os.Setenv("TEST_BOOL", "true")
 	defer os.Unsetenv("TEST_BOOL")
 
-	result := getEnvAsBool("TEST_BOOL", false)
-	if result != true {
-		t.Errorf("Expected true, got %v", result)
+	result, err := getEnvAsBool("TEST_BOOL", false)
+	if err != nil || result != true {
+		t.Errorf("Expected true, got %v (%v)", result, err)
 	}
 
 	// Test with fallback
-	result = getEnvAsBool("NON_EXISTENT", false)
-	if result != false {
+	result, err = getEnvAsBool("NON_EXISTENT", false)
+	if err != nil || result != false {
 		t.Errorf("Expected fallback value false, got %v", result)
 	}
 }
+
+func TestParseRejectsMalformedEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"CORS_MAX_AGE", "ten"},
+		{"CORS_STRICT", "maybe"},
+		{"BCRYPT_COST", "12.5"},
+		{"DB_MAX_OPEN_CONNS", "25 "},
+		{"READ_TIMEOUT", "15"},
+		{"LOGIN_LOCKOUT_DURATION", "forever"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+
+			if _, err := Parse(flag.NewFlagSet("test", flag.ContinueOnError), nil); err == nil || !strings.Contains(err.Error(), tt.name) {
+				t.Errorf("Expected Parse to reject %s=%q, got %v", tt.name, tt.value, err)
+			}
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tt.name) {
+				t.Errorf("Expected Load to reject %s=%q, got %v", tt.name, tt.value, err)
+			}
+		})
+	}
+}
+
+func TestParseReportsEveryMalformedEnv(t *testing.T) {
+	t.Setenv("BCRYPT_COST", "high")
+	t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+	// Empty values fall back to the defaults like unset ones
+	t.Setenv("CORS_MAX_AGE", "")
+
+	_, err := Parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, name := range []string{"BCRYPT_COST", "SHUTDOWN_TIMEOUT"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected error to name %s, got %v", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "CORS_MAX_AGE") {
+		t.Errorf("Expected empty CORS_MAX_AGE to be ignored, got %v", err)
+	}
+}
+
+func TestParseLayers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := "port: \"7000\"\nenv: staging\ncors_max_age: 30\nread_timeout: 5s\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Environment overrides the file, flags override the environment
+	t.Setenv("ENV", "test")
+	t.Setenv("CORS_MAX_AGE", "45")
+	t.Setenv("WRITE_TIMEOUT", "20s")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Parse(fs, []string{"-config", path, "-cors-max-age", "90"})
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	if cfg.Port != "7000" {
+		t.Errorf("Expected port from file '7000', got '%s'", cfg.Port)
+	}
+	if cfg.Env != "test" {
+		t.Errorf("Expected env from environment 'test', got '%s'", cfg.Env)
+	}
+	if cfg.CORSMaxAge != 90 {
+		t.Errorf("Expected CORS max age from flag 90, got %d", cfg.CORSMaxAge)
+	}
+	if cfg.ReadTimeout != 5*time.Second {
+		t.Errorf("Expected read timeout from file 5s, got %s", cfg.ReadTimeout)
+	}
+	if cfg.WriteTimeout != 20*time.Second {
+		t.Errorf("Expected write timeout from environment 20s, got %s", cfg.WriteTimeout)
+	}
+	if cfg.IdleTimeout != 60*time.Second {
+		t.Errorf("Expected default idle timeout 60s, got %s", cfg.IdleTimeout)
+	}
+}
+
+func TestParseJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"port": "9100", "cors_strict": true, "shutdown_timeout": "3s"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Parse(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if cfg.Port != "9100" || !cfg.CORSStrict || cfg.ShutdownTimeout != 3*time.Second {
+		t.Errorf("JSON file values not applied: %+v", cfg)
+	}
+}
+
+func TestParseRejectsUnknownFileKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("prot: 8080\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Parse(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path})
+	if err == nil {
+		t.Error("Expected error for unknown config key")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{"defaults in development", func(c *Config) {}, false},
+		{"invalid port", func(c *Config) { c.Port = "http" }, true},
+		{"zero timeout", func(c *Config) { c.ReadTimeout = 0 }, true},
+		{"production with default secret", func(c *Config) {
+			c.Env = "production"
+			c.DatabaseURL = "postgres://app:strong@db:5432/app"
+		}, true},
+		{"production with default database", func(c *Config) {
+			c.Env = "production"
+			c.JWTSecret = strings.Repeat("s", 32)
+		}, true},
+		{"production with short secret", func(c *Config) {
+			c.Env = "production"
+			c.JWTSecret = "short"
+			c.DatabaseURL = "postgres://app:strong@db:5432/app"
+		}, true},
+		{"debug endpoints", func(c *Config) { c.DebugEndpoints = true }, false},
+		{"production with debug endpoints", func(c *Config) {
+			c.Env = "production"
+			c.JWTSecret = strings.Repeat("s", 32)
+			c.DatabaseURL = "postgres://app:strong@db:5432/app"
+			c.CORSOrigins = "https://app.example.com"
+			c.SMTPAddr = "smtp.example.com:587"
+			c.DebugEndpoints = true
+		}, true},
+		{"wildcard origin", func(c *Config) { c.CORSOrigins = "http://localhost:3000, *" }, true},
+		{"production with wildcard origin", func(c *Config) {
+			c.Env = "production"
+			c.JWTSecret = strings.Repeat("s", 32)
+			c.DatabaseURL = "postgres://app:strong@db:5432/app"
+			c.CORSOrigins = "*"
+		}, true},
+		{"secure production", func(c *Config) {
+			c.Env = "production"
+			c.JWTSecret = strings.Repeat("s", 32)
+			c.DatabaseURL = "postgres://app:strong@db:5432/app"
+			c.CORSOrigins = "https://app.example.com"
+			c.SMTPAddr = "smtp.example.com:587"
+		}, false},
+		{"production with signing key instead of secret", func(c *Config) {
+			c.Env = "production"
+			c.JWTSigningKeyFile = "/run/secrets/jwt.pem"
+			c.DatabaseURL = "postgres://app:strong@db:5432/app"
+			c.CORSOrigins = "https://app.example.com"
+			c.SMTPAddr = "smtp.example.com:587"
+		}, false},
+		{"verify keys without signing key", func(c *Config) { c.JWTVerifyKeyFiles = "old.pem" }, true},
+		{"refresh shorter than access", func(c *Config) { c.JWTRefreshTTL = time.Minute }, true},
+		{"bcrypt hashing", func(c *Config) { c.PasswordHashAlgorithm = "bcrypt" }, false},
+		{"unknown hash algorithm", func(c *Config) { c.PasswordHashAlgorithm = "md5" }, true},
+		{"bcrypt cost too low", func(c *Config) { c.BcryptCost = 2 }, true},
+		{"argon2 memory too low", func(c *Config) { c.Argon2Memory = 8 }, true},
+		{"unlimited password length", func(c *Config) { c.PasswordMaxLength = 0 }, false},
+		{"password max below min", func(c *Config) { c.PasswordMaxLength = 4 }, true},
+		{"trusted proxies", func(c *Config) { c.TrustedProxies = "10.0.0.0/8, 192.0.2.10" }, false},
+		{"invalid trusted proxy", func(c *Config) { c.TrustedProxies = "10.0.0.0/8,proxy.local" }, true},
+		{"zero login failures", func(c *Config) { c.LoginMaxFailures = 0 }, true},
+		{"zero lockout duration", func(c *Config) { c.LoginLockoutDuration = 0 }, true},
+		{"production without smtp", func(c *Config) {
+			c.Env = "production"
+			c.JWTSecret = strings.Repeat("s", 32)
+			c.DatabaseURL = "postgres://app:strong@db:5432/app"
+			c.CORSOrigins = "https://app.example.com"
+		}, true},
+		{"invalid mail sender", func(c *Config) { c.MailFrom = "no-reply" }, true},
+		{"relative app url", func(c *Config) { c.AppURL = "/app" }, true},
+		{"smtp username without address", func(c *Config) { c.SMTPUsername = "mailer" }, true},
+		{"zero reset ttl", func(c *Config) { c.PasswordResetTTL = 0 }, true},
+		{"totp issuer with colon", func(c *Config) { c.TOTPIssuer = "Course: Backend" }, true},
+		{"zero mfa token ttl", func(c *Config) { c.MFATokenTTL = 0 }, true},
+		{"oidc without client id", func(c *Config) {
+			c.OIDCIssuerURL = "https://accounts.example.com"
+			c.OIDCRedirectURL = "http://localhost:8080/api/v1/auth/oidc/callback"
+		}, true},
+		{"oidc with relative redirect", func(c *Config) {
+			c.OIDCIssuerURL = "https://accounts.example.com"
+			c.OIDCClientID = "course"
+			c.OIDCRedirectURL = "/api/v1/auth/oidc/callback"
+		}, true},
+		{"oidc", func(c *Config) {
+			c.OIDCIssuerURL = "https://accounts.example.com"
+			c.OIDCClientID = "course"
+			c.OIDCRedirectURL = "http://localhost:8080/api/v1/auth/oidc/callback"
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	dump := cfg.Redacted()
+
+	if dump["jwt_secret"] != "[REDACTED]" {
+		t.Errorf("Expected JWT secret to be redacted, got %v", dump["jwt_secret"])
+	}
+	if url := dump["database_url"].(string); strings.Contains(url, "coursepass") {
+		t.Errorf("Expected database password to be redacted, got %s", url)
+	}
+	cfg.SMTPPassword = "smtp-secret"
+	if dump := cfg.Redacted(); dump["smtp_password"] != "[REDACTED]" {
+		t.Errorf("Expected SMTP password to be redacted, got %v", dump["smtp_password"])
+	}
+	cfg.OIDCClientSecret = "oidc-secret"
+	if dump := cfg.Redacted(); dump["oidc_client_secret"] != "[REDACTED]" {
+		t.Errorf("Expected OIDC client secret to be redacted, got %v", dump["oidc_client_secret"])
+	}
+	if dump["port"] != "8080" {
+		t.Errorf("Expected port in dump, got %v", dump["port"])
+	}
+}
@@ -0,0 +1,406 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+// checksumTable stores a SHA-256 of every applied migration file next to
+// goose's own schema-version table (goose_db_version)
+const checksumTable = "goose_migration_checksums"
+
+// ErrMigrationModified is returned when an applied migration file no longer
+// matches the checksum recorded when it was applied
+var ErrMigrationModified = errors.New("applied migration file was modified")
+
+// MigrationStatus describes a single migration file and its state in the database
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when the file changed after it was applied
+	Modified bool
+}
+
+// Migrator applies versioned SQL migrations from a directory using goose
+type Migrator struct {
+	db       *sql.DB
+	dialect  goose.Dialect
+	dir      string
+	provider *goose.Provider
+}
+
+// dialects are the goose dialects Open can return. Migrations that need
+// different SQL per database live in a subdirectory named after each one.
+var dialects = []goose.Dialect{goose.DialectPostgres, goose.DialectSQLite3}
+
+// NewMigrator creates a migrator for the SQL files in dir. When dir has a
+// subdirectory named after the dialect, such as migrations/postgres or
+// migrations/sqlite3, the files are read from there instead.
+func NewMigrator(db *sql.DB, dialect goose.Dialect, dir string) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection cannot be nil")
+	}
+
+	if sub := filepath.Join(dir, string(dialect)); isDir(sub) {
+		dir = sub
+	}
+	provider, err := goose.NewProvider(dialect, db, os.DirFS(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration provider: %w", err)
+	}
+
+	return &Migrator{
+		db:       db,
+		dialect:  dialect,
+		dir:      dir,
+		provider: provider,
+	}, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.runVerified(ctx, func() ([]*goose.MigrationResult, error) {
+		return m.provider.Up(ctx)
+	})
+}
+
+// Down rolls back the last n applied migrations
+func (m *Migrator) Down(ctx context.Context, n int) ([]*goose.MigrationResult, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of migrations to roll back must be positive, got %d", n)
+	}
+
+	return m.runVerified(ctx, func() ([]*goose.MigrationResult, error) {
+		var results []*goose.MigrationResult
+		for i := 0; i < n; i++ {
+			res, err := m.provider.Down(ctx)
+			if errors.Is(err, goose.ErrNoNextVersion) {
+				break
+			}
+			if err != nil {
+				return results, err
+			}
+			results = append(results, res)
+		}
+		return results, nil
+	})
+}
+
+// Redo rolls back the last applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.runVerified(ctx, func() ([]*goose.MigrationResult, error) {
+		down, err := m.provider.Down(ctx)
+		if err != nil {
+			return nil, err
+		}
+		up, err := m.provider.UpByOne(ctx)
+		if err != nil {
+			return []*goose.MigrationResult{down}, err
+		}
+		return []*goose.MigrationResult{down, up}, nil
+	})
+}
+
+// Goto migrates the database up or down to the given version. Version 0
+// rolls back every migration.
+func (m *Migrator) Goto(ctx context.Context, version int64) ([]*goose.MigrationResult, error) {
+	if version < 0 {
+		return nil, fmt.Errorf("invalid version: %d", version)
+	}
+
+	return m.runVerified(ctx, func() ([]*goose.MigrationResult, error) {
+		current, err := m.provider.GetDBVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case version > current:
+			if !m.hasVersion(version) {
+				return nil, fmt.Errorf("%w: %d", goose.ErrVersionNotFound, version)
+			}
+			return m.provider.UpTo(ctx, version)
+		case version < current:
+			if version != 0 && !m.hasVersion(version) {
+				return nil, fmt.Errorf("%w: %d", goose.ErrVersionNotFound, version)
+			}
+			return m.provider.DownTo(ctx, version)
+		default:
+			return nil, nil
+		}
+	})
+}
+
+// Version returns the current schema version
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	return m.provider.GetDBVersion(ctx)
+}
+
+// HasPending reports whether any migration file has not been applied yet
+func (m *Migrator) HasPending(ctx context.Context) (bool, error) {
+	return m.provider.HasPending(ctx)
+}
+
+// Status lists every migration file with its applied state. Unlike the other
+// commands it reports modified files instead of refusing to run.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	stored, err := m.storedChecksums(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0, len(statuses))
+	for _, st := range statuses {
+		status := MigrationStatus{
+			Version:   st.Source.Version,
+			Name:      filepath.Base(st.Source.Path),
+			Applied:   st.State == goose.StateApplied,
+			AppliedAt: st.AppliedAt,
+		}
+		if sum, ok := stored[st.Source.Version]; ok && status.Applied {
+			current, err := m.checksum(st.Source.Path)
+			if err != nil {
+				return nil, err
+			}
+			status.Modified = current != sum
+		}
+		result = append(result, status)
+	}
+
+	return result, nil
+}
+
+// Verify returns ErrMigrationModified if any applied migration file differs
+// from the version that was applied. Applied migrations without a recorded
+// checksum (for example from before checksums were tracked) are adopted.
+func (m *Migrator) Verify(ctx context.Context) error {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	stored, err := m.storedChecksums(ctx)
+	if err != nil {
+		return err
+	}
+
+	var modified []string
+	for _, st := range statuses {
+		if st.State != goose.StateApplied {
+			continue
+		}
+		current, err := m.checksum(st.Source.Path)
+		if err != nil {
+			return err
+		}
+		sum, ok := stored[st.Source.Version]
+		if !ok {
+			if err := m.saveChecksum(ctx, st.Source.Version, current); err != nil {
+				return err
+			}
+			continue
+		}
+		if sum != current {
+			modified = append(modified, filepath.Base(st.Source.Path))
+		}
+	}
+
+	if len(modified) > 0 {
+		return fmt.Errorf("%w: %s", ErrMigrationModified, strings.Join(modified, ", "))
+	}
+	return nil
+}
+
+// CreateMigration writes a new blank timestamped SQL migration into dir, or
+// into each of its dialect subdirectories when it has them, so that every
+// dialect gets a file with the same version. It returns the created paths.
+func CreateMigration(dir, name string) ([]string, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("migration name cannot be empty")
+	}
+
+	dirs := []string{dir}
+	if split := dialectDirs(dir); len(split) > 0 {
+		dirs = split
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	filename := fmt.Sprintf("%s_%s.sql", version, strings.ToLower(strings.Join(strings.Fields(name), "_")))
+	var paths []string
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return paths, fmt.Errorf("failed to create migrations directory: %w", err)
+		}
+		path := filepath.Join(d, filename)
+		if err := os.WriteFile(path, []byte(migrationTemplate), 0o644); err != nil {
+			return paths, fmt.Errorf("failed to create migration: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// migrationTemplate is the body of a new migration file
+const migrationTemplate = `-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+`
+
+// dialectDirs lists the dialect subdirectories of dir that exist
+func dialectDirs(dir string) []string {
+	var dirs []string
+	for _, dialect := range dialects {
+		if sub := filepath.Join(dir, string(dialect)); isDir(sub) {
+			dirs = append(dirs, sub)
+		}
+	}
+	return dirs
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// runVerified refuses to run fn when an applied migration was modified and
+// keeps the checksum table in sync with the schema version afterwards
+func (m *Migrator) runVerified(ctx context.Context, fn func() ([]*goose.MigrationResult, error)) ([]*goose.MigrationResult, error) {
+	if err := m.Verify(ctx); err != nil {
+		return nil, err
+	}
+
+	results, err := fn()
+	if syncErr := m.syncChecksums(ctx); syncErr != nil && err == nil {
+		err = syncErr
+	}
+	return results, err
+}
+
+// hasVersion reports whether a migration file exists for version
+func (m *Migrator) hasVersion(version int64) bool {
+	for _, src := range m.provider.ListSources() {
+		if src.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// syncChecksums records checksums of applied migrations and drops the ones
+// that were rolled back
+func (m *Migrator) syncChecksums(ctx context.Context) error {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	stored, err := m.storedChecksums(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, st := range statuses {
+		_, ok := stored[st.Source.Version]
+		switch {
+		case st.State == goose.StateApplied && !ok:
+			sum, err := m.checksum(st.Source.Path)
+			if err != nil {
+				return err
+			}
+			if err := m.saveChecksum(ctx, st.Source.Version, sum); err != nil {
+				return err
+			}
+		case st.State == goose.StatePending && ok:
+			query := fmt.Sprintf("DELETE FROM %s WHERE version_id = %s", checksumTable, m.placeholder(1))
+			if _, err := m.db.ExecContext(ctx, query, st.Source.Version); err != nil {
+				return fmt.Errorf("failed to delete migration checksum: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) ensureChecksumTable(ctx context.Context) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version_id BIGINT PRIMARY KEY,
+		checksum VARCHAR(64) NOT NULL
+	)`, checksumTable)
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create checksum table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) storedChecksums(ctx context.Context) (map[int64]string, error) {
+	if err := m.ensureChecksumTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT version_id, checksum FROM %s", checksumTable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration checksums: %w", err)
+	}
+	defer rows.Close()
+
+	sums := make(map[int64]string)
+	for rows.Next() {
+		var version int64
+		var sum string
+		if err := rows.Scan(&version, &sum); err != nil {
+			return nil, fmt.Errorf("failed to scan migration checksum: %w", err)
+		}
+		sums[version] = sum
+	}
+	return sums, rows.Err()
+}
+
+func (m *Migrator) saveChecksum(ctx context.Context, version int64, sum string) error {
+	query := fmt.Sprintf("INSERT INTO %s (version_id, checksum) VALUES (%s, %s)",
+		checksumTable, m.placeholder(1), m.placeholder(2))
+	if _, err := m.db.ExecContext(ctx, query, version, sum); err != nil {
+		return fmt.Errorf("failed to save migration checksum: %w", err)
+	}
+	return nil
+}
+
+// checksum hashes a migration file as it currently exists on disk
+func (m *Migrator) checksum(path string) (string, error) {
+	data, err := fs.ReadFile(os.DirFS(m.dir), path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migration %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (m *Migrator) placeholder(n int) string {
+	if m.dialect == goose.DialectPostgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
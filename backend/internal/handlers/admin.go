@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/policy"
+)
+
+// AdminHandler serves the /admin endpoints. Every route requires a token or
+// API key and a permission checked against the policy.
+type AdminHandler struct {
+	auth   *auth.Service
+	policy *policy.Policy
+}
+
+// NewAdminHandler creates an AdminHandler
+func NewAdminHandler(service *auth.Service, p *policy.Policy) *AdminHandler {
+	return &AdminHandler{auth: service, policy: p}
+}
+
+// RegisterRoutes mounts the admin endpoints on group
+func (h *AdminHandler) RegisterRoutes(group *gin.RouterGroup) {
+	read := middleware.RequirePermission(h.policy, auth.PermUsersRead)
+	write := middleware.RequirePermission(h.policy, auth.PermUsersWrite)
+
+	group.Use(middleware.RequireAuthOrAPIKey(h.auth, h.auth))
+	group.GET("/users/:id", read, h.GetUser)
+	group.GET("/users/:id/lockout", read, h.GetLockout)
+	group.POST("/users/:id/unlock", write, h.UnlockUser)
+	group.POST("/ips/:ip/unlock", write, h.UnlockIP)
+}
+
+// GetUser returns any user by ID
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.auth.User(c.Request.Context(), id)
+	if err != nil {
+		h.userError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// GetLockout returns the failed-login state of a user
+func (h *AdminHandler) GetLockout(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.auth.LockoutStatus(c.Request.Context(), id)
+	if err != nil {
+		h.userError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lockout": status})
+}
+
+// UnlockUser lifts a login lockout of a user
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.auth.UnlockUser(c.Request.Context(), id, actor(c)); err != nil {
+		h.userError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UnlockIP lifts a login lockout of a client IP address
+func (h *AdminHandler) UnlockIP(c *gin.Context) {
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ip address"})
+		return
+	}
+
+	if err := h.auth.UnlockIP(c.Request.Context(), ip.String(), actor(c)); err != nil {
+		h.userError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// userError maps a user lookup error to a response
+func (h *AdminHandler) userError(c *gin.Context, err error) {
+	if errors.Is(err, auth.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Error(err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+}
+
+// userIDParam parses the :id parameter, responding with 400 if it is invalid
+func userIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// actor identifies the authenticated administrator in audit events
+func actor(c *gin.Context) string {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		return ""
+	}
+	return claims.Email
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+)
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+	// ExpiresAt is optional; keys without it do not expire
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ListAPIKeys returns the authenticated user's API keys without secrets
+func (h *AuthHandler) ListAPIKeys(c *gin.Context) {
+	claims, _ := middleware.Claims(c)
+
+	keys, err := h.auth.APIKeys(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateAPIKey creates an API key. The response is the only time the full
+// key is shown.
+func (h *AuthHandler) CreateAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and scopes are required"})
+		return
+	}
+	claims, _ := middleware.Claims(c)
+
+	key, err := h.auth.CreateAPIKey(c.Request.Context(), claims.UserID, req.Name, req.Scopes, req.ExpiresAt)
+	switch {
+	case errors.Is(err, auth.ErrInvalidInput):
+		invalidInput(c, err)
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusCreated, gin.H{"api_key": key})
+	}
+}
+
+// RevokeAPIKey deletes one of the authenticated user's API keys
+func (h *AuthHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return
+	}
+	claims, _ := middleware.Claims(c)
+
+	err = h.auth.RevokeAPIKey(c.Request.Context(), claims.UserID, id)
+	switch {
+	case errors.Is(err, auth.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/lockout"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/security"
+)
+
+// AuthHandler serves the /auth endpoints
+type AuthHandler struct {
+	auth *auth.Service
+}
+
+// NewAuthHandler creates an AuthHandler
+func NewAuthHandler(service *auth.Service) *AuthHandler {
+	return &AuthHandler{auth: service}
+}
+
+// RegisterRoutes mounts the auth endpoints on group
+func (h *AuthHandler) RegisterRoutes(group *gin.RouterGroup) {
+	group.POST("/register", h.Register)
+	group.POST("/login", h.Login)
+	group.POST("/refresh", h.Refresh)
+	group.POST("/verify-email/confirm", h.ConfirmEmail)
+	group.POST("/password-reset/request", h.RequestPasswordReset)
+	group.POST("/password-reset/confirm", h.ResetPassword)
+	group.POST("/mfa/verify", h.VerifyMFA)
+
+	// API keys may read the profile, but managing credentials needs a
+	// login so that a leaked key cannot mint more keys
+	group.GET("/me", middleware.RequireAuthOrAPIKey(h.auth, h.auth), h.Me)
+
+	authenticated := group.Group("", middleware.RequireAuth(h.auth))
+	authenticated.POST("/logout", h.Logout)
+	authenticated.POST("/verify-email/request", h.RequestEmailVerification)
+	authenticated.GET("/mfa", h.MFAStatus)
+	authenticated.POST("/mfa/enroll", h.BeginMFA)
+	authenticated.POST("/mfa/confirm", h.ConfirmMFA)
+	authenticated.POST("/mfa/disable", h.DisableMFA)
+	authenticated.GET("/api-keys", h.ListAPIKeys)
+	authenticated.POST("/api-keys", h.CreateAPIKey)
+	authenticated.DELETE("/api-keys/:id", h.RevokeAPIKey)
+}
+
+type registerRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type tokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type passwordResetRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type verifyMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Register creates a new user account
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req registerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email, name and password are required"})
+		return
+	}
+
+	user, err := h.auth.Register(c.Request.Context(), req.Email, req.Name, req.Password)
+	switch {
+	case errors.Is(err, auth.ErrInvalidInput):
+		invalidInput(c, err)
+	case errors.Is(err, auth.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusCreated, gin.H{"user": user})
+	}
+}
+
+// Login exchanges email and password for an access token
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email and password are required"})
+		return
+	}
+
+	tokens, err := h.auth.Login(c.Request.Context(), req.Email, req.Password, c.ClientIP())
+	var locked *lockout.LockedError
+	var mfaRequired *auth.MFARequiredError
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.As(err, &locked):
+		tooManyAttempts(c, locked)
+	case errors.As(err, &mfaRequired):
+		mfaChallenge(c, mfaRequired)
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// Refresh exchanges a refresh token for a new token pair
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	tokens, err := h.auth.Refresh(c.Request.Context(), req.RefreshToken)
+	switch {
+	case isTokenError(err):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrUserNotFound):
+		// The user was deleted after the token was issued
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// Logout revokes the presented access token and its refresh tokens
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims, _ := middleware.Claims(c)
+	if err := h.auth.Logout(c.Request.Context(), claims); err != nil {
+		h.internalError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Me returns the authenticated user
+func (h *AuthHandler) Me(c *gin.Context) {
+	claims, _ := middleware.Claims(c)
+
+	user, err := h.auth.CurrentUser(c.Request.Context(), claims)
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusOK, gin.H{"user": user})
+	}
+}
+
+// RequestEmailVerification emails a new verification link to the
+// authenticated user
+func (h *AuthHandler) RequestEmailVerification(c *gin.Context) {
+	claims, _ := middleware.Claims(c)
+
+	err := h.auth.RequestEmailVerification(c.Request.Context(), claims.UserID)
+	switch {
+	case errors.Is(err, auth.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+	}
+}
+
+// ConfirmEmail redeems an email verification token
+func (h *AuthHandler) ConfirmEmail(c *gin.Context) {
+	var req tokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	user, err := h.auth.ConfirmEmail(c.Request.Context(), req.Token)
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusOK, gin.H{"user": user})
+	}
+}
+
+// RequestPasswordReset emails a reset link. It responds the same way
+// whether or not the email is registered.
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var req passwordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+		return
+	}
+
+	if err := h.auth.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		h.internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password with a reset token and signs the user
+// out everywhere
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token and password are required"})
+		return
+	}
+
+	err := h.auth.ResetPassword(c.Request.Context(), req.Token, req.Password)
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrInvalidInput):
+		invalidInput(c, err)
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// VerifyMFA completes a two-factor login with the token returned by Login
+// and a TOTP or recovery code
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req verifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code are required"})
+		return
+	}
+
+	tokens, err := h.auth.VerifyMFA(c.Request.Context(), req.MFAToken, req.Code, c.ClientIP())
+	var locked *lockout.LockedError
+	switch {
+	case errors.Is(err, auth.ErrInvalidMFACode), isTokenError(err):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.As(err, &locked):
+		tooManyAttempts(c, locked)
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// MFAStatus reports whether the authenticated user has two-factor
+// authentication enabled
+func (h *AuthHandler) MFAStatus(c *gin.Context) {
+	claims, _ := middleware.Claims(c)
+
+	status, err := h.auth.MFAStatus(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mfa": status})
+}
+
+// BeginMFA generates a TOTP secret for the authenticated user
+func (h *AuthHandler) BeginMFA(c *gin.Context) {
+	claims, _ := middleware.Claims(c)
+
+	enrollment, err := h.auth.BeginMFA(c.Request.Context(), claims.UserID)
+	switch {
+	case errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusOK, enrollment)
+	}
+}
+
+// ConfirmMFA enables two-factor authentication and returns the recovery
+// codes, which are shown only this once
+func (h *AuthHandler) ConfirmMFA(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+	claims, _ := middleware.Claims(c)
+
+	codes, err := h.auth.ConfirmMFA(c.Request.Context(), claims.UserID, req.Code)
+	switch {
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrMFANotEnrolled), errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// DisableMFA turns two-factor authentication off after checking a code
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+	claims, _ := middleware.Claims(c)
+
+	err := h.auth.DisableMFA(c.Request.Context(), claims.UserID, req.Code)
+	var locked *lockout.LockedError
+	switch {
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrMFANotEnrolled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &locked):
+		tooManyAttempts(c, locked)
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		h.internalError(c, err)
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// internalError hides err from the client and attaches it to the request log
+func (h *AuthHandler) internalError(c *gin.Context, err error) {
+	c.Error(err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+}
+
+// mfaChallenge responds with the token for the second step of a
+// two-factor login, see VerifyMFA
+func mfaChallenge(c *gin.Context, required *auth.MFARequiredError) {
+	c.JSON(http.StatusOK, gin.H{
+		"mfa_required": true,
+		"mfa_token":    required.Token,
+		"expires_in":   int(time.Until(required.ExpiresAt).Round(time.Second).Seconds()),
+	})
+}
+
+// tooManyAttempts responds with 429 and the time until the next attempt
+func tooManyAttempts(c *gin.Context, locked *lockout.LockedError) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": lockout.ErrLocked.Error()})
+}
+
+// invalidInput responds with 400. Password policy failures list every
+// violated rule under "details".
+func invalidInput(c *gin.Context, err error) {
+	var violations security.ValidationErrors
+	if errors.As(err, &violations) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password does not meet the requirements", "details": violations})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// isTokenError reports whether err means the client presented a bad token
+// rather than the server failing
+func isTokenError(err error) bool {
+	for _, target := range []error{
+		jwtservice.ErrEmptyToken,
+		jwtservice.ErrInvalidToken,
+		jwtservice.ErrInvalidClaims,
+		jwtservice.ErrTokenExpired,
+		jwtservice.ErrTokenRevoked,
+		jwtservice.ErrTokenReused,
+		jwtservice.ErrWrongTokenType,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	var methodErr jwtservice.InvalidSigningMethodError
+	return errors.As(err, &methodErr)
+}
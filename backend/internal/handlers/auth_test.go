@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/lockout"
+)
+
+// setupLoginRouter serves /auth/login with an IP lockout after two failures,
+// trusting the proxies configured in trustedProxies
+func setupLoginRouter(t *testing.T, trustedProxies string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	service, err := auth.NewService(auth.NewMemoryUserStore(), auth.Config{
+		Tokens:  jwtservice.Config{SecretKey: "test-secret"},
+		Lockout: lockout.New(lockout.Config{MaxAccountFailures: 100, MaxIPFailures: 2, FreeFailures: 100}),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.TrustedProxies = trustedProxies
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxyList()); err != nil {
+		t.Fatalf("Failed to set trusted proxies: %v", err)
+	}
+	NewAuthHandler(service).RegisterRoutes(router.Group("/auth"))
+	return router
+}
+
+// login posts wrong credentials for a new account from remoteAddr with the
+// given X-Forwarded-For header and returns the status code
+func login(router *gin.Engine, attempt int, remoteAddr, forwardedFor string) int {
+	body := `{"email":"user` + strconv.Itoa(attempt) + `@example.com","password":"wrong"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestLoginIgnoresForgedForwardedFor(t *testing.T) {
+	router := setupLoginRouter(t, "")
+
+	// Every attempt claims another client, but all come from one peer
+	for i := 0; i < 2; i++ {
+		if code := login(router, i, "203.0.113.7:4000", "198.51.100."+strconv.Itoa(i)); code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401, got %d", code)
+		}
+	}
+	if code := login(router, 2, "203.0.113.7:4000", "198.51.100.99"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the peer IP to be locked out, got %d", code)
+	}
+}
+
+func TestLoginTrustsConfiguredProxy(t *testing.T) {
+	router := setupLoginRouter(t, "10.0.0.0/8")
+
+	// Clients behind the proxy are counted separately
+	for i := 0; i < 3; i++ {
+		if code := login(router, i, "10.0.0.1:4000", "198.51.100."+strconv.Itoa(i)); code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401 for client %d, got %d", i, code)
+		}
+	}
+
+	// The same client is locked out no matter which proxy relays it
+	login(router, 3, "10.0.0.2:4000", "198.51.100.50")
+	login(router, 4, "10.0.0.3:4000", "198.51.100.50")
+	if code := login(router, 5, "10.0.0.1:4000", "198.51.100.50"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the forwarded client IP to be locked out, got %d", code)
+	}
+}
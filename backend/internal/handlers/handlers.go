@@ -4,20 +4,55 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/health"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/version"
 )
 
-// HealthCheck returns server health status
+// serviceName identifies this backend in health responses
+const serviceName = "sum25-go-flutter-course-backend"
+
+// HealthCheck reports that the process is alive. It does not check
+// dependencies, so it is used for the liveness probe.
 func HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "healthy",
-		"service": "sum25-go-flutter-course-backend",
-		"version": "1.0.0",
+		"service": serviceName,
+		"version": version.Version,
+		"commit":  version.Commit,
 	})
 }
 
+// Readiness runs the registered dependency checks and returns 503 if any of them fails
+func Readiness(checks *health.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := checks.Run(c.Request.Context())
+
+		status, code := "ready", http.StatusOK
+		if !report.Healthy {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+
+		c.JSON(code, gin.H{
+			"status":  status,
+			"service": serviceName,
+			"version": version.Version,
+			"commit":  version.Commit,
+			"checks":  report.Checks,
+		})
+	}
+}
+
 // Ping returns a simple pong response
 func Ping(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "pong",
 	})
 }
+
+// ConfigDump returns the effective configuration with secrets redacted
+func ConfigDump(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, cfg.Redacted())
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/oidc"
+)
+
+// oidcFlowCookie keeps the secrets of a login between /login and /callback
+const oidcFlowCookie = "oidc_flow"
+
+// oidcFlowMaxAge is how many seconds a user has to log in at the provider
+const oidcFlowMaxAge = 600
+
+// OIDCHandler serves login through an OpenID Connect provider
+type OIDCHandler struct {
+	auth     *auth.Service
+	provider *oidc.Provider
+	// secure marks the flow cookie HTTPS-only
+	secure bool
+}
+
+// NewOIDCHandler creates an OIDCHandler. secure should be true whenever the
+// callback is served over HTTPS.
+func NewOIDCHandler(service *auth.Service, provider *oidc.Provider, secure bool) *OIDCHandler {
+	return &OIDCHandler{auth: service, provider: provider, secure: secure}
+}
+
+// RegisterRoutes mounts the provider login endpoints on group
+func (h *OIDCHandler) RegisterRoutes(group *gin.RouterGroup) {
+	group.GET("/login", h.Login)
+	group.GET("/callback", h.Callback)
+}
+
+// Login redirects the browser to the provider
+func (h *OIDCHandler) Login(c *gin.Context) {
+	flow := oidc.NewFlow()
+	data, err := json.Marshal(flow)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, base64.RawURLEncoding.EncodeToString(data), oidcFlowMaxAge, "/", "", h.secure, true)
+	c.Redirect(http.StatusFound, h.provider.AuthCodeURL(flow))
+}
+
+// Callback completes the login the provider redirected back from and
+// responds like /auth/login
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "provider login failed: " + reason})
+		return
+	}
+
+	flow, ok := h.flow(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "login session is missing or expired"})
+		return
+	}
+	// Every flow is single-use
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, "", -1, "/", "", h.secure, true)
+
+	identity, err := h.provider.Exchange(c.Request.Context(), flow, c.Query("state"), c.Query("code"))
+	switch {
+	case errors.Is(err, oidc.ErrStateMismatch), errors.Is(err, oidc.ErrNonceMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, oidc.ErrMissingEmail):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.Error(err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "provider login failed"})
+		return
+	}
+
+	tokens, err := h.auth.LoginWithIdentity(c.Request.Context(), identity)
+	var mfaRequired *auth.MFARequiredError
+	switch {
+	case errors.Is(err, auth.ErrUnverifiedIdentity):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrAccountLinkConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &mfaRequired):
+		mfaChallenge(c, mfaRequired)
+	case err != nil:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	default:
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// flow decodes the flow cookie set by Login
+func (h *OIDCHandler) flow(c *gin.Context) (oidc.Flow, bool) {
+	var flow oidc.Flow
+	value, err := c.Cookie(oidcFlowCookie)
+	if err != nil {
+		return flow, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(data, &flow) != nil || flow.State == "" {
+		return flow, false
+	}
+	return flow, true
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Migrator is the part of database.Migrator needed to check the schema version
+type Migrator interface {
+	HasPending(ctx context.Context) (bool, error)
+}
+
+// DatabasePing checks that the database accepts connections
+func DatabasePing(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// MigrationsCurrent checks that every migration has been applied
+func MigrationsCurrent(m Migrator) Check {
+	return func(ctx context.Context) error {
+		pending, err := m.HasPending(ctx)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return errors.New("database schema has pending migrations")
+		}
+		return nil
+	}
+}
+
+// RedisPing sends a PING to the Redis server at addr (host:port) and expects PONG
+func RedisPing(addr string) Check {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+
+		if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+			return err
+		}
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if reply = strings.TrimSpace(reply); reply != "+PONG" {
+			return fmt.Errorf("unexpected reply %q", reply)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,133 @@
+// Package health runs dependency checks for the readiness probe.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Check verifies a single dependency. It should honour ctx cancellation, but
+// the registry enforces the timeout even if it does not.
+type Check func(ctx context.Context) error
+
+// Check statuses reported in results
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Result is the outcome of a single check
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of all registered checks
+type Report struct {
+	Healthy bool              `json:"-"`
+	Checks  map[string]Result `json:"checks"`
+}
+
+type registeredCheck struct {
+	name    string
+	timeout time.Duration
+	check   Check
+}
+
+// Registry holds the checks that decide whether the service is ready
+type Registry struct {
+	mu             sync.RWMutex
+	defaultTimeout time.Duration
+	checks         []registeredCheck
+}
+
+// NewRegistry creates a registry whose checks time out after defaultTimeout
+// unless registered with their own timeout
+func NewRegistry(defaultTimeout time.Duration) *Registry {
+	return &Registry{defaultTimeout: defaultTimeout}
+}
+
+// Register adds a check using the registry's default timeout
+func (r *Registry) Register(name string, check Check) {
+	r.RegisterWithTimeout(name, r.defaultTimeout, check)
+}
+
+// RegisterWithTimeout adds a check with its own timeout
+func (r *Registry) RegisterWithTimeout(name string, timeout time.Duration, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks = append(r.checks, registeredCheck{name: name, timeout: timeout, check: check})
+}
+
+// Run executes all checks concurrently and collects their results
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make([]registeredCheck, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	report := Report{Healthy: true, Checks: make(map[string]Result, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c registeredCheck) {
+			defer wg.Done()
+
+			result := runCheck(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+			if result.Status != StatusOK {
+				report.Healthy = false
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// runCheck runs a check under its timeout and measures its latency
+func runCheck(ctx context.Context, c registeredCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = fmt.Sprintf("timed out after %s", c.timeout)
+		}
+	}
+	return result
+}
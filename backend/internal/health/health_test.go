@@ -0,0 +1,166 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type fakeMigrator struct {
+	pending bool
+	err     error
+}
+
+func (m fakeMigrator) HasPending(ctx context.Context) (bool, error) {
+	return m.pending, m.err
+}
+
+func TestRunAllHealthy(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register("a", func(ctx context.Context) error { return nil })
+	registry.Register("b", func(ctx context.Context) error { return nil })
+
+	report := registry.Run(context.Background())
+
+	if !report.Healthy {
+		t.Errorf("Expected healthy report, got %+v", report)
+	}
+	if len(report.Checks) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(report.Checks))
+	}
+	for name, result := range report.Checks {
+		if result.Status != StatusOK {
+			t.Errorf("Expected %s to be ok, got %+v", name, result)
+		}
+		if result.LatencyMs < 0 {
+			t.Errorf("Expected non-negative latency for %s, got %v", name, result.LatencyMs)
+		}
+	}
+}
+
+func TestRunReportsFailures(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register("ok", func(ctx context.Context) error { return nil })
+	registry.Register("broken", func(ctx context.Context) error { return errors.New("connection refused") })
+	registry.Register("panics", func(ctx context.Context) error { panic("boom") })
+
+	report := registry.Run(context.Background())
+
+	if report.Healthy {
+		t.Error("Expected unhealthy report")
+	}
+	if got := report.Checks["ok"].Status; got != StatusOK {
+		t.Errorf("Expected ok check to pass, got %s", got)
+	}
+	if got := report.Checks["broken"]; got.Status != StatusFail || got.Error != "connection refused" {
+		t.Errorf("Expected broken check to fail with its error, got %+v", got)
+	}
+	if got := report.Checks["panics"]; got.Status != StatusFail || !strings.Contains(got.Error, "boom") {
+		t.Errorf("Expected panicking check to fail, got %+v", got)
+	}
+}
+
+func TestRunEnforcesTimeout(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	// Ignores ctx on purpose; the registry must still give up on it
+	registry.RegisterWithTimeout("slow", 20*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	report := registry.Run(context.Background())
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected Run to return after the timeout, took %s", elapsed)
+	}
+	result := report.Checks["slow"]
+	if result.Status != StatusFail || result.Error != "timed out after 20ms" {
+		t.Errorf("Expected timeout failure, got %+v", result)
+	}
+}
+
+func TestRunChecksConcurrently(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	for _, name := range []string{"a", "b", "c"} {
+		registry.Register(name, func(ctx context.Context) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+	}
+
+	start := time.Now()
+	registry.Run(context.Background())
+	if elapsed := time.Since(start); elapsed > 140*time.Millisecond {
+		t.Errorf("Expected checks to run concurrently, took %s", elapsed)
+	}
+}
+
+func TestDatabasePing(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	check := DatabasePing(db)
+	if err := check(context.Background()); err != nil {
+		t.Errorf("Expected ping to succeed, got %v", err)
+	}
+
+	db.Close()
+	if err := check(context.Background()); err == nil {
+		t.Error("Expected ping on closed database to fail")
+	}
+}
+
+func TestMigrationsCurrent(t *testing.T) {
+	tests := []struct {
+		name     string
+		migrator fakeMigrator
+		wantErr  bool
+	}{
+		{"current", fakeMigrator{}, false},
+		{"pending", fakeMigrator{pending: true}, true},
+		{"error", fakeMigrator{err: errors.New("no such table")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MigrationsCurrent(tt.migrator)(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRedisPing(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 64)
+			conn.Read(buf)
+			conn.Write([]byte("+PONG\r\n"))
+			conn.Close()
+		}
+	}()
+
+	if err := RedisPing(ln.Addr().String())(context.Background()); err != nil {
+		t.Errorf("Expected PING to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testMessage = Message{
+	To:      "alice@example.com",
+	Subject: "Verify your email",
+	Body:    "Open this link:\nhttps://example.com/verify?token=abc\n",
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	mailer := &FileMailer{Dir: dir, From: "App <no-reply@example.com>"}
+
+	if err := mailer.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Expected send to succeed, got %v", err)
+	}
+	if err := mailer.Send(context.Background(), Message{To: "not an address"}); err == nil {
+		t.Error("Expected error for an invalid recipient")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 message file, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	content := string(data)
+	for _, want := range []string{
+		"From: App <no-reply@example.com>\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: Verify your email\r\n",
+		"Message-ID: <",
+		"\r\n\r\nOpen this link:\r\nhttps://example.com/verify?token=abc\r\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected message to contain %q, got:\n%s", want, content)
+		}
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var out strings.Builder
+	mailer := &LogMailer{Logger: slog.New(slog.NewTextHandler(&out, nil))}
+
+	if err := mailer.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Expected send to succeed, got %v", err)
+	}
+	if !strings.Contains(out.String(), "to=alice@example.com") || !strings.Contains(out.String(), "token=abc") {
+		t.Errorf("Expected message to be logged, got %q", out.String())
+	}
+}
+
+// fakeSMTPServer accepts one message and sends its envelope and data on the
+// returned channel
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		var transcript strings.Builder
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				transcript.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+					transcript.WriteString(data)
+				}
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	mailer := &SMTPMailer{Addr: addr, From: "App <no-reply@example.com>"}
+
+	if err := mailer.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Expected send to succeed, got %v", err)
+	}
+
+	transcript := <-received
+	for _, want := range []string{
+		"MAIL FROM:<no-reply@example.com>",
+		"RCPT TO:<alice@example.com>",
+		"Subject: Verify your email",
+		"https://example.com/verify?token=abc",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("Expected transcript to contain %q, got:\n%s", want, transcript)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/policy"
+)
+
+type fakeValidator map[string]*jwtservice.Claims
+
+func (v fakeValidator) ValidateToken(token string) (*jwtservice.Claims, error) {
+	if claims, ok := v[token]; ok {
+		return claims, nil
+	}
+	return nil, errors.New("token signature is invalid")
+}
+
+type fakeKeys map[string]*jwtservice.Claims
+
+func (k fakeKeys) ValidateAPIKey(ctx context.Context, key string) (*jwtservice.Claims, error) {
+	if claims, ok := k[key]; ok {
+		return claims, nil
+	}
+	return nil, errors.New("api key was revoked")
+}
+
+func TestRequireAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	validator := fakeValidator{"good": {UserID: 7, Email: "alice@example.com"}}
+	router := gin.New()
+	router.GET("/me", RequireAuth(validator), func(c *gin.Context) {
+		claims, ok := Claims(c)
+		if !ok {
+			t.Error("Expected claims in context")
+		}
+		if userID, _ := c.Get(UserIDKey); userID != 7 {
+			t.Errorf("Expected user ID 7 in context, got %v", userID)
+		}
+		c.JSON(http.StatusOK, gin.H{"email": claims.Email})
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid token", "Bearer good", http.StatusOK},
+		{"lowercase scheme", "bearer good", http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"wrong scheme", "Basic good", http.StatusUnauthorized},
+		{"empty token", "Bearer ", http.StatusUnauthorized},
+		{"invalid token", "Bearer bad", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rr.Code)
+			}
+			if tt.want == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header on 401")
+			}
+			if strings.Contains(rr.Body.String(), "signature") {
+				t.Errorf("Expected the validation error to stay on the server, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	p := policy.New(map[string][]string{"admin": {"*"}, "user": {"posts:read"}})
+	validator := fakeValidator{
+		"admin": {UserID: 1, Roles: []string{"admin"}},
+		"user":  {UserID: 2, Roles: []string{"user"}},
+	}
+	router := gin.New()
+	router.GET("/users", RequireAuth(validator), RequirePermission(p, "users:read"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/unauthenticated", RequirePermission(p, "users:read"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"permitted", "/users", "admin", http.StatusOK},
+		{"missing permission", "/users", "user", http.StatusForbidden},
+		{"no claims", "/unauthenticated", "admin", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d", tt.want, rr.Code)
+			}
+			if tt.want != http.StatusForbidden {
+				return
+			}
+
+			var body policy.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			if body != policy.Forbidden("users:read") {
+				t.Errorf("Expected structured 403 body, got %+v", body)
+			}
+		})
+	}
+}
+
+func TestRequireAuthOrAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	p := policy.New(map[string][]string{"admin": {"*"}})
+	validator := fakeValidator{"admin": {UserID: 1, Roles: []string{"admin"}}}
+	keys := fakeKeys{
+		"ck_read_secret":  {UserID: 1, Permissions: []string{"users:read"}},
+		"ck_posts_secret": {UserID: 1, Permissions: []string{"posts:read"}},
+	}
+	router := gin.New()
+	router.GET("/users", RequireAuthOrAPIKey(validator, keys), RequirePermission(p, "users:read"), func(c *gin.Context) {
+		claims, _ := Claims(c)
+		c.JSON(http.StatusOK, gin.H{"user_id": claims.UserID})
+	})
+
+	tests := []struct {
+		name   string
+		bearer string
+		key    string
+		want   int
+	}{
+		{"bearer token", "admin", "", http.StatusOK},
+		{"api key", "", "ck_read_secret", http.StatusOK},
+		{"api key without scope", "", "ck_posts_secret", http.StatusForbidden},
+		{"invalid api key", "", "ck_read_wrong", http.StatusUnauthorized},
+		{"invalid api key with valid token", "admin", "ck_read_wrong", http.StatusUnauthorized},
+		{"no credentials", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rr.Code)
+			}
+			if strings.Contains(rr.Body.String(), "revoked") {
+				t.Errorf("Expected the validation error to stay on the server, got %s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -1,22 +1,36 @@
 package middleware
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/cors"
 )
 
-// CORS middleware to handle Cross-Origin Resource Sharing
-func CORS() gin.HandlerFunc {
-	return gin.HandlerFunc(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+// CORS middleware to handle Cross-Origin Resource Sharing using the origin
+// allowlist from the configuration. Routes can override the allowed methods
+// and headers for specific path prefixes. The API sends credentials, so an
+// allowlist of "*" is refused with cors.ErrWildcardCredentials.
+func CORS(cfg *config.Config, routes ...cors.Route) (gin.HandlerFunc, error) {
+	policy, err := cors.New(cors.Options{
+		AllowedOrigins:   cors.ParseOrigins(cfg.CORSOrigins),
+		AllowedHeaders:   []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin", "Cache-Control", "X-Requested-With"},
+		ExposedHeaders:   []string{RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           time.Duration(cfg.CORSMaxAge) * time.Second,
+		Strict:           cfg.CORSStrict,
+		Routes:           routes,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+	return func(c *gin.Context) {
+		if policy.Apply(c.Writer, c.Request) {
+			c.Abort()
 			return
 		}
-
 		c.Next()
-	})
+	}, nil
 }
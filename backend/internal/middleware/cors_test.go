@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/cors"
+)
+
+func mustCORS(t *testing.T, cfg *config.Config) gin.HandlerFunc {
+	t.Helper()
+	handler, err := CORS(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create CORS middleware: %v", err)
+	}
+	return handler
+}
+
+func TestCORSRejectsAnyOrigin(t *testing.T) {
+	if _, err := CORS(&config.Config{CORSOrigins: "http://localhost:3000,*"}); !errors.Is(err, cors.ErrWildcardCredentials) {
+		t.Errorf("Expected %v, got %v", cors.ErrWildcardCredentials, err)
+	}
+}
+
+func TestCORSPreflightOnGinRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{CORSOrigins: "http://localhost:3000, https://*.example.com", CORSMaxAge: 60, CORSStrict: true}
+	router := gin.New()
+	router.Use(mustCORS(t, cfg))
+	router.GET("/api/v1/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	tests := []struct {
+		origin     string
+		wantStatus int
+	}{
+		{"http://localhost:3000", http.StatusNoContent},
+		{"https://app.example.com", http.StatusNoContent},
+		{"http://evil.test", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/api/v1/ping", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", "GET")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusNoContent && rr.Header().Get("Access-Control-Allow-Origin") != tt.origin {
+				t.Errorf("Expected echoed origin %q, got %q", tt.origin, rr.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
+
+func TestCORSSimpleRequestOnGinRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(mustCORS(t, &config.Config{CORSOrigins: "http://localhost:3000"}))
+	router.GET("/api/v1/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "pong" {
+		t.Errorf("Expected handler response, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("Expected credentials header for allowed origin")
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupLoggerRouter(buf *bytes.Buffer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+	router := gin.New()
+	router.Use(RequestLogger(logger))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.Set(UserIDKey, 42)
+		Logger(c).Info("handler log")
+		c.String(http.StatusOK, "hello")
+	})
+	return router
+}
+
+// logLines decodes every JSON log line written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %q", line)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestRequestLoggerWritesStructuredLine(t *testing.T) {
+	var buf bytes.Buffer
+	router := setupLoggerRouter(&buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Errorf("Expected propagated request ID, got %q", got)
+	}
+
+	lines := logLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("Expected handler and request log lines, got %d", len(lines))
+	}
+
+	handlerLine, requestLine := lines[0], lines[1]
+	if handlerLine["request_id"] != "abc-123" {
+		t.Errorf("Expected handler log to carry request ID, got %v", handlerLine["request_id"])
+	}
+
+	expected := map[string]interface{}{
+		"msg":        "request",
+		"request_id": "abc-123",
+		"method":     "GET",
+		"route":      "/users/:id",
+		"path":       "/users/7",
+		"status":     float64(200),
+		"bytes":      float64(5),
+		"user_id":    float64(42),
+	}
+	for key, want := range expected {
+		if requestLine[key] != want {
+			t.Errorf("Expected %s=%v, got %v", key, want, requestLine[key])
+		}
+	}
+	if _, ok := requestLine["latency_ms"]; !ok {
+		t.Error("Expected latency_ms in request log")
+	}
+}
+
+func TestRequestLoggerGeneratesRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"missing", ""},
+		{"invalid characters", "bad id\nwith newline"},
+		{"too long", strings.Repeat("a", 200)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			router := setupLoggerRouter(&buf)
+
+			req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			id := rr.Header().Get(RequestIDHeader)
+			if id == "" || id == tt.header {
+				t.Fatalf("Expected a generated request ID, got %q", id)
+			}
+
+			line := logLines(t, &buf)[0]
+			if line["route"] != "unmatched" || line["level"] != "WARN" {
+				t.Errorf("Expected unmatched route logged as warning, got %v", line)
+			}
+			if line["request_id"] != id {
+				t.Errorf("Expected logged request ID %q, got %v", id, line["request_id"])
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupMetricsRouter(db *sql.DB) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	metrics := NewMetrics(db)
+	router := gin.New()
+	router.Use(metrics.Middleware())
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	router.GET("/metrics", metrics.Handler())
+	return router
+}
+
+func scrape(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 from /metrics, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text exposition format, got %q", ct)
+	}
+	body, _ := io.ReadAll(rr.Body)
+	return string(body)
+}
+
+func TestMetricsRecordsRouteTemplate(t *testing.T) {
+	router := setupMetricsRouter(nil)
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, router)
+
+	expected := []string{
+		`backend_http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`backend_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`backend_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`,
+		`backend_http_requests_in_flight 1`,
+		`go_goroutines`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "/users/1") {
+		t.Error("Expected raw paths not to be used as labels")
+	}
+	if strings.Contains(body, "go_sql_") {
+		t.Error("Expected no DB pool metrics without a database")
+	}
+}
+
+func TestMetricsIncludesDBStats(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	body := scrape(t, setupMetricsRouter(db))
+
+	for _, want := range []string{"go_sql_open_connections", "go_sql_max_open_connections"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package oidc logs users in with an external OpenID Connect provider using
+// the authorization code flow with PKCE.
+//
+// A login starts with NewFlow, whose values the caller keeps (e.g. in a
+// cookie) while the browser visits AuthCodeURL. The provider redirects back
+// with a code and the state, which Exchange turns into a verified Identity.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Errors returned by Exchange
+var (
+	ErrStateMismatch = errors.New("oidc state mismatch")
+	ErrNonceMismatch = errors.New("oidc nonce mismatch")
+	ErrMissingEmail  = errors.New("oidc provider returned no email")
+)
+
+// Config configures a Provider
+type Config struct {
+	// IssuerURL is discovered through /.well-known/openid-configuration
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is our callback registered with the provider
+	RedirectURL string
+	// Scopes defaults to openid, email and profile
+	Scopes []string
+	// HTTPClient is used for discovery, token and key requests. It defaults
+	// to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Identity is the verified result of a login
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Flow holds the per-login secrets. State protects against CSRF, Nonce ties
+// the ID token to this login and Verifier is the PKCE code verifier; none
+// of them may be reused.
+type Flow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// NewFlow generates the secrets of a new login
+func NewFlow() Flow {
+	return Flow{
+		State:    rand.Text(),
+		Nonce:    rand.Text(),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+}
+
+// Provider is a discovered OpenID Connect provider
+type Provider struct {
+	oauth    oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	client   *http.Client
+}
+
+// New discovers the provider at cfg.IssuerURL
+func New(ctx context.Context, cfg Config) (*Provider, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc issuer url, client id and redirect url are required")
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, client), cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover oidc provider: %w", err)
+	}
+
+	return &Provider{
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		client:   client,
+	}, nil
+}
+
+// AuthCodeURL returns the provider URL to send the browser to
+func (p *Provider) AuthCodeURL(flow Flow) string {
+	return p.oauth.AuthCodeURL(flow.State,
+		oidc.Nonce(flow.Nonce),
+		oauth2.S256ChallengeOption(flow.Verifier),
+	)
+}
+
+// Exchange checks that state matches the flow, redeems the code and
+// verifies the ID token's signature against the provider's JWKS, its
+// issuer, audience, expiry and nonce
+func (p *Provider) Exchange(ctx context.Context, flow Flow, state, code string) (*Identity, error) {
+	if flow.State == "" || state != flow.State {
+		return nil, ErrStateMismatch
+	}
+
+	ctx = oidc.ClientContext(ctx, p.client)
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange oidc code: %w", err)
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id token: %w", err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified any    `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse id token claims: %w", err)
+	}
+	if claims.Email == "" {
+		return nil, ErrMissingEmail
+	}
+
+	return &Identity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// isTrue reads email_verified, which some providers send as a string
+func isTrue(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	testClientID     = "course-backend"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "http://localhost:8080/api/v1/auth/oidc/callback"
+)
+
+// authorization is what the fake provider remembers about an issued code
+type authorization struct {
+	challenge string
+	nonce     string
+}
+
+// fakeProvider is a minimal OpenID Connect provider: discovery, JWKS, an
+// authorize endpoint that approves immediately and a token endpoint that
+// enforces PKCE
+type fakeProvider struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+	// signingKey signs ID tokens; set it to another key to simulate a forged
+	// token
+	signingKey *rsa.PrivateKey
+	claims     map[string]any
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	p := &fakeProvider{
+		t:          t,
+		key:        key,
+		signingKey: key,
+		claims: map[string]any{
+			"sub":            "provider-user-1",
+			"email":          "alice@example.com",
+			"email_verified": true,
+			"name":           "Alice",
+		},
+		codes: make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakeProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *fakeProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &p.key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"},
+	}})
+}
+
+func (p *fakeProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != testClientID || q.Get("code_challenge_method") != "S256" || q.Get("response_type") != "code" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := rand.Text()
+	p.mu.Lock()
+	p.codes[code] = authorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	p.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+	}
+	if clientID != testClientID || secret != testClientSecret {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.Form.Get("code")]
+	delete(p.codes, r.Form.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	claims := map[string]any{
+		"iss":   p.URL,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": auth.nonce,
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     p.sign(claims),
+	})
+}
+
+func (p *fakeProvider) sign(claims map[string]any) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: p.signingKey},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"),
+	)
+	if err != nil {
+		p.t.Fatalf("Failed to create signer: %v", err)
+	}
+	payload, _ := json.Marshal(claims)
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		p.t.Fatalf("Failed to sign id token: %v", err)
+	}
+	token, _ := signed.CompactSerialize()
+	return token
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func newTestProvider(t *testing.T, fake *fakeProvider) *Provider {
+	t.Helper()
+
+	provider, err := New(context.Background(), Config{
+		IssuerURL:    fake.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	return provider
+}
+
+// authorize visits the authorization URL like a browser and returns the
+// state and code from the redirect to our callback
+func authorize(t *testing.T, provider *Provider, flow Flow) (state, code string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(provider.AuthCodeURL(flow))
+	if err != nil {
+		t.Fatalf("Failed to authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected redirect from provider, got %d", resp.StatusCode)
+	}
+
+	callback, _ := url.Parse(resp.Header.Get("Location"))
+	return callback.Query().Get("state"), callback.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	fake := newFakeProvider(t)
+	provider := newTestProvider(t, fake)
+	ctx := context.Background()
+
+	flow := NewFlow()
+	state, code := authorize(t, provider, flow)
+
+	identity, err := provider.Exchange(ctx, flow, state, code)
+	if err != nil {
+		t.Fatalf("Expected exchange to succeed, got %v", err)
+	}
+	want := Identity{Issuer: fake.URL, Subject: "provider-user-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+	if *identity != want {
+		t.Errorf("Expected %+v, got %+v", want, *identity)
+	}
+
+	if _, err := provider.Exchange(ctx, flow, state, code); err == nil {
+		t.Error("Expected a code to be redeemable only once")
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	fake := newFakeProvider(t)
+	provider := newTestProvider(t, fake)
+	ctx := context.Background()
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name    string
+		setup   func(flow *Flow, state *string)
+		forge   bool
+		wantErr error
+	}{
+		{"state mismatch", func(flow *Flow, state *string) { *state = "forged" }, false, ErrStateMismatch},
+		{"missing state", func(flow *Flow, state *string) { flow.State, *state = "", "" }, false, ErrStateMismatch},
+		{"wrong code verifier", func(flow *Flow, state *string) { flow.Verifier = NewFlow().Verifier }, false, nil},
+		{"nonce mismatch", func(flow *Flow, state *string) { flow.Nonce = "other" }, false, ErrNonceMismatch},
+		{"token signed by an unknown key", func(flow *Flow, state *string) {}, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.signingKey = fake.key
+			if tt.forge {
+				fake.signingKey = otherKey
+			}
+
+			flow := NewFlow()
+			state, code := authorize(t, provider, flow)
+			tt.setup(&flow, &state)
+
+			_, err := provider.Exchange(ctx, flow, state, code)
+			if err == nil {
+				t.Fatal("Expected exchange to fail")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExchangeUnverifiedEmail(t *testing.T) {
+	fake := newFakeProvider(t)
+	fake.claims["email_verified"] = "false"
+	provider := newTestProvider(t, fake)
+
+	flow := NewFlow()
+	state, code := authorize(t, provider, flow)
+	identity, err := provider.Exchange(context.Background(), flow, state, code)
+	if err != nil {
+		t.Fatalf("Expected exchange to succeed, got %v", err)
+	}
+	if identity.EmailVerified {
+		t.Error("Expected email to be reported as unverified")
+	}
+}
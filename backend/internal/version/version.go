@@ -0,0 +1,12 @@
+// Package version holds build information injected at link time, e.g.
+//
+//	go build -ldflags "-X github.com/timur-harin/sum25-go-flutter-course/backend/internal/version.Version=v1.2.0 \
+//	  -X github.com/timur-harin/sum25-go-flutter-course/backend/internal/version.Commit=$(git rev-parse --short HEAD)"
+package version
+
+// Build information, overridden with -ldflags "-X" at build time
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
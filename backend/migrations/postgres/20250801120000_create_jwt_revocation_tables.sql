@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS jwt_revocations (
+    id VARCHAR(128) PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS jwt_used_refresh_tokens (
+    jti VARCHAR(128) PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_jwt_revocations_expires_at ON jwt_revocations(expires_at);
+CREATE INDEX IF NOT EXISTS idx_jwt_used_refresh_tokens_expires_at ON jwt_used_refresh_tokens(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS jwt_used_refresh_tokens;
+DROP TABLE IF EXISTS jwt_revocations;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Comma-separated role names, copied into access tokens at login
+ALTER TABLE users ADD COLUMN roles TEXT NOT NULL DEFAULT 'user';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN roles;
+-- +goose StatementEnd
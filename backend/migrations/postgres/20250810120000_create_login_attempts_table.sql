@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Failed login counters and lockouts, keyed by "account:<email>" or "ip:<addr>"
+CREATE TABLE IF NOT EXISTS login_attempts (
+    subject VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure ON login_attempts(last_failure);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_attempts;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Per-user session version; bumping it invalidates every token issued before
+CREATE TABLE IF NOT EXISTS jwt_session_versions (
+    user_id VARCHAR(128) PRIMARY KEY,
+    version INTEGER NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS jwt_session_versions;
+-- +goose StatementEnd
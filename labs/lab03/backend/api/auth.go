@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/policy"
+
+	"lab03-backend/models"
+)
+
+// RoleAdmin is the course backend role allowed to purge messages and see
+// the history of deleted ones
+const RoleAdmin = "admin"
+
+// PermMessagesModerate is the course backend permission to edit and delete
+// any message. Its access tokens carry the permissions of the user's roles.
+const PermMessagesModerate = "messages:moderate"
+
+// ErrUnauthenticated is returned when a request carries no valid credentials
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Identity is the authenticated user behind a request
+type Identity struct {
+	UserID      int
+	Username    string
+	Roles       []string
+	Permissions []string
+}
+
+// HasRole reports whether the identity has role
+func (i *Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the identity has been granted permission, which may
+// be through a wildcard such as "messages:*" or "*"
+func (i *Identity) Can(permission string) bool {
+	subject := policy.Subject{UserID: i.UserID, Roles: i.Roles, Permissions: i.Permissions}
+	return subject.Can(permission)
+}
+
+// Authenticator turns a bearer token into an Identity
+type Authenticator interface {
+	Authenticate(token string) (*Identity, error)
+}
+
+// JWKSRefreshInterval is how often a JWTAuthenticator may fetch the JWKS
+// again when a token names a key it does not know
+const JWKSRefreshInterval = time.Minute
+
+// JWTConfig selects how the course backend's access tokens are verified
+type JWTConfig struct {
+	// Secret verifies HS256 tokens. It must match the backend's JWT_SECRET.
+	Secret string
+	// JWKSURL is the backend's /.well-known/jwks.json, used instead of
+	// Secret when the backend signs with RS256 or EdDSA keys
+	JWKSURL string
+	// Revocations is the backend's revocation store, usually a
+	// jwtservice.SQLRevocationStore on its database. Without it, tokens
+	// revoked by a logout, a reused refresh token or a password reset are
+	// still accepted until they expire.
+	Revocations jwtservice.RevocationStore
+	// Client fetches the JWKS and defaults to a client with a 10s timeout
+	Client *http.Client
+}
+
+// JWTAuthenticator accepts access tokens issued by the course backend
+type JWTAuthenticator struct {
+	cfg JWTConfig
+
+	mu      sync.Mutex
+	keys    *jwtservice.KeySet
+	tokens  *jwtservice.JWTService
+	fetched time.Time
+}
+
+// NewJWTAuthenticator creates a JWTAuthenticator. With a JWKS URL the keys
+// are fetched right away, so a wrong URL is reported at startup.
+func NewJWTAuthenticator(cfg JWTConfig) (*JWTAuthenticator, error) {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	a := &JWTAuthenticator{cfg: cfg}
+	if cfg.JWKSURL == "" {
+		tokens, err := jwtservice.New(jwtservice.Config{SecretKey: cfg.Secret, Store: cfg.Revocations})
+		if err != nil {
+			return nil, err
+		}
+		a.tokens = tokens
+		return a, nil
+	}
+	a.fetched = time.Now()
+	if _, err := a.fetchKeys(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Authenticate implements Authenticator. The email is used as username, as
+// it is the only unique name in the token.
+func (a *JWTAuthenticator) Authenticate(token string) (*Identity, error) {
+	claims, err := a.service(token).ValidateToken(token)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	return &Identity{UserID: claims.UserID, Username: claims.Email, Roles: claims.Roles, Permissions: claims.Permissions}, nil
+}
+
+// service returns the JWTService to validate token with. When the token
+// names a key that is not in the JWKS yet, the backend may have rotated its
+// keys, so they are fetched again unless that happened recently. Other
+// requests keep using the known keys meanwhile.
+func (a *JWTAuthenticator) service(token string) *jwtservice.JWTService {
+	a.mu.Lock()
+	tokens := a.tokens
+	refresh := false
+	if a.keys != nil {
+		_, known := a.keys.Lookup(keyID(token))
+		refresh = !known && time.Since(a.fetched) >= JWKSRefreshInterval
+	}
+	if refresh {
+		a.fetched = time.Now()
+	}
+	a.mu.Unlock()
+
+	if refresh {
+		// On failure the known keys keep working
+		if fresh, err := a.fetchKeys(); err == nil {
+			tokens = fresh
+		}
+	}
+	return tokens
+}
+
+// fetchKeys loads the JWKS and returns a JWTService verifying with it
+func (a *JWTAuthenticator) fetchKeys() (*jwtservice.JWTService, error) {
+	resp, err := a.cfg.Client.Get(a.cfg.JWKSURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	keys, err := jwtservice.ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := jwtservice.New(jwtservice.Config{Keys: keys, Store: a.cfg.Revocations})
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.keys, a.tokens = keys, tokens
+	a.mu.Unlock()
+	return tokens, nil
+}
+
+// keyID returns the kid header of a JWT, or "" if it has none
+func keyID(token string) string {
+	header, _, _ := strings.Cut(token, ".")
+	data, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return ""
+	}
+	var h struct {
+		KeyID string `json:"kid"`
+	}
+	json.Unmarshal(data, &h)
+	return h.KeyID
+}
+
+// DevAuthenticator trusts every bearer token as the username of a user
+// without roles. It lets the chat run without the course backend and must
+// only be used for local development.
+type DevAuthenticator struct{}
+
+// Authenticate implements Authenticator
+func (DevAuthenticator) Authenticate(token string) (*Identity, error) {
+	return &Identity{Username: token}, nil
+}
+
+// identity authenticates the request's bearer token. It returns
+// ErrUnauthenticated when there is no authenticator, no token or an
+// invalid one.
+func (h *Handler) identity(r *http.Request) (*Identity, error) {
+	if h.auth == nil {
+		return nil, ErrUnauthenticated
+	}
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, ErrUnauthenticated
+	}
+	return h.auth.Authenticate(strings.TrimSpace(token))
+}
+
+// canModify reports whether the identity may edit or delete msg: its author
+// and moderators may
+func (i *Identity) canModify(msg *models.Message) bool {
+	return i.isAuthor(msg) || i.Can(PermMessagesModerate)
+}
+
+// isAuthor reports whether the identity posted msg. Users of the course
+// backend are matched by user ID, as their email may change or be reused.
+// DevAuthenticator users have no ID and are matched by username.
+func (i *Identity) isAuthor(msg *models.Message) bool {
+	if i.UserID == 0 {
+		return msg.UserID == 0 && msg.Username == i.Username
+	}
+	return msg.UserID == i.UserID
+}
+
+// requireIdentity writes 401 and returns nil unless the request is
+// authenticated
+func (h *Handler) requireIdentity(w http.ResponseWriter, r *http.Request) *Identity {
+	id, err := h.identity(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="chat"`)
+		h.writeError(w, http.StatusUnauthorized, err.Error())
+		return nil
+	}
+	return id
+}
+
+// requireRole writes 401 or 403 and returns nil unless the request is
+// authenticated with role
+func (h *Handler) requireRole(w http.ResponseWriter, r *http.Request, role string) *Identity {
+	id := h.requireIdentity(w, r)
+	if id == nil {
+		return nil
+	}
+	if !id.HasRole(role) {
+		h.writeError(w, http.StatusForbidden, "this action requires the "+role+" role")
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+)
+
+// newSigner returns a JWTService issuing tokens like the course backend
+func newSigner(t *testing.T, cfg jwtservice.Config) *jwtservice.JWTService {
+	t.Helper()
+	service, err := jwtservice.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return service
+}
+
+func issue(t *testing.T, signer *jwtservice.JWTService) string {
+	t.Helper()
+	// As the course backend issues it for an editor
+	pair, err := signer.IssueTokenPair(jwtservice.Subject{
+		UserID:      7,
+		Email:       "alice@example.com",
+		Roles:       []string{"editor"},
+		Permissions: []string{"posts:*", PermMessagesModerate},
+	})
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	return pair.AccessToken
+}
+
+func TestJWTAuthenticatorSecret(t *testing.T) {
+	auth, err := NewJWTAuthenticator(JWTConfig{Secret: "secret"})
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator failed: %v", err)
+	}
+
+	id, err := auth.Authenticate(issue(t, newSigner(t, jwtservice.Config{SecretKey: "secret"})))
+	if err != nil {
+		t.Fatalf("Expected the token to be accepted, got %v", err)
+	}
+	if id.UserID != 7 || id.Username != "alice@example.com" || !id.HasRole("editor") || !id.Can(PermMessagesModerate) {
+		t.Errorf("Unexpected identity %+v", id)
+	}
+	if _, err := auth.Authenticate(issue(t, newSigner(t, jwtservice.Config{SecretKey: "other"}))); err != ErrUnauthenticated {
+		t.Errorf("Expected a token with another secret to be rejected, got %v", err)
+	}
+}
+
+func TestJWTAuthenticatorJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet := func(key *jwtservice.Key) *jwtservice.KeySet {
+		keys, err := jwtservice.NewKeySet(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+
+	// The backend serves the JWKS of whichever signer is current
+	var current atomic.Pointer[jwtservice.JWTService]
+	current.Store(newSigner(t, jwtservice.Config{Keys: keySet(jwtservice.NewRSAKey("rsa", rsaKey))}))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		current.Load().JWKSHandler().ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	auth, err := NewJWTAuthenticator(JWTConfig{JWKSURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator failed: %v", err)
+	}
+	if _, err := auth.Authenticate(issue(t, current.Load())); err != nil {
+		t.Errorf("Expected an RS256 token to be accepted, got %v", err)
+	}
+
+	// After a key rotation the new kid is fetched, but not more often
+	// than JWKSRefreshInterval
+	current.Store(newSigner(t, jwtservice.Config{Keys: keySet(jwtservice.NewEd25519Key("ed", edKey))}))
+	rotated := issue(t, current.Load())
+	if _, err := auth.Authenticate(rotated); err != ErrUnauthenticated {
+		t.Errorf("Expected the JWKS not to be fetched again yet, got %v", err)
+	}
+	auth.fetched = time.Now().Add(-JWKSRefreshInterval)
+	if _, err := auth.Authenticate(rotated); err != nil {
+		t.Errorf("Expected an EdDSA token of a rotated key to be accepted, got %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("Expected 2 JWKS fetches, got %d", n)
+	}
+
+	// HS256 tokens cannot be verified with published keys
+	if _, err := auth.Authenticate(issue(t, newSigner(t, jwtservice.Config{SecretKey: "secret"}))); err != ErrUnauthenticated {
+		t.Errorf("Expected an HS256 token to be rejected, got %v", err)
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if _, err := NewJWTAuthenticator(JWTConfig{JWKSURL: missing.URL}); err == nil {
+		t.Error("Expected an error for a missing JWKS")
+	}
+}
+
+func TestJWTAuthenticatorRevocation(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "backend.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := jwtservice.NewSQLRevocationStore(db)
+	if err := store.CreateSchema(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := newSigner(t, jwtservice.Config{SecretKey: "secret", Store: store})
+	token := issue(t, backend)
+
+	shared, err := NewJWTAuthenticator(JWTConfig{Secret: "secret", Revocations: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without the backend's store revocations are not seen
+	standalone, err := NewJWTAuthenticator(JWTConfig{Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shared.Authenticate(token); err != nil {
+		t.Fatalf("Expected the token to be accepted before revocation, got %v", err)
+	}
+
+	// A password reset revokes every session of the user
+	if err := backend.RevokeSessions(context.Background(), 7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shared.Authenticate(token); err != ErrUnauthenticated {
+		t.Errorf("Expected a revoked token to be rejected, got %v", err)
+	}
+	if _, err := standalone.Authenticate(token); err != nil {
+		t.Errorf("Expected revocation to be ignored without the store, got %v", err)
+	}
+}
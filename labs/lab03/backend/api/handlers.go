@@ -1,36 +1,95 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/jsonbody"
 
+	"lab03-backend/images"
 	"lab03-backend/models"
 	"lab03-backend/storage"
 )
 
 type Handler struct {
-	storage *storage.MemoryStorage
+	storage   storage.MessageStore
+	auth      Authenticator
+	images    images.Provider
+	publicURL string
+	events    *storage.Broker
+	heartbeat time.Duration
 }
 
-func NewHandler(st *storage.MemoryStorage) *Handler {
-	return &Handler{storage: st}
+// Config holds the optional dependencies of a Handler
+type Config struct {
+	// Auth identifies users by bearer token. Without it every endpoint that
+	// needs an identity responds with 401.
+	Auth Authenticator
+	// Images serves /api/cat; images.Default() when nil
+	Images images.Provider
+	// PublicURL is the externally visible base URL of the API, such as
+	// https://chat.example.com, used in links to its own endpoints. Without
+	// it links are built from the host of the request.
+	PublicURL string
+	// Events receives the changes made through the Handler and feeds
+	// /api/messages/stream; a private broker when nil
+	Events *storage.Broker
+	// Heartbeat is how often an idle stream sends a comment to keep the
+	// connection open; DefaultHeartbeat when zero
+	Heartbeat time.Duration
+}
+
+// DefaultHeartbeat is the heartbeat interval of event streams by default
+const DefaultHeartbeat = 15 * time.Second
+
+// streamRetry is how long clients wait before reconnecting a dropped stream
+const streamRetry = 3 * time.Second
+
+func NewHandler(st storage.MessageStore) *Handler {
+	return NewHandlerWithConfig(st, Config{})
+}
+
+// NewHandlerWithConfig creates a Handler with the dependencies in cfg
+func NewHandlerWithConfig(st storage.MessageStore, cfg Config) *Handler {
+	if cfg.Images == nil {
+		cfg.Images = images.Default()
+	}
+	if cfg.Events == nil {
+		cfg.Events = storage.NewBroker(storage.DefaultReplaySize)
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = DefaultHeartbeat
+	}
+	return &Handler{
+		storage:   storage.WithEvents(st, cfg.Events),
+		auth:      cfg.Auth,
+		images:    cfg.Images,
+		publicURL: strings.TrimSuffix(cfg.PublicURL, "/"),
+		events:    cfg.Events,
+		heartbeat: cfg.Heartbeat,
+	}
 }
 
 func (h *Handler) SetupRoutes() *mux.Router {
 	r := mux.NewRouter()
-	r.Use(corsMiddleware)
 
 	api := r.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/messages", h.GetMessages).Methods("GET")
 	api.HandleFunc("/messages", h.CreateMessage).Methods("POST")
+	api.HandleFunc("/messages/search", h.SearchMessages).Methods("GET")
+	api.HandleFunc("/messages/stream", h.StreamMessages).Methods("GET")
 	api.HandleFunc("/messages/{id}", h.UpdateMessage).Methods("PUT")
 	api.HandleFunc("/messages/{id}", h.DeleteMessage).Methods("DELETE")
+	api.HandleFunc("/messages/{id}/revisions", h.GetRevisions).Methods("GET")
+	api.HandleFunc("/admin/messages/{id}", h.PurgeMessage).Methods("DELETE")
 	api.HandleFunc("/status/{code}", h.GetHTTPStatus).Methods("GET")
 	api.HandleFunc("/cat/{code}", h.GetStatusImage).Methods("GET") // <-- добавлено
 	api.HandleFunc("/health", h.HealthCheck).Methods("GET")
@@ -38,22 +97,258 @@ func (h *Handler) SetupRoutes() *mux.Router {
 	return r
 }
 
+// GetMessages returns messages, oldest first. Without query parameters it
+// returns all of them. Otherwise it returns a page: ?after= and ?before=
+// take message IDs from a previous page's pagination, ?limit= the page
+// size, and with ?limit= alone the newest messages are returned.
 func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
-	msgs := h.storage.GetAll()
-	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: msgs})
+	page, err := parsePage(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if page == (storage.Page{}) {
+		msgs, err := h.listAll()
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "failed to load messages")
+			return
+		}
+		h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: msgs})
+		return
+	}
+	result, err := h.storage.List(page)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "failed to load messages")
+		return
+	}
+
+	msgs := result.Messages
+	if msgs == nil {
+		msgs = []*models.Message{}
+	}
+	pagination := &models.Pagination{Limit: page.Limit, HasMore: result.HasMore}
+	if pagination.Limit == 0 {
+		pagination.Limit = storage.DefaultPageLimit
+	}
+	if len(msgs) > 0 {
+		pagination.After = msgs[len(msgs)-1].ID
+		pagination.Before = msgs[0].ID
+	}
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: msgs, Pagination: pagination})
+}
+
+// listAll reads every message page by page, from the newest back. The
+// pages are joined once at the end, oldest first, rather than prepending
+// each one, which would copy the messages read so far on every page.
+func (h *Handler) listAll() ([]*models.Message, error) {
+	var pages [][]*models.Message
+	total := 0
+	page := storage.Page{Limit: storage.MaxPageLimit}
+	for {
+		result, err := h.storage.List(page)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, result.Messages)
+		total += len(result.Messages)
+		if !result.HasMore || len(result.Messages) == 0 {
+			break
+		}
+		page.Before = result.Messages[0].ID
+	}
+
+	msgs := make([]*models.Message, 0, total)
+	for i := len(pages) - 1; i >= 0; i-- {
+		msgs = append(msgs, pages[i]...)
+	}
+	return msgs, nil
+}
+
+// SearchMessages returns the newest messages containing every word of ?q=,
+// case-insensitively, each with a highlighted snippet. ?username= and the
+// RFC 3339 times ?from= and ?to= narrow the results; ?limit= caps them.
+func (h *Handler) SearchMessages(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSearch(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	msgs, err := h.storage.Search(query)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "failed to search messages")
+		return
+	}
+
+	tokens := storage.Tokenize(query.Text)
+	results := make([]models.SearchResult, 0, len(msgs))
+	for _, msg := range msgs {
+		results = append(results, models.SearchResult{
+			Message: msg,
+			Snippet: storage.Highlight(msg.Content, tokens),
+		})
+	}
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: results})
+}
+
+// StreamMessages sends message changes as Server-Sent Events named created,
+// updated and deleted, with the message as JSON data. A client reconnecting
+// with Last-Event-ID first receives the events it missed; when those are no
+// longer available it receives a reset event and should reload the
+// messages. Comments are sent as heartbeats while there are no events.
+func (h *Handler) StreamMessages(w http.ResponseWriter, r *http.Request) {
+	var lastEventID uint64
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		lastEventID = id
+	}
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	sub, replay, complete := h.events.Subscribe(lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		writeEvent(w, event)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind or shutting down; the client
+				// reconnects and resumes from the last event it received
+				return
+			}
+			writeEvent(w, event)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes an event in the text/event-stream format
+func writeEvent(w io.Writer, event storage.Event) {
+	data, err := json.Marshal(event.Message)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
 }
 
+// parseSearch reads the query parameters of SearchMessages
+func parseSearch(r *http.Request) (storage.SearchQuery, error) {
+	params := r.URL.Query()
+	query := storage.SearchQuery{
+		Text:     params.Get("q"),
+		Username: params.Get("username"),
+	}
+	if len(storage.Tokenize(query.Text)) == 0 {
+		return query, errors.New("q must contain at least one word")
+	}
+	times := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"from", &query.From},
+		{"to", &query.To},
+	}
+	for _, p := range times {
+		value := params.Get(p.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, fmt.Errorf("%s must be an RFC 3339 time", p.name)
+		}
+		*p.dst = t
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && query.To.Before(query.From) {
+		return query, errors.New("from must not be after to")
+	}
+	if value := params.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > storage.MaxSearchLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", storage.MaxSearchLimit)
+		}
+		query.Limit = n
+	}
+	return query, nil
+}
+
+// parsePage reads the cursor query parameters of GetMessages
+func parsePage(r *http.Request) (storage.Page, error) {
+	var page storage.Page
+	params := []struct {
+		name string
+		dst  *int
+	}{
+		{"after", &page.After},
+		{"before", &page.Before},
+		{"limit", &page.Limit},
+	}
+	for _, p := range params {
+		value := r.URL.Query().Get(p.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return page, fmt.Errorf("%s must be a positive integer", p.name)
+		}
+		*p.dst = n
+	}
+	if page.Limit > storage.MaxPageLimit {
+		return page, fmt.Errorf("limit must be at most %d", storage.MaxPageLimit)
+	}
+	if page.Before > 0 && page.After >= page.Before {
+		return page, errors.New("after must be less than before")
+	}
+	return page, nil
+}
+
+// CreateMessage posts a message as the authenticated user. The username in
+// the request is replaced with theirs.
 func (h *Handler) CreateMessage(w http.ResponseWriter, r *http.Request) {
-	var req models.CreateMessageRequest
-	if err := h.parseJSON(r, &req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "invalid JSON payload")
+	identity := h.requireIdentity(w, r)
+	if identity == nil {
 		return
 	}
-	if err := req.Validate(); err != nil {
-		h.writeError(w, http.StatusBadRequest, err.Error())
+	// The body may omit the username, so it is filled in before validation
+	req := models.CreateMessageRequest{Username: identity.Username}
+	if err := h.parseJSON(w, r, &req); err != nil {
+		h.writeDecodeError(w, err)
 		return
 	}
-	msg, err := h.storage.Create(req.Username, req.Content)
+	req.Username = identity.Username
+	msg, err := h.storage.CreateByUser(identity.UserID, req.Username, req.Content)
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "failed to create message")
 		return
@@ -61,37 +356,115 @@ func (h *Handler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusCreated, models.APIResponse{Success: true, Data: msg})
 }
 
+// UpdateMessage edits a message. Only its author and moderators may.
 func (h *Handler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
+	identity := h.requireIdentity(w, r)
+	if identity == nil {
+		return
+	}
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		h.writeError(w, http.StatusBadRequest, "invalid message ID")
 		return
 	}
-	var req models.UpdateMessageRequest
-	if err := h.parseJSON(r, &req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "invalid JSON payload")
+	if !h.authorizeModify(w, identity, id, "edit") {
 		return
 	}
-	if err := req.Validate(); err != nil {
-		h.writeError(w, http.StatusBadRequest, err.Error())
+	var req models.UpdateMessageRequest
+	if err := h.parseJSON(w, r, &req); err != nil {
+		h.writeDecodeError(w, err)
 		return
 	}
 	msg, err := h.storage.Update(id, req.Content)
 	if err != nil {
-		h.writeError(w, http.StatusNotFound, err.Error())
+		h.writeStorageError(w, err)
 		return
 	}
 	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: msg})
 }
 
+// DeleteMessage replaces a message with a tombstone. Only its author and
+// moderators may.
 func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	identity := h.requireIdentity(w, r)
+	if identity == nil {
+		return
+	}
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		h.writeError(w, http.StatusBadRequest, "invalid message ID")
 		return
 	}
+	if !h.authorizeModify(w, identity, id, "delete") {
+		return
+	}
 	if err := h.storage.Delete(id); err != nil {
-		h.writeError(w, http.StatusNotFound, err.Error())
+		h.writeStorageError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeModify writes 404, 410 or 403 and returns false unless identity
+// may perform action on the message
+func (h *Handler) authorizeModify(w http.ResponseWriter, identity *Identity, id int, action string) bool {
+	msg, err := h.storage.GetByID(id)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return false
+	}
+	if msg.Deleted() {
+		h.writeStorageError(w, storage.ErrMessageDeleted)
+		return false
+	}
+	if !identity.canModify(msg) {
+		h.writeError(w, http.StatusForbidden, fmt.Sprintf("only the author or a moderator can %s this message", action))
+		return false
+	}
+	return true
+}
+
+// GetRevisions returns every version of a message, oldest first. The
+// history of a deleted message is only shown to admins.
+func (h *Handler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid message ID")
+		return
+	}
+	msg, err := h.storage.GetByID(id)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	if msg.Deleted() {
+		if identity, err := h.identity(r); err != nil || !identity.HasRole(RoleAdmin) {
+			h.writeStorageError(w, storage.ErrMessageDeleted)
+			return
+		}
+	}
+
+	revisions, err := h.storage.Revisions(id)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: revisions})
+}
+
+// PurgeMessage removes a message or tombstone with its history. Only
+// admins may purge.
+func (h *Handler) PurgeMessage(w http.ResponseWriter, r *http.Request) {
+	if h.requireRole(w, r, RoleAdmin) == nil {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid message ID")
+		return
+	}
+	if err := h.storage.Purge(id); err != nil {
+		h.writeStorageError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -109,36 +482,69 @@ func (h *Handler) GetHTTPStatus(w http.ResponseWriter, r *http.Request) {
 	}
 	statusResp := models.HTTPStatusResponse{
 		StatusCode:  code,
-		ImageURL:    fmt.Sprintf("http://localhost:8080/api/cat/%d", code),
+		ImageURL:    fmt.Sprintf("%s/api/cat/%d", h.baseURL(r), code),
 		Description: desc,
 	}
 	h.writeJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: statusResp})
 }
 
-// 🔽 Новая функция: прокси для https://http.cat/{code}
-func (h *Handler) GetStatusImage(w http.ResponseWriter, r *http.Request) {
-	code := mux.Vars(r)["code"]
-	url := fmt.Sprintf("https://http.cat/%s", code)
+// statusImageMaxAge is how long clients may keep a status image, and
+// fallbackImageMaxAge how long they may keep a bundled replacement
+const (
+	statusImageMaxAge   = 24 * time.Hour
+	fallbackImageMaxAge = 5 * time.Minute
+)
 
-	resp, err := http.Get(url)
-	if err != nil {
-		http.Error(w, "Failed to fetch image", http.StatusInternalServerError)
+// GetStatusImage serves the picture of a status code, from http.cat when
+// reachable. Responses carry an ETag, so clients revalidate with
+// If-None-Match and get 304 Not Modified.
+func (h *Handler) GetStatusImage(w http.ResponseWriter, r *http.Request) {
+	code, err := strconv.Atoi(mux.Vars(r)["code"])
+	if err != nil || !images.ValidCode(code) {
+		h.writeError(w, http.StatusBadRequest, "invalid status code")
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		http.Error(w, "Image not found", http.StatusNotFound)
+	img, err := h.images.Image(r.Context(), code)
+	switch {
+	case errors.Is(err, images.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, "image not found")
+		return
+	case err != nil:
+		h.writeError(w, http.StatusBadGateway, "failed to load image")
 		return
 	}
 
-	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-	w.WriteHeader(http.StatusOK)
-	io.Copy(w, resp.Body)
+	maxAge := statusImageMaxAge
+	if img.Fallback {
+		maxAge = fallbackImageMaxAge
+	}
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("ETag", img.ETag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	// ServeContent answers If-None-Match, Range and HEAD requests
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(img.Data))
+}
+
+// baseURL returns the configured public URL, or the scheme and host the
+// request was made to
+func (h *Handler) baseURL(r *http.Request) string {
+	if h.publicURL != "" {
+		return h.publicURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
 }
 
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	total, err := h.storage.CountMessages()
+	if err != nil {
+		h.writeError(w, http.StatusServiceUnavailable, "storage unavailable")
+		return
+	}
+
 	health := struct {
 		Status        string `json:"status"`
 		Message       string `json:"message"`
@@ -148,7 +554,7 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 		Status:        "healthy",
 		Message:       "API is running",
 		Timestamp:     time.Now().Format(time.RFC3339),
-		TotalMessages: h.storage.Count(),
+		TotalMessages: total,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -178,19 +584,34 @@ func (h *Handler) writeError(w http.ResponseWriter, status int, message string)
 	h.writeJSON(w, status, models.APIResponse{Success: false, Error: message})
 }
 
-func (h *Handler) parseJSON(r *http.Request, dst interface{}) error {
-	return json.NewDecoder(r.Body).Decode(dst)
+// writeStorageError maps storage errors to responses
+func (h *Handler) writeStorageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrMessageNotFound):
+		h.writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrMessageDeleted):
+		h.writeError(w, http.StatusGone, err.Error())
+	default:
+		h.writeError(w, http.StatusInternalServerError, "storage error")
+	}
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+// parseJSON decodes a request body strictly and checks its validate tags
+func (h *Handler) parseJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	return jsonbody.Decode(w, r, dst)
+}
+
+// writeDecodeError responds to a failed parseJSON with the status it calls
+// for and the fields that were rejected
+func (h *Handler) writeDecodeError(w http.ResponseWriter, err error) {
+	var bodyErr *jsonbody.Error
+	if !errors.As(err, &bodyErr) {
+		h.writeError(w, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+	var fields []models.FieldError
+	for _, f := range bodyErr.Fields {
+		fields = append(fields, models.FieldError{Field: f.Field, Rule: f.Rule, Message: f.Message})
+	}
+	h.writeJSON(w, bodyErr.Status, models.APIResponse{Success: false, Error: bodyErr.Message, Fields: fields})
 }
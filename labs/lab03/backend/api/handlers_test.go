@@ -1,18 +1,27 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"lab03-backend/images"
 	"lab03-backend/models"
 	"lab03-backend/storage"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
+// testToken authenticates as testuser in handlers from setupTestHandler
+const testToken = "test-token"
+
 func setupTestHandler() *Handler {
 	storage := storage.NewMemoryStorage()
-	return NewHandler(storage)
+	return NewHandlerWithConfig(storage, Config{Auth: fakeAuth{testToken: {UserID: 1, Username: "testuser"}}})
 }
 
 func TestGetMessages(t *testing.T) {
@@ -57,6 +66,7 @@ func TestCreateMessage(t *testing.T) {
 		t.Fatal(err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testToken)
 
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
@@ -89,6 +99,7 @@ func TestUpdateMessage(t *testing.T) {
 	jsonData, _ := json.Marshal(createReq)
 	createHttpReq, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonData))
 	createHttpReq.Header.Set("Content-Type", "application/json")
+	createHttpReq.Header.Set("Authorization", "Bearer "+testToken)
 
 	createRr := httptest.NewRecorder()
 	router.ServeHTTP(createRr, createHttpReq)
@@ -104,6 +115,7 @@ func TestUpdateMessage(t *testing.T) {
 		t.Fatal(err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testToken)
 
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
@@ -126,6 +138,7 @@ func TestDeleteMessage(t *testing.T) {
 	jsonData, _ := json.Marshal(createReq)
 	createHttpReq, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonData))
 	createHttpReq.Header.Set("Content-Type", "application/json")
+	createHttpReq.Header.Set("Authorization", "Bearer "+testToken)
 
 	createRr := httptest.NewRecorder()
 	router.ServeHTTP(createRr, createHttpReq)
@@ -135,6 +148,7 @@ func TestDeleteMessage(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
 
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
@@ -209,3 +223,565 @@ func TestHealthCheck(t *testing.T) {
 		t.Errorf("Expected Content-Type application/json, got %s", contentType)
 	}
 }
+
+func TestGetMessagesPagination(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+	for i := 0; i < 5; i++ {
+		handler.storage.Create("testuser", "message")
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int
+		hasMore    bool
+	}{
+		{"newest page", "?limit=2", http.StatusOK, []int{4, 5}, true},
+		{"older page", "?before=4&limit=2", http.StatusOK, []int{2, 3}, true},
+		{"newer page", "?after=3&limit=5", http.StatusOK, []int{4, 5}, false},
+		{"invalid limit", "?limit=abc", http.StatusBadRequest, nil, false},
+		{"limit too large", "?limit=1000", http.StatusBadRequest, nil, false},
+		{"empty range", "?after=4&before=2", http.StatusBadRequest, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/messages"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data       []models.Message   `json:"data"`
+				Pagination *models.Pagination `json:"pagination"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			if len(response.Data) != len(tt.wantIDs) {
+				t.Fatalf("Expected %d messages, got %d", len(tt.wantIDs), len(response.Data))
+			}
+			for i, msg := range response.Data {
+				if msg.ID != tt.wantIDs[i] {
+					t.Errorf("Expected message %d at position %d, got %d", tt.wantIDs[i], i, msg.ID)
+				}
+			}
+			if response.Pagination == nil || response.Pagination.HasMore != tt.hasMore {
+				t.Fatalf("Expected has_more %v, got %+v", tt.hasMore, response.Pagination)
+			}
+			if response.Pagination.Before != tt.wantIDs[0] || response.Pagination.After != tt.wantIDs[len(tt.wantIDs)-1] {
+				t.Errorf("Expected cursors of the page bounds, got %+v", response.Pagination)
+			}
+		})
+	}
+}
+
+func TestGetMessagesWithoutParamsReturnsAll(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+	total := storage.MaxPageLimit + storage.DefaultPageLimit + 1
+	for i := 0; i < total; i++ {
+		handler.storage.Create("testuser", "message")
+	}
+
+	req := httptest.NewRequest("GET", "/api/messages", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v", rr.Code)
+	}
+	var response struct {
+		Data       []models.Message   `json:"data"`
+		Pagination *models.Pagination `json:"pagination"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(response.Data) != total {
+		t.Fatalf("Expected all %d messages, got %d", total, len(response.Data))
+	}
+	for i, msg := range response.Data {
+		if msg.ID != i+1 {
+			t.Fatalf("Expected message %d at position %d, got %d", i+1, i, msg.ID)
+		}
+	}
+	if response.Pagination != nil {
+		t.Errorf("Expected no pagination, got %+v", response.Pagination)
+	}
+}
+
+// fakeAuth maps bearer tokens to identities
+type fakeAuth map[string]*Identity
+
+func (a fakeAuth) Authenticate(token string) (*Identity, error) {
+	if id, ok := a[token]; ok {
+		return id, nil
+	}
+	return nil, ErrUnauthenticated
+}
+
+func TestDevAuthenticator(t *testing.T) {
+	handler := NewHandlerWithConfig(storage.NewMemoryStorage(), Config{Auth: DevAuthenticator{}})
+	router := handler.SetupRoutes()
+
+	post := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/messages", strings.NewReader(`{"content":"hi"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := post(""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %v", rr.Code)
+	}
+	rr := post("alice")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %v: %s", rr.Code, rr.Body)
+	}
+	msg, _ := handler.storage.GetByID(1)
+	if msg == nil || msg.Username != "alice" {
+		t.Errorf("Expected the token to be used as username, got %+v", msg)
+	}
+}
+
+func TestMessageHistory(t *testing.T) {
+	auth := fakeAuth{
+		"admin-token": {UserID: 1, Username: "admin", Roles: []string{RoleAdmin}},
+		"user-token":  {UserID: 2, Username: "testuser"},
+	}
+	handler := NewHandlerWithConfig(storage.NewMemoryStorage(), Config{Auth: auth})
+	router := handler.SetupRoutes()
+
+	do := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		reader := &bytes.Buffer{}
+		if body != nil {
+			json.NewEncoder(reader).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	do("POST", "/api/messages", "user-token", models.CreateMessageRequest{Username: "testuser", Content: "first"})
+	if rr := do("PUT", "/api/messages/1", "user-token", models.UpdateMessageRequest{Content: "second"}); rr.Code != http.StatusOK {
+		t.Fatalf("Expected update to succeed, got %v", rr.Code)
+	}
+
+	rr := do("GET", "/api/messages/1/revisions", "", nil)
+	var revisions struct {
+		Data []models.Revision `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&revisions); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("Expected revisions, got %v, %v", rr.Code, err)
+	}
+	if len(revisions.Data) != 2 || revisions.Data[0].Content != "first" || revisions.Data[1].Content != "second" {
+		t.Errorf("Expected both versions, got %+v", revisions.Data)
+	}
+
+	if rr := do("DELETE", "/api/messages/1", "user-token", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected soft delete to succeed, got %v", rr.Code)
+	}
+	rr = do("GET", "/api/messages", "", nil)
+	var list struct {
+		Data []models.Message `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.Data) != 1 || !list.Data[0].Deleted() || list.Data[0].Content != "" {
+		t.Errorf("Expected a tombstone in the list, got %+v", list.Data)
+	}
+	if rr := do("PUT", "/api/messages/1", "user-token", models.UpdateMessageRequest{Content: "third"}); rr.Code != http.StatusGone {
+		t.Errorf("Expected 410 updating a tombstone, got %v", rr.Code)
+	}
+	if rr := do("GET", "/api/messages/1/revisions", "user-token", nil); rr.Code != http.StatusGone {
+		t.Errorf("Expected history of a deleted message to be hidden, got %v", rr.Code)
+	}
+	if rr := do("GET", "/api/messages/1/revisions", "admin-token", nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected admins to see the history of a deleted message, got %v", rr.Code)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"anonymous purge", "", http.StatusUnauthorized},
+		{"invalid token", "bogus", http.StatusUnauthorized},
+		{"user purge", "user-token", http.StatusForbidden},
+		{"admin purge", "admin-token", http.StatusNoContent},
+		{"purge again", "admin-token", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rr := do("DELETE", "/api/admin/messages/1", tt.token, nil); rr.Code != tt.want {
+			t.Errorf("%s: expected status %v, got %v", tt.name, tt.want, rr.Code)
+		}
+	}
+}
+
+func TestMessageOwnership(t *testing.T) {
+	auth := fakeAuth{
+		"alice": {UserID: 1, Username: "alice@example.com"},
+		"bob":   {UserID: 2, Username: "bob@example.com"},
+		"mod":   {UserID: 3, Username: "mod@example.com", Roles: []string{"editor"}, Permissions: []string{"posts:*", PermMessagesModerate}},
+		// Emails can change and be given to someone else; the user ID stays
+		"alice-renamed":  {UserID: 1, Username: "alice@example.net"},
+		"alice-imposter": {UserID: 4, Username: "alice@example.com"},
+	}
+	handler := NewHandlerWithConfig(storage.NewMemoryStorage(), Config{Auth: auth})
+	router := handler.SetupRoutes()
+
+	do := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		reader := &bytes.Buffer{}
+		if body != nil {
+			json.NewEncoder(reader).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("POST", "/api/messages", "", models.CreateMessageRequest{Username: "alice@example.com", Content: "hi"}); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected anonymous post to be rejected, got %v", rr.Code)
+	}
+
+	rr := do("POST", "/api/messages", "alice", models.CreateMessageRequest{Username: "bob@example.com", Content: "hi"})
+	var created struct {
+		Data models.Message `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || created.Data.Username != "alice@example.com" {
+		t.Fatalf("Expected message authored by alice, got %v %+v", rr.Code, created.Data)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		want   int
+	}{
+		{"anonymous edit", "PUT", "", http.StatusUnauthorized},
+		{"other user edit", "PUT", "bob", http.StatusForbidden},
+		{"other user delete", "DELETE", "bob", http.StatusForbidden},
+		{"other user with the author's email", "PUT", "alice-imposter", http.StatusForbidden},
+		{"author edit", "PUT", "alice", http.StatusOK},
+		{"author edit after email change", "PUT", "alice-renamed", http.StatusOK},
+		{"moderator edit", "PUT", "mod", http.StatusOK},
+		{"moderator delete", "DELETE", "mod", http.StatusNoContent},
+		{"author edit after delete", "PUT", "alice", http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := do(tt.method, "/api/messages/1", tt.token, models.UpdateMessageRequest{Content: "changed"})
+			if rr.Code != tt.want {
+				t.Fatalf("Expected status %v, got %v", tt.want, rr.Code)
+			}
+			if tt.want != http.StatusForbidden {
+				return
+			}
+			var response models.APIResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			if response.Success || response.Error == "" {
+				t.Errorf("Expected an error in the response envelope, got %+v", response)
+			}
+		})
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+	handler.storage.Create("alice", "Deploy the <b>backend</b> today")
+	handler.storage.Create("bob", "backend tests are green")
+	handler.storage.Create("alice", "lunch?")
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int
+	}{
+		{"match", "?q=Backend", http.StatusOK, []int{2, 1}},
+		{"username", "?q=backend&username=alice", http.StatusOK, []int{1}},
+		{"no match", "?q=dinner", http.StatusOK, []int{}},
+		{"missing query", "", http.StatusBadRequest, nil},
+		{"no words", "?q=%3F%21", http.StatusBadRequest, nil},
+		{"invalid time", "?q=backend&from=yesterday", http.StatusBadRequest, nil},
+		{"invalid limit", "?q=backend&limit=0", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/messages/search"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data []models.SearchResult `json:"data"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			if len(response.Data) != len(tt.wantIDs) {
+				t.Fatalf("Expected %d results, got %d", len(tt.wantIDs), len(response.Data))
+			}
+			for i, result := range response.Data {
+				if result.Message.ID != tt.wantIDs[i] {
+					t.Errorf("Expected message %d at position %d, got %d", tt.wantIDs[i], i, result.Message.ID)
+				}
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/api/messages/search?q=backend&username=alice", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var response struct {
+		Data []models.SearchResult `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil || len(response.Data) != 1 {
+		t.Fatalf("Expected one result, got %+v, %v", response.Data, err)
+	}
+	want := "Deploy the &lt;b&gt;<mark>backend</mark>&lt;/b&gt; today"
+	if response.Data[0].Snippet != want {
+		t.Errorf("Expected snippet %q, got %q", want, response.Data[0].Snippet)
+	}
+}
+
+// fakeImages serves the same image for every code
+type fakeImages struct{}
+
+func (fakeImages) Image(ctx context.Context, code int) (*images.Image, error) {
+	return images.NewImage([]byte("cat"), "image/jpeg"), nil
+}
+
+func TestGetStatusImage(t *testing.T) {
+	handler := NewHandlerWithConfig(storage.NewMemoryStorage(), Config{Images: fakeImages{}})
+	router := handler.SetupRoutes()
+
+	req := httptest.NewRequest("GET", "/api/cat/404", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "cat" {
+		t.Fatalf("Expected the image, got %v %q", rr.Code, rr.Body.String())
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" || rr.Header().Get("Cache-Control") != "public, max-age=86400" {
+		t.Errorf("Expected caching headers, got %v", rr.Header())
+	}
+
+	req = httptest.NewRequest("GET", "/api/cat/404", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status %v, got %v", http.StatusNotModified, rr.Code)
+	}
+
+	for _, code := range []string{"abc", "99", "600"} {
+		req = httptest.NewRequest("GET", "/api/cat/"+code, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %v for %s, got %v", http.StatusBadRequest, code, rr.Code)
+		}
+	}
+}
+
+func TestGetHTTPStatusImageURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		publicURL string
+		want      string
+	}{
+		{"request host", "", "http://chat.test/api/cat/418"},
+		{"public url", "https://chat.example.com/", "https://chat.example.com/api/cat/418"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandlerWithConfig(storage.NewMemoryStorage(), Config{Images: fakeImages{}, PublicURL: tt.publicURL})
+			req := httptest.NewRequest("GET", "http://chat.test/api/status/418", nil)
+			rr := httptest.NewRecorder()
+			handler.SetupRoutes().ServeHTTP(rr, req)
+
+			var response struct {
+				Data models.HTTPStatusResponse `json:"data"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			if response.Data.ImageURL != tt.want {
+				t.Errorf("Expected image URL %q, got %q", tt.want, response.Data.ImageURL)
+			}
+		})
+	}
+}
+
+// readEvent reads the next event or comment block of a stream
+func readEvent(t *testing.T, r *bufio.Reader) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestStreamMessages(t *testing.T) {
+	events := storage.NewBroker(0)
+	handler := NewHandlerWithConfig(storage.NewMemoryStorage(), Config{
+		Auth:      fakeAuth{},
+		Images:    fakeImages{},
+		Events:    events,
+		Heartbeat: 50 * time.Millisecond,
+	})
+	server := httptest.NewServer(handler.SetupRoutes())
+	defer server.Close()
+
+	connect := func(lastEventID string) (*http.Response, *bufio.Reader) {
+		req, _ := http.NewRequest("GET", server.URL+"/api/messages/stream", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Expected an event stream, got %q", ct)
+		}
+		r := bufio.NewReader(resp.Body)
+		readEvent(t, r) // retry
+		return resp, r
+	}
+
+	resp, stream := connect("")
+	handler.storage.Create("testuser", "hello")
+	handler.storage.Update(1, "hello again")
+	handler.storage.Delete(1)
+
+	for i, want := range []string{"created", "updated", "deleted"} {
+		lines := readEvent(t, stream)
+		if len(lines) != 3 || lines[0] != fmt.Sprintf("id: %d", i+1) || lines[1] != "event: "+want {
+			t.Fatalf("Expected %s event %d, got %q", want, i+1, lines)
+		}
+		var msg models.Message
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &msg); err != nil || msg.ID != 1 {
+			t.Errorf("Expected message 1 as data, got %q, %v", lines[2], err)
+		}
+	}
+	if lines := readEvent(t, stream); len(lines) != 1 || lines[0] != ": heartbeat" {
+		t.Errorf("Expected a heartbeat, got %q", lines)
+	}
+	resp.Body.Close()
+
+	resp, stream = connect("1")
+	for _, want := range []string{"id: 2", "id: 3"} {
+		if lines := readEvent(t, stream); len(lines) == 0 || lines[0] != want {
+			t.Errorf("Expected replayed event %q, got %q", want, lines)
+		}
+	}
+	resp.Body.Close()
+
+	resp, stream = connect("42")
+	if lines := readEvent(t, stream); len(lines) == 0 || lines[0] != "event: reset" {
+		t.Errorf("Expected a reset event for an unknown ID, got %q", lines)
+	}
+	resp.Body.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for events.Subscribers() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := events.Subscribers(); n != 0 {
+		t.Errorf("Expected disconnected subscribers to be removed, got %d", n)
+	}
+}
+
+func TestCreateMessageRejectsInvalidBodies(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		wantStatus  int
+		wantFields  []string
+	}{
+		{"username omitted", `{"content":"hi"}`, "application/json", http.StatusCreated, nil},
+		{"missing content", `{"username":"testuser"}`, "application/json", http.StatusUnprocessableEntity, []string{"content"}},
+		{"wrong type", `{"content":42}`, "application/json", http.StatusUnprocessableEntity, []string{"content"}},
+		{"unknown field", `{"content":"hi","pinned":true}`, "application/json", http.StatusUnprocessableEntity, []string{"pinned"}},
+		{"trailing data", `{"content":"hi"}{"content":"again"}`, "application/json", http.StatusBadRequest, nil},
+		{"not json", `content=hi`, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType, nil},
+		{"too large", `{"content":"` + strings.Repeat("a", 2<<20) + `"}`, "application/json", http.StatusRequestEntityTooLarge, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/messages", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %v, got %v: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			var response models.APIResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			var fields []string
+			for _, f := range response.Fields {
+				fields = append(fields, f.Field)
+			}
+			if len(fields) != len(tt.wantFields) || (len(fields) > 0 && fields[0] != tt.wantFields[0]) {
+				t.Errorf("Expected field errors for %v, got %+v", tt.wantFields, response.Fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// openAuthDatabase opens the course backend's database. It takes a
+// DATABASE_URL of the forms the backend accepts: postgres:// URLs and
+// sqlite:// URLs or plain SQLite paths.
+func openAuthDatabase(databaseURL string) (*sql.DB, error) {
+	driver, dsn := "sqlite3", databaseURL
+	switch {
+	case strings.HasPrefix(databaseURL, "postgres://"), strings.HasPrefix(databaseURL, "postgresql://"):
+		driver = "postgres"
+	case strings.HasPrefix(databaseURL, "sqlite://"):
+		dsn = strings.TrimPrefix(databaseURL, "sqlite://")
+	case strings.HasPrefix(databaseURL, "sqlite3://"):
+		dsn = strings.TrimPrefix(databaseURL, "sqlite3://")
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// config holds the server settings. Every field is a command-line flag
+// whose default comes from the environment variable named in its usage.
+type config struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	CORSOrigins     string
+	DatabasePath    string
+	SnapshotPath    string
+	JWTSecret       string
+	JWKSURL         string
+	AuthDatabaseURL string
+	DevAuth         bool
+	PublicURL       string
+	CatCacheDir     string
+}
+
+// parseConfig reads the configuration from the environment and then from
+// args, so flags take precedence
+func parseConfig(fs *flag.FlagSet, args []string) (*config, error) {
+	c := &config{
+		Addr:            getEnv("ADDR", ":8080"),
+		CORSOrigins:     getEnv("CORS_ORIGINS", "http://localhost:3000"),
+		DatabasePath:    getEnv("DATABASE_PATH", ""),
+		SnapshotPath:    getEnv("SNAPSHOT_PATH", ""),
+		JWTSecret:       getEnv("JWT_SECRET", ""),
+		JWKSURL:         getEnv("JWKS_URL", ""),
+		AuthDatabaseURL: getEnv("AUTH_DATABASE_URL", ""),
+		PublicURL:       getEnv("PUBLIC_URL", ""),
+		CatCacheDir:     getEnv("CAT_CACHE_DIR", ""),
+	}
+	devAuth, err := getEnvAsBool("DEV_AUTH", false)
+	if err != nil {
+		return nil, err
+	}
+	c.DevAuth = devAuth
+	durations := []struct {
+		dst      *time.Duration
+		env      string
+		fallback time.Duration
+	}{
+		{&c.ReadTimeout, "READ_TIMEOUT", 15 * time.Second},
+		{&c.WriteTimeout, "WRITE_TIMEOUT", 15 * time.Second},
+		{&c.IdleTimeout, "IDLE_TIMEOUT", 60 * time.Second},
+		{&c.ShutdownTimeout, "SHUTDOWN_TIMEOUT", 10 * time.Second},
+	}
+	for _, d := range durations {
+		if *d.dst, err = getEnvAsDuration(d.env, d.fallback); err != nil {
+			return nil, err
+		}
+	}
+
+	fs.StringVar(&c.Addr, "addr", c.Addr, "address to listen on (env ADDR)")
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "HTTP read timeout (env READ_TIMEOUT)")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "HTTP write timeout, not applied to event streams (env WRITE_TIMEOUT)")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "HTTP idle timeout (env IDLE_TIMEOUT)")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time to finish in-flight requests on shutdown (env SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&c.CORSOrigins, "cors-origins", c.CORSOrigins, "comma-separated allowed origins (env CORS_ORIGINS)")
+	fs.StringVar(&c.DatabasePath, "database-path", c.DatabasePath, "SQLite database file, empty to keep messages in memory (env DATABASE_PATH)")
+	fs.StringVar(&c.SnapshotPath, "snapshot-path", c.SnapshotPath, "file the in-memory messages are loaded from on start and saved to on shutdown (env SNAPSHOT_PATH)")
+	fs.StringVar(&c.JWTSecret, "jwt-secret", c.JWTSecret, "secret of the course backend's access tokens (env JWT_SECRET)")
+	fs.StringVar(&c.JWKSURL, "jwks-url", c.JWKSURL, "the course backend's /.well-known/jwks.json, to verify RS256 and EdDSA tokens (env JWKS_URL)")
+	fs.StringVar(&c.AuthDatabaseURL, "auth-database-url", c.AuthDatabaseURL, "the course backend's database, to reject tokens it revoked (env AUTH_DATABASE_URL)")
+	fs.BoolVar(&c.DevAuth, "dev-auth", c.DevAuth, "accept any bearer token as the username, for local development only (env DEV_AUTH)")
+	fs.StringVar(&c.PublicURL, "public-url", c.PublicURL, "externally visible base URL, empty to use the request host (env PUBLIC_URL)")
+	fs.StringVar(&c.CatCacheDir, "cat-cache-dir", c.CatCacheDir, "directory caching http.cat images, empty for memory only (env CAT_CACHE_DIR)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if c.DatabasePath != "" && c.SnapshotPath != "" {
+		return nil, fmt.Errorf("snapshot-path only applies to in-memory storage, not with database-path")
+	}
+	backendAuth := c.JWTSecret != "" || c.JWKSURL != ""
+	if !backendAuth && !c.DevAuth {
+		return nil, fmt.Errorf("jwt-secret or jwks-url is required to accept writes; use -dev-auth to run without the course backend")
+	}
+	if backendAuth && c.DevAuth {
+		return nil, fmt.Errorf("dev-auth cannot be combined with jwt-secret or jwks-url")
+	}
+	if c.JWTSecret != "" && c.JWKSURL != "" {
+		return nil, fmt.Errorf("use either jwt-secret or jwks-url, like the course backend signs with either")
+	}
+	if c.AuthDatabaseURL != "" && !backendAuth {
+		return nil, fmt.Errorf("auth-database-url requires jwt-secret or jwks-url")
+	}
+	if c.ShutdownTimeout <= 0 {
+		return nil, fmt.Errorf("shutdown-timeout must be positive")
+	}
+	return c, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvAsBool(key string, fallback bool) (bool, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+func getEnvAsDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback, nil
+	}
+	// Plain numbers are seconds
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
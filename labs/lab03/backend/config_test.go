@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("ADDR", ":9090")
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("READ_TIMEOUT", "5")
+	t.Setenv("WRITE_TIMEOUT", "30s")
+
+	cfg, err := parseConfig(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-write-timeout=1m", "-snapshot-path=messages.json"})
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if cfg.Addr != ":9090" || cfg.ReadTimeout != 5*time.Second {
+		t.Errorf("Expected settings from the environment, got %+v", cfg)
+	}
+	if cfg.WriteTimeout != time.Minute || cfg.SnapshotPath != "messages.json" {
+		t.Errorf("Expected flags to override the environment, got %+v", cfg)
+	}
+	if cfg.IdleTimeout != 60*time.Second || cfg.CORSOrigins != "http://localhost:3000" {
+		t.Errorf("Expected defaults for unset settings, got %+v", cfg)
+	}
+
+	t.Setenv("DATABASE_PATH", "messages.db")
+	if _, err := parseConfig(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-snapshot-path=messages.json"}); err == nil {
+		t.Error("Expected an error for a snapshot with SQLite storage")
+	}
+	t.Setenv("IDLE_TIMEOUT", "soon")
+	if _, err := parseConfig(flag.NewFlagSet("test", flag.ContinueOnError), nil); err == nil {
+		t.Error("Expected an error for an invalid duration")
+	}
+}
+
+func TestParseConfigRequiresAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		wantErr bool
+	}{
+		{"no authentication", nil, nil, true},
+		{"jwt secret", map[string]string{"JWT_SECRET": "secret"}, nil, false},
+		{"dev auth flag", nil, []string{"-dev-auth"}, false},
+		{"dev auth env", map[string]string{"DEV_AUTH": "true"}, nil, false},
+		{"invalid dev auth env", map[string]string{"DEV_AUTH": "maybe"}, nil, true},
+		{"dev auth with secret", map[string]string{"JWT_SECRET": "secret"}, []string{"-dev-auth"}, true},
+		{"jwks url", nil, []string{"-jwks-url=http://localhost:8081/.well-known/jwks.json"}, false},
+		{"jwks url with secret", map[string]string{"JWT_SECRET": "secret", "JWKS_URL": "http://localhost:8081/.well-known/jwks.json"}, nil, true},
+		{"auth database with secret", map[string]string{"JWT_SECRET": "secret", "AUTH_DATABASE_URL": "postgres://localhost/coursedb"}, nil, false},
+		{"auth database with dev auth", map[string]string{"AUTH_DATABASE_URL": "backend.db"}, []string{"-dev-auth"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := parseConfig(flag.NewFlagSet("test", flag.ContinueOnError), tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -2,4 +2,28 @@ module lab03-backend
 
 go 1.24
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/gorilla/mux v1.8.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/timur-harin/sum25-go-flutter-course/pkg v0.0.0
+	github.com/timur-harin/sum25-go-flutter-course/pkg/auth v0.0.0
+	golang.org/x/sync v0.15.0
+)
+
+require (
+	github.com/gabriel-vasile/mimetype v1.4.6 // indirect
+	github.com/go-playground/locales v0.14.1 // indirect
+	github.com/go-playground/universal-translator v0.18.1 // indirect
+	github.com/go-playground/validator/v10 v10.22.1 // indirect
+	github.com/golang-jwt/jwt/v4 v4.5.2 // indirect
+	github.com/leodido/go-urn v1.4.0 // indirect
+	golang.org/x/crypto v0.39.0 // indirect
+	golang.org/x/net v0.40.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.26.0 // indirect
+)
+
+replace github.com/timur-harin/sum25-go-flutter-course/pkg => ../../../pkg
+
+replace github.com/timur-harin/sum25-go-flutter-course/pkg/auth => ../../../pkg/auth
@@ -0,0 +1,224 @@
+package images
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCacheSize is the cache size used by Default
+const DefaultCacheSize = 32 << 20
+
+// Cache is an LRU cache of images bounded by their total size. With a
+// directory it also keeps the images on disk, so they survive restarts.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	dir      string
+	// order holds *cacheEntry values, most recently used first
+	order   *list.List
+	entries map[int]*list.Element
+}
+
+type cacheEntry struct {
+	code  int
+	image *Image
+}
+
+// NewMemoryCache creates a cache holding at most maxBytes of image data in
+// memory only
+func NewMemoryCache(maxBytes int) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[int]*list.Element),
+	}
+}
+
+// NewCache creates a cache holding at most maxBytes of image data. If dir
+// is not empty, images are also written there, and the ones already there
+// are loaded, most recently written first, as far as they fit.
+func NewCache(maxBytes int, dir string) (*Cache, error) {
+	c := NewMemoryCache(maxBytes)
+	if dir == "" {
+		return c, nil
+	}
+	c.dir = dir
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load reads the images in the cache directory, oldest first, so the
+// newest end up at the front and the oldest are evicted if they do not fit
+func (c *Cache) load() error {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	type cached struct {
+		code  int
+		info  os.FileInfo
+		image *Image
+	}
+	var found []cached
+	for _, file := range files {
+		code, err := strconv.Atoi(strings.TrimSuffix(file.Name(), ".img"))
+		if err != nil || file.IsDir() || !ValidCode(code) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		image, err := readImage(filepath.Join(c.dir, file.Name()))
+		if err != nil {
+			// A partially written file is just a miss
+			continue
+		}
+		found = append(found, cached{code, info, image})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].info.ModTime().Before(found[j].info.ModTime()) })
+	for _, f := range found {
+		_, evicted := c.add(f.code, f.image)
+		c.removeFiles(evicted)
+	}
+	return nil
+}
+
+// Get returns the cached image for code and marks it as recently used
+func (c *Cache) Get(code int) (*Image, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[code]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).image, true
+}
+
+// Put caches the image for code, evicting the least recently used images
+// until it fits. Images larger than the whole cache are not kept. Files are
+// written and removed after the lock is released, so a slow disk does not
+// hold up Get.
+func (c *Cache) Put(code int, image *Image) {
+	c.mu.Lock()
+	kept, evicted := c.add(code, image)
+	c.mu.Unlock()
+
+	c.removeFiles(evicted)
+	if !kept || c.dir == "" {
+		return
+	}
+	// The disk copy is best effort; the image is cached in memory anyway
+	if err := writeImage(c.path(code), image); err != nil {
+		return
+	}
+	// If the image was evicted while it was written, its removal may have
+	// come first. A replacement writes its own file.
+	c.mu.Lock()
+	_, ok := c.entries[code]
+	c.mu.Unlock()
+	if !ok {
+		os.Remove(c.path(code))
+	}
+}
+
+// Len returns the number of cached images
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// add inserts the image and evicts entries over the limit. It reports
+// whether the image was kept and returns the codes of evicted images, whose
+// files the caller removes. c.mu must be held.
+func (c *Cache) add(code int, image *Image) (bool, []int) {
+	if len(image.Data) > c.maxBytes {
+		return false, nil
+	}
+	if elem, ok := c.entries[code]; ok {
+		// The file is overwritten, not removed
+		c.remove(elem)
+	}
+	c.entries[code] = c.order.PushFront(&cacheEntry{code: code, image: image})
+	c.size += len(image.Data)
+	var evicted []int
+	for c.size > c.maxBytes {
+		evicted = append(evicted, c.remove(c.order.Back()))
+	}
+	return true, evicted
+}
+
+// remove drops an entry from memory and returns its code
+func (c *Cache) remove(elem *list.Element) int {
+	entry := c.order.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.code)
+	c.size -= len(entry.image.Data)
+	return entry.code
+}
+
+// removeFiles deletes the disk copies of evicted images
+func (c *Cache) removeFiles(codes []int) {
+	if c.dir == "" {
+		return
+	}
+	for _, code := range codes {
+		os.Remove(c.path(code))
+	}
+}
+
+func (c *Cache) path(code int) string {
+	return filepath.Join(c.dir, strconv.Itoa(code)+".img")
+}
+
+// writeImage stores the content type on the first line followed by the
+// data. It writes to a temporary file first so readers never see half of
+// an image.
+func writeImage(path string, image *Image) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	w.WriteString(image.ContentType + "\n")
+	w.Write(image.Data)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readImage reads a file written by writeImage
+func readImage(path string) (*Image, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	contentType, data, ok := bytes.Cut(raw, []byte("\n"))
+	if !ok || !strings.HasPrefix(string(contentType), "image/") {
+		return nil, fmt.Errorf("malformed cache file %s", path)
+	}
+	return NewImage(data, string(contentType)), nil
+}
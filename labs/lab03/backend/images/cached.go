@@ -0,0 +1,113 @@
+package images
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultNegativeTTL is how long Default remembers that upstream failed or
+// had no image for a code
+const DefaultNegativeTTL = 30 * time.Second
+
+// Cached serves images from a cache, fetching misses from upstream. When
+// several requests miss the same code at once, upstream is asked only once.
+// Upstream errors, including ErrNotFound, are remembered for a short time
+// so that a failing upstream is not asked again on every request.
+type Cached struct {
+	upstream    Provider
+	cache       *Cache
+	group       singleflight.Group
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu sync.Mutex
+	// failures holds the remembered errors by code
+	failures map[int]failure
+}
+
+type failure struct {
+	err     error
+	expires time.Time
+}
+
+// NewCached caches the images of upstream in cache and its errors for
+// negativeTTL; 0 disables caching errors
+func NewCached(upstream Provider, cache *Cache, negativeTTL time.Duration) *Cached {
+	return &Cached{
+		upstream:    upstream,
+		cache:       cache,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		failures:    make(map[int]failure),
+	}
+}
+
+// Image implements Provider
+func (p *Cached) Image(ctx context.Context, code int) (*Image, error) {
+	if image, ok := p.cache.Get(code); ok {
+		return image, nil
+	}
+	if err := p.failed(code); err != nil {
+		return nil, err
+	}
+
+	// The shared fetch must not fail for everyone when the request that
+	// started it goes away; the upstream client has its own timeout
+	fetchCtx := context.WithoutCancel(ctx)
+	result := p.group.DoChan(strconv.Itoa(code), func() (interface{}, error) {
+		image, err := p.upstream.Image(fetchCtx, code)
+		if err != nil {
+			p.remember(code, err)
+			return nil, err
+		}
+		p.cache.Put(code, image)
+		return image, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*Image), nil
+	}
+}
+
+// failed returns the remembered error for code, if it has not expired
+func (p *Cached) failed(code int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, ok := p.failures[code]
+	if !ok {
+		return nil
+	}
+	if !p.now().Before(f.expires) {
+		delete(p.failures, code)
+		return nil
+	}
+	return f.err
+}
+
+// remember keeps err for code for the negative TTL. Only valid codes are
+// kept, which bounds the map.
+func (p *Cached) remember(code int, err error) {
+	if p.negativeTTL <= 0 || !ValidCode(code) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures[code] = failure{err: err, expires: p.now().Add(p.negativeTTL)}
+}
+
+// Default fetches from http.cat through an in-memory cache and falls back to
+// the bundled images
+func Default() Provider {
+	upstream := NewHTTPCat(DefaultHTTPCatURL, 10*time.Second)
+	return WithFallback(NewCached(upstream, NewMemoryCache(DefaultCacheSize), DefaultNegativeTTL), Fallback{})
+}
@@ -0,0 +1,79 @@
+package images
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// fallbackFiles holds a picture for every status code with an
+// http.StatusText, named like 404.svg, and one per class, named like 4xx.svg
+//
+//go:embed fallback/*.svg
+var fallbackFiles embed.FS
+
+// fallbackImages maps the file names in fallbackFiles, without extension,
+// to their images
+var fallbackImages = loadFallbacks()
+
+func loadFallbacks() map[string]*Image {
+	images := make(map[string]*Image)
+	files, err := fs.Glob(fallbackFiles, "fallback/*.svg")
+	if err != nil {
+		panic(err)
+	}
+	for _, file := range files {
+		data, err := fallbackFiles.ReadFile(file)
+		if err != nil {
+			panic(err)
+		}
+		image := NewImage(data, "image/svg+xml")
+		image.Fallback = true
+		images[strings.TrimSuffix(path.Base(file), ".svg")] = image
+	}
+	return images
+}
+
+// Fallback serves the bundled pictures, so images are available without
+// network access. Codes without a picture of their own get the one of
+// their class, such as 4xx for 499.
+type Fallback struct{}
+
+// Image implements Provider
+func (Fallback) Image(ctx context.Context, code int) (*Image, error) {
+	if !ValidCode(code) {
+		return nil, ErrNotFound
+	}
+	if image, ok := fallbackImages[strconv.Itoa(code)]; ok {
+		return image, nil
+	}
+	if image, ok := fallbackImages[strconv.Itoa(code/100)+"xx"]; ok {
+		return image, nil
+	}
+	return nil, ErrNotFound
+}
+
+// WithFallback serves images from primary, and from fallback whenever
+// primary fails or has no image
+func WithFallback(primary, fallback Provider) Provider {
+	return &withFallback{primary: primary, fallback: fallback}
+}
+
+type withFallback struct {
+	primary  Provider
+	fallback Provider
+}
+
+func (p *withFallback) Image(ctx context.Context, code int) (*Image, error) {
+	image, err := p.primary.Image(ctx, code)
+	if err == nil {
+		return image, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return p.fallback.Image(ctx, code)
+}
@@ -0,0 +1,66 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultHTTPCatURL is where HTTPCat fetches images from by default
+const DefaultHTTPCatURL = "https://http.cat"
+
+// maxImageSize limits how much of an upstream response is read
+const maxImageSize = 5 << 20
+
+// HTTPCat fetches images from http.cat or a compatible server
+type HTTPCat struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPCat fetches from baseURL, giving up on a request after timeout
+func NewHTTPCat(baseURL string, timeout time.Duration) *HTTPCat {
+	return &HTTPCat{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Image implements Provider
+func (p *HTTPCat) Image(ctx context.Context, code int) (*Image, error) {
+	if !ValidCode(code) {
+		return nil, ErrNotFound
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%d", p.baseURL, code), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch image: upstream returned %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("failed to fetch image: unexpected content type %q", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("failed to read image: larger than %d bytes", maxImageSize)
+	}
+	return NewImage(data, contentType), nil
+}
@@ -0,0 +1,243 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProvider returns a fixed image, blocking until release is closed
+type countingProvider struct {
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+func (p *countingProvider) Image(ctx context.Context, code int) (*Image, error) {
+	p.calls.Add(1)
+	if p.release != nil {
+		<-p.release
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return NewImage([]byte("cat"), "image/jpeg"), nil
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, err := NewCache(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put(200, NewImage([]byte("aaaa"), "image/jpeg"))
+	cache.Put(404, NewImage([]byte("bbbb"), "image/jpeg"))
+	cache.Get(200)
+	cache.Put(500, NewImage([]byte("cccc"), "image/jpeg"))
+
+	if _, ok := cache.Get(404); ok {
+		t.Error("Expected the least recently used image to be evicted")
+	}
+	if _, ok := cache.Get(200); !ok {
+		t.Error("Expected the recently used image to stay")
+	}
+	cache.Put(418, NewImage([]byte("too large to fit"), "image/jpeg"))
+	if _, ok := cache.Get(418); ok || cache.Len() != 2 {
+		t.Errorf("Expected an oversized image to be skipped, got %d entries", cache.Len())
+	}
+}
+
+func TestCachePersistsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewCache(100, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := NewImage([]byte("cat"), "image/jpeg")
+	cache.Put(200, original)
+
+	reopened, err := NewCache(100, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, ok := reopened.Get(200)
+	if !ok {
+		t.Fatal("Expected the image to be loaded from disk")
+	}
+	if image.ETag != original.ETag || image.ContentType != "image/jpeg" {
+		t.Errorf("Expected the same image, got %+v", image)
+	}
+
+	// Loading into a smaller cache keeps it within its bound
+	small, err := NewCache(2, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small.Len() != 0 {
+		t.Errorf("Expected images over the limit to be dropped, got %d", small.Len())
+	}
+}
+
+func TestCachedDeduplicatesMisses(t *testing.T) {
+	upstream := &countingProvider{release: make(chan struct{})}
+	cache, _ := NewCache(100, "")
+	provider := NewCached(upstream, cache, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := provider.Image(context.Background(), 200); err != nil {
+				t.Errorf("Image failed: %v", err)
+			}
+		}()
+	}
+	// Give the goroutines time to pile up on the same miss
+	time.Sleep(50 * time.Millisecond)
+	close(upstream.release)
+	wg.Wait()
+
+	if _, err := provider.Image(context.Background(), 200); err != nil {
+		t.Fatalf("Image failed: %v", err)
+	}
+	if calls := upstream.calls.Load(); calls != 1 {
+		t.Errorf("Expected one upstream call, got %d", calls)
+	}
+}
+
+func TestCachedRemembersFailures(t *testing.T) {
+	upstream := &countingProvider{err: errors.New("upstream unavailable")}
+	provider := NewCached(upstream, NewMemoryCache(100), time.Minute)
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := provider.Image(context.Background(), 404); err == nil || err.Error() != "upstream unavailable" {
+			t.Fatalf("Expected the upstream error, got %v", err)
+		}
+	}
+	if calls := upstream.calls.Load(); calls != 1 {
+		t.Errorf("Expected one upstream call within the TTL, got %d", calls)
+	}
+
+	now = now.Add(time.Minute)
+	upstream.err = nil
+	if _, err := provider.Image(context.Background(), 404); err != nil {
+		t.Fatalf("Expected upstream to be asked again after the TTL, got %v", err)
+	}
+	if calls := upstream.calls.Load(); calls != 2 {
+		t.Errorf("Expected a second upstream call, got %d", calls)
+	}
+}
+
+func TestCacheRemovesEvictedFiles(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewCache(6, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put(200, NewImage([]byte("aaaa"), "image/jpeg"))
+	cache.Put(404, NewImage([]byte("bbbb"), "image/jpeg"))
+
+	if _, err := os.Stat(filepath.Join(dir, "200.img")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the file of the evicted image to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "404.img")); err != nil {
+		t.Errorf("Expected the cached image on disk, got %v", err)
+	}
+}
+
+func TestHTTPCat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/200":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("cat"))
+		case "/500":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>"))
+		case "/504":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	provider := NewHTTPCat(server.URL, 50*time.Millisecond)
+
+	image, err := provider.Image(context.Background(), 200)
+	if err != nil || string(image.Data) != "cat" || image.ContentType != "image/jpeg" {
+		t.Errorf("Expected the upstream image, got %+v, %v", image, err)
+	}
+	if _, err := provider.Image(context.Background(), 404); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := provider.Image(context.Background(), 500); err == nil {
+		t.Error("Expected an error for a response that is not an image")
+	}
+	if _, err := provider.Image(context.Background(), 504); err == nil {
+		t.Error("Expected a slow upstream to time out")
+	}
+}
+
+func TestFallbackImages(t *testing.T) {
+	for code := 100; code <= 599; code++ {
+		image, err := Fallback{}.Image(context.Background(), code)
+		if err != nil {
+			t.Fatalf("Expected a fallback image for %d, got %v", code, err)
+		}
+		want := strconv.Itoa(code)
+		if http.StatusText(code) == "" {
+			want = strconv.Itoa(code/100) + "xx"
+		}
+		if !strings.Contains(string(image.Data), ">"+want+"<") {
+			t.Errorf("Expected the image for %d to show %s", code, want)
+		}
+	}
+
+	// Every bundled file must be well-formed SVG
+	for name, image := range fallbackImages {
+		dec := xml.NewDecoder(bytes.NewReader(image.Data))
+		for {
+			_, err := dec.Token()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Errorf("Malformed fallback image %s: %v", name, err)
+				break
+			}
+		}
+	}
+}
+
+func TestWithFallback(t *testing.T) {
+	provider := WithFallback(&countingProvider{err: errors.New("offline")}, Fallback{})
+
+	image, err := provider.Image(context.Background(), 418)
+	if err != nil {
+		t.Fatalf("Image failed: %v", err)
+	}
+	if !image.Fallback || image.ContentType != "image/svg+xml" {
+		t.Errorf("Expected a fallback SVG, got %+v", image)
+	}
+	if !strings.Contains(string(image.Data), "I'm a teapot") {
+		t.Errorf("Expected the status description in the image, got %s", image.Data)
+	}
+	if _, err := provider.Image(context.Background(), 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an invalid code, got %v", err)
+	}
+}
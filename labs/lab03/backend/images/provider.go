@@ -0,0 +1,43 @@
+// Package images serves the status code pictures of the /api/cat endpoint
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrNotFound is returned when a provider has no image for a status code
+var ErrNotFound = errors.New("image not found")
+
+// Image is a status code picture
+type Image struct {
+	Data        []byte
+	ContentType string
+	// ETag is a strong, quoted entity tag derived from Data
+	ETag string
+	// Fallback marks a bundled image served because the real one could not
+	// be fetched; clients should not keep it for long
+	Fallback bool
+}
+
+// NewImage wraps data and computes its ETag
+func NewImage(data []byte, contentType string) *Image {
+	sum := sha256.Sum256(data)
+	return &Image{
+		Data:        data,
+		ContentType: contentType,
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// Provider returns the image for an HTTP status code
+type Provider interface {
+	Image(ctx context.Context, code int) (*Image, error)
+}
+
+// ValidCode reports whether code is in the range of HTTP status codes
+func ValidCode(code int) bool {
+	return code >= 100 && code <= 599
+}
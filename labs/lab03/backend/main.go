@@ -1,58 +1,156 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/cors"
+
 	"lab03-backend/api"
+	"lab03-backend/images"
 	"lab03-backend/storage"
 )
 
-// CORS middleware для ограничения источника (Origin)
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-		// Для preflight-запросов OPTIONS просто возвращаем 200
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+func main() {
+	// Настройки берутся из переменных окружения и флагов командной строки
+	cfg, err := parseConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Создаём хранилище: SQLite, если задан путь к базе, иначе в памяти
+	var store storage.MessageStore
+	var memory *storage.MemoryStorage
+	if cfg.DatabasePath != "" {
+		sqliteStorage, err := storage.NewSQLiteStorage(cfg.DatabasePath)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
 		}
+		defer sqliteStorage.Close()
+		store = sqliteStorage
+	} else {
+		memory = storage.NewMemoryStorage()
+		store = memory
+	}
 
-		next.ServeHTTP(w, r)
-	})
-}
+	// Хранилище в памяти восстанавливается из снимка, если он есть
+	if memory != nil && cfg.SnapshotPath != "" {
+		err := memory.LoadSnapshot(cfg.SnapshotPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			log.Printf("No snapshot at %s, starting empty", cfg.SnapshotPath)
+		case err != nil:
+			log.Fatalf("Failed to load snapshot: %v", err)
+		default:
+			log.Printf("Loaded %d messages from %s", memory.Count(), cfg.SnapshotPath)
+		}
+	}
 
-func main() {
-	// Создаём хранилище в памяти
-	memStorage := storage.NewMemoryStorage()
+	// Изменения сообщений рассылаются подписчикам /api/messages/stream
+	events := storage.NewBroker(storage.DefaultReplaySize)
+	config := api.Config{PublicURL: cfg.PublicURL, Events: events}
+
+	// Токены бэкенда курса подтверждают личность пользователя. Без бэкенда
+	// сервер запускается только с явным -dev-auth
+	if cfg.DevAuth {
+		log.Println("WARNING: -dev-auth accepts any bearer token as the username; use it for local development only")
+		config.Auth = api.DevAuthenticator{}
+	} else {
+		jwtConfig := api.JWTConfig{Secret: cfg.JWTSecret, JWKSURL: cfg.JWKSURL}
+		// Отозванные бэкендом токены видны только через его базу данных
+		if cfg.AuthDatabaseURL != "" {
+			db, err := openAuthDatabase(cfg.AuthDatabaseURL)
+			if err != nil {
+				log.Fatalf("Failed to open auth database: %v", err)
+			}
+			defer db.Close()
+			jwtConfig.Revocations = jwtservice.NewSQLRevocationStore(db)
+		} else {
+			log.Println("No auth-database-url: tokens revoked by the course backend stay valid until they expire")
+		}
+		auth, err := api.NewJWTAuthenticator(jwtConfig)
+		if err != nil {
+			log.Fatalf("Failed to configure authentication: %v", err)
+		}
+		config.Auth = auth
+	}
+
+	// Картинки http.cat кэшируются в памяти и, если задан каталог, на диске
+	cache, err := images.NewCache(images.DefaultCacheSize, cfg.CatCacheDir)
+	if err != nil {
+		log.Fatalf("Failed to open image cache: %v", err)
+	}
+	upstream := images.NewHTTPCat(images.DefaultHTTPCatURL, 10*time.Second)
+	config.Images = images.WithFallback(images.NewCached(upstream, cache, images.DefaultNegativeTTL), images.Fallback{})
 
 	// Создаём обработчик API, передаём хранилище
-	handler := api.NewHandler(memStorage)
+	handler := api.NewHandlerWithConfig(store, config)
 
-	// Получаем настроенный роутер с маршрутами и middleware
+	// Получаем настроенный роутер с маршрутами
 	router := handler.SetupRoutes()
 
-	// Оборачиваем роутер в CORS middleware
-	corsRouter := corsMiddleware(router)
+	// CORS оборачивает весь роутер, чтобы preflight-запросы доходили до него
+	// даже для маршрутов, которые не принимают OPTIONS. Запросы идут
+	// с credentials, поэтому "*" в CORS_ORIGINS отклоняется
+	corsPolicy, err := cors.New(cors.Options{
+		AllowedOrigins:   cors.ParseOrigins(cfg.CORSOrigins),
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "Last-Event-ID"},
+		AllowCredentials: true,
+	})
+	if err != nil {
+		log.Fatalf("Invalid CORS configuration: %v", err)
+	}
+	corsRouter := corsPolicy.Handler(router)
 
 	// Конфигурируем сервер с таймаутами
 	server := &http.Server{
-		Addr:         ":8080",
+		Addr:         cfg.Addr,
 		Handler:      corsRouter,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
 	}
+	// Потоки событий не завершаются сами, поэтому закрываем их при остановке
+	server.RegisterOnShutdown(events.Close)
 
-	log.Println("Starting server on :8080")
+	// Запускаем сервер в отдельной горутине
+	go func() {
+		log.Printf("Starting server on %s", cfg.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	// Ждём SIGINT или SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down server...")
+
+	// Даём текущим запросам время завершиться
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
 
-	// Запускаем сервер, логируем ошибки
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Server error: %v", err)
+	// Сохраняем снимок после того, как все запросы завершились
+	if memory != nil && cfg.SnapshotPath != "" {
+		if err := memory.SaveSnapshot(cfg.SnapshotPath); err != nil {
+			log.Printf("Failed to save snapshot: %v", err)
+		} else {
+			log.Printf("Saved snapshot to %s", cfg.SnapshotPath)
+		}
 	}
+
+	log.Println("Server exited")
 }
@@ -11,10 +11,33 @@ type Message struct {
 	ID        int       `json:"id"`
 	// Username of the message author
 	Username  string    `json:"username"`
+	// UserID of the author in the course backend, 0 for messages posted
+	// with DevAuthenticator or before user IDs were stored
+	UserID    int       `json:"user_id,omitempty"`
 	// Content of the message
 	Content   string    `json:"content"`
 	// Timestamp when the message was created
 	Timestamp time.Time `json:"timestamp"`
+	// EditedAt is the time of the last edit, nil if never edited
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// DeletedAt marks a tombstone: the message was deleted and its content
+	// is no longer shown
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Deleted reports whether the message is a tombstone
+func (m *Message) Deleted() bool {
+	return m.DeletedAt != nil
+}
+
+// Revision is one version of a message's content
+type Revision struct {
+	// Number counts versions from 1, the original content
+	Number int `json:"revision"`
+	// Content of the message in this version
+	Content string `json:"content"`
+	// Timestamp when this version was written
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // CreateMessageRequest represents the request to create a new message
@@ -49,6 +72,40 @@ type APIResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 	// Error holds the error message when Success is false
 	Error   string      `json:"error,omitempty"`
+	// Pagination describes the page of list responses
+	Pagination *Pagination `json:"pagination,omitempty"`
+	// Fields lists the rejected fields of an invalid request body
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes why one field of a request body was rejected
+type FieldError struct {
+	// Field is the JSON name of the field, such as "content"
+	Field string `json:"field"`
+	// Rule is the check that failed, such as "required"
+	Rule string `json:"rule"`
+	// Message is a human-readable explanation
+	Message string `json:"message"`
+}
+
+// Pagination holds the cursors of a page of messages
+type Pagination struct {
+	// Limit is the page size that was applied
+	Limit int `json:"limit"`
+	// HasMore reports whether more messages exist in the direction paged
+	HasMore bool `json:"has_more"`
+	// After is passed as ?after= to fetch messages newer than this page
+	After int `json:"after,omitempty"`
+	// Before is passed as ?before= to fetch messages older than this page
+	Before int `json:"before,omitempty"`
+}
+
+// SearchResult is a message matching a search
+type SearchResult struct {
+	// Message is the matching message
+	Message *Message `json:"message"`
+	// Snippet is an HTML-escaped excerpt with matches wrapped in <mark>
+	Snippet string `json:"snippet"`
 }
 
 // NewMessage creates a new message with the current timestamp
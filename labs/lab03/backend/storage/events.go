@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"lab03-backend/models"
+)
+
+// DefaultReplaySize is how many events a Broker keeps for resuming clients
+// when none is specified
+const DefaultReplaySize = 256
+
+// subscriberBuffer is how many events may queue up for a subscriber before
+// it is considered too slow and dropped
+const subscriberBuffer = 64
+
+// EventType says what happened to a message
+type EventType string
+
+// Event types
+const (
+	EventCreated EventType = "created"
+	EventUpdated EventType = "updated"
+	EventDeleted EventType = "deleted"
+)
+
+// Event is a change to a message
+type Event struct {
+	// ID grows by one with every event published by a Broker
+	ID      uint64
+	Type    EventType
+	Message *models.Message
+}
+
+// Broker fans events out to subscribers and keeps the latest ones, so a
+// subscriber that lost its connection can catch up on what it missed
+type Broker struct {
+	mu     sync.Mutex
+	lastID uint64
+	// replay is a ring buffer of the latest events, start the oldest
+	replay      []Event
+	start       int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBroker creates a Broker replaying at most replaySize events
+func NewBroker(replaySize int) *Broker {
+	if replaySize <= 0 {
+		replaySize = DefaultReplaySize
+	}
+	return &Broker{
+		replay:      make([]Event, 0, replaySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends an event to every subscriber. Subscribers whose queue is
+// full are dropped rather than allowed to hold up the others; they can
+// resubscribe from the last event they received.
+func (b *Broker) Publish(eventType EventType, msg *models.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.lastID++
+	event := Event{ID: b.lastID, Type: eventType, Message: msg}
+	if len(b.replay) < cap(b.replay) {
+		b.replay = append(b.replay, event)
+	} else {
+		b.replay[b.start] = event
+		b.start = (b.start + 1) % len(b.replay)
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe starts delivering events. With a lastEventID above 0 it also
+// returns the buffered events after it; complete is false when some of
+// them are no longer buffered, or the ID is unknown, so the subscriber has
+// to reload its state.
+func (b *Broker) Subscribe(lastEventID uint64) (sub *Subscription, replay []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{broker: b, events: make(chan Event, subscriberBuffer)}
+	if b.closed {
+		close(sub.events)
+		return sub, nil, true
+	}
+	b.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil, true
+	}
+	oldest := b.lastID - uint64(len(b.replay)) + 1
+	if lastEventID > b.lastID || lastEventID+1 < oldest {
+		return sub, nil, false
+	}
+	for i := range b.replay {
+		event := b.replay[(b.start+i)%len(b.replay)]
+		if event.ID > lastEventID {
+			replay = append(replay, event)
+		}
+	}
+	return sub, replay, true
+}
+
+// Close ends every subscription and stops accepting events
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.drop(sub)
+	}
+}
+
+// drop removes a subscriber and closes its channel. The caller holds b.mu.
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Subscribers returns the number of active subscriptions
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Subscription receives the events of a Broker
+type Subscription struct {
+	broker *Broker
+	events chan Event
+}
+
+// Events returns the channel of events. It is closed when the subscription
+// ends, because of Close, Broker.Close or the subscriber falling behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
+
+// notifyingStore publishes the changes made through a MessageStore
+type notifyingStore struct {
+	MessageStore
+	broker *Broker
+}
+
+// WithEvents returns a MessageStore that publishes an event to broker for
+// every message created, updated, deleted or purged through it
+func WithEvents(store MessageStore, broker *Broker) MessageStore {
+	return &notifyingStore{MessageStore: store, broker: broker}
+}
+
+// Create implements MessageStore
+func (s *notifyingStore) Create(username, content string) (*models.Message, error) {
+	msg, err := s.MessageStore.Create(username, content)
+	if err == nil {
+		s.broker.Publish(EventCreated, msg)
+	}
+	return msg, err
+}
+
+// CreateByUser implements MessageStore
+func (s *notifyingStore) CreateByUser(userID int, username, content string) (*models.Message, error) {
+	msg, err := s.MessageStore.CreateByUser(userID, username, content)
+	if err == nil {
+		s.broker.Publish(EventCreated, msg)
+	}
+	return msg, err
+}
+
+// Update implements MessageStore
+func (s *notifyingStore) Update(id int, content string) (*models.Message, error) {
+	msg, err := s.MessageStore.Update(id, content)
+	if err == nil {
+		s.broker.Publish(EventUpdated, msg)
+	}
+	return msg, err
+}
+
+// Delete implements MessageStore
+func (s *notifyingStore) Delete(id int) error {
+	if err := s.MessageStore.Delete(id); err != nil {
+		return err
+	}
+	if msg, err := s.MessageStore.GetByID(id); err == nil {
+		s.broker.Publish(EventDeleted, msg)
+	}
+	return nil
+}
+
+// Purge implements MessageStore. Purging a message that was not deleted yet
+// publishes a deleted event.
+func (s *notifyingStore) Purge(id int) error {
+	msg, err := s.MessageStore.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.MessageStore.Purge(id); err != nil {
+		return err
+	}
+	if !msg.Deleted() {
+		now := time.Now()
+		msg.Content = ""
+		msg.DeletedAt = &now
+		s.broker.Publish(EventDeleted, msg)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"lab03-backend/models"
+)
+
+func TestBrokerReplay(t *testing.T) {
+	broker := NewBroker(3)
+	for i := 1; i <= 5; i++ {
+		broker.Publish(EventCreated, &models.Message{ID: i})
+	}
+
+	tests := []struct {
+		name         string
+		lastEventID  uint64
+		wantIDs      []uint64
+		wantComplete bool
+	}{
+		{"new subscriber", 0, nil, true},
+		{"buffered", 3, []uint64{4, 5}, true},
+		{"up to date", 5, nil, true},
+		{"oldest buffered", 2, []uint64{3, 4, 5}, true},
+		{"evicted", 1, nil, false},
+		{"unknown", 9, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete := broker.Subscribe(tt.lastEventID)
+			defer sub.Close()
+
+			if complete != tt.wantComplete {
+				t.Errorf("Expected complete %v, got %v", tt.wantComplete, complete)
+			}
+			var ids []uint64
+			for _, event := range replay {
+				ids = append(ids, event.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("Expected replay %v, got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+	if n := broker.Subscribers(); n != 0 {
+		t.Errorf("Expected closed subscriptions to be removed, got %d", n)
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	broker := NewBroker(0)
+	slow, _, _ := broker.Subscribe(0)
+	fast, _, _ := broker.Subscribe(0)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish(EventCreated, &models.Message{ID: i + 1})
+		<-fast.Events()
+	}
+	if n := broker.Subscribers(); n != 1 {
+		t.Errorf("Expected the slow subscriber to be dropped, got %d subscribers", n)
+	}
+	count := 0
+	for range slow.Events() {
+		count++
+	}
+	if count != subscriberBuffer {
+		t.Errorf("Expected %d queued events before the channel closed, got %d", subscriberBuffer, count)
+	}
+
+	broker.Close()
+	if _, ok := <-fast.Events(); ok {
+		t.Error("Expected Close to end the remaining subscriptions")
+	}
+	fast.Close()
+}
+
+func TestWithEvents(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			broker := NewBroker(0)
+			store := WithEvents(store, broker)
+			sub, _, _ := broker.Subscribe(0)
+			defer sub.Close()
+
+			msg, _ := store.Create("alice", "hello")
+			store.Update(msg.ID, "hello again")
+			store.Update(99, "missing")
+			store.Delete(msg.ID)
+			other, _ := store.Create("bob", "bye")
+			store.Purge(other.ID)
+
+			want := []struct {
+				eventType EventType
+				id        int
+				content   string
+			}{
+				{EventCreated, msg.ID, "hello"},
+				{EventUpdated, msg.ID, "hello again"},
+				{EventDeleted, msg.ID, ""},
+				{EventCreated, other.ID, "bye"},
+				{EventDeleted, other.ID, ""},
+			}
+			for i, w := range want {
+				select {
+				case event := <-sub.Events():
+					if event.ID != uint64(i+1) || event.Type != w.eventType || event.Message.ID != w.id || event.Message.Content != w.content {
+						t.Errorf("Expected %s of message %d with %q, got %+v %+v", w.eventType, w.id, w.content, event, event.Message)
+					}
+				default:
+					t.Fatalf("Expected %d events, got %d", len(want), i)
+				}
+			}
+			select {
+			case event := <-sub.Events():
+				t.Errorf("Expected no more events, got %+v", event)
+			default:
+			}
+		})
+	}
+}
@@ -1,34 +1,82 @@
 package storage
 
 import (
+	"errors"
+	"sort"
 	"sync"
+	"time"
+
 	"lab03-backend/models"
-	"errors"
 )
 
 // MemoryStorage implements in-memory storage for messages
 type MemoryStorage struct {
 	sync.RWMutex
 	messages map[int]*models.Message
-	nextID   int
+	// ids holds the keys of messages in ascending order, so pages are
+	// found by binary search instead of sorting every message
+	ids       []int
+	revisions map[int][]models.Revision
+	index     *index
+	nextID    int
 }
 
 // NewMemoryStorage creates a new in-memory storage instance
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		messages: make(map[int]*models.Message),
-		nextID:   1,
+		messages:  make(map[int]*models.Message),
+		revisions: make(map[int][]models.Revision),
+		index:     newIndex(),
+		nextID:    1,
 	}
 }
 
-// GetAll returns all messages
+// GetAll returns all messages ordered by ID
 func (ms *MemoryStorage) GetAll() []*models.Message {
 	ms.RLock()
 	defer ms.RUnlock()
 
-	result := make([]*models.Message, 0, len(ms.messages))
-	for _, msg := range ms.messages {
-		result = append(result, msg)
+	return ms.sorted()
+}
+
+// List implements MessageStore
+func (ms *MemoryStorage) List(page Page) (*MessagePage, error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	// ids[from:to] are the IDs between After and Before
+	from := sort.SearchInts(ms.ids, page.After+1)
+	to := len(ms.ids)
+	if page.Before != 0 {
+		to = sort.SearchInts(ms.ids, page.Before)
+	}
+	to = max(to, from)
+
+	limit := page.limit()
+	result := &MessagePage{HasMore: to-from > limit}
+	if result.HasMore {
+		if page.forward() {
+			to = from + limit
+		} else {
+			from = to - limit
+		}
+	}
+	result.Messages = ms.clones(ms.ids[from:to])
+	return result, nil
+}
+
+// sorted returns copies of the messages ordered by ID. The caller must hold
+// the lock.
+func (ms *MemoryStorage) sorted() []*models.Message {
+	return ms.clones(ms.ids)
+}
+
+// clones returns copies of the messages with ids. The caller must hold the
+// lock.
+func (ms *MemoryStorage) clones(ids []int) []*models.Message {
+	result := make([]*models.Message, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, clone(ms.messages[id]))
 	}
 	return result
 }
@@ -42,21 +90,31 @@ func (ms *MemoryStorage) GetByID(id int) (*models.Message, error) {
 	if !ok {
 		return nil, ErrMessageNotFound
 	}
-	return msg, nil
+	return clone(msg), nil
 }
 
 // Create adds a new message to storage
 func (ms *MemoryStorage) Create(username, content string) (*models.Message, error) {
+	return ms.CreateByUser(0, username, content)
+}
+
+// CreateByUser implements MessageStore
+func (ms *MemoryStorage) CreateByUser(userID int, username, content string) (*models.Message, error) {
 	ms.Lock()
 	defer ms.Unlock()
 
 	msg := models.NewMessage(ms.nextID, username, content)
+	msg.UserID = userID
 	ms.messages[ms.nextID] = msg
+	// IDs only grow, so the order is kept by appending
+	ms.ids = append(ms.ids, msg.ID)
+	ms.revisions[msg.ID] = []models.Revision{{Number: 1, Content: content, Timestamp: msg.Timestamp}}
+	ms.index.set(msg.ID, content)
 	ms.nextID++
-	return msg, nil
+	return clone(msg), nil
 }
 
-// Update modifies an existing message
+// Update modifies an existing message and records the new revision
 func (ms *MemoryStorage) Update(id int, content string) (*models.Message, error) {
 	ms.Lock()
 	defer ms.Unlock()
@@ -65,31 +123,110 @@ func (ms *MemoryStorage) Update(id int, content string) (*models.Message, error)
 	if !ok {
 		return nil, ErrMessageNotFound
 	}
+	if msg.Deleted() {
+		return nil, ErrMessageDeleted
+	}
+
+	now := time.Now()
 	msg.Content = content
-	return msg, nil
+	msg.EditedAt = &now
+	ms.revisions[id] = append(ms.revisions[id], models.Revision{
+		Number:    len(ms.revisions[id]) + 1,
+		Content:   content,
+		Timestamp: now,
+	})
+	ms.index.set(id, content)
+	return clone(msg), nil
 }
 
-// Delete removes a message from storage
+// Delete replaces a message with a tombstone
 func (ms *MemoryStorage) Delete(id int) error {
 	ms.Lock()
 	defer ms.Unlock()
 
+	msg, ok := ms.messages[id]
+	if !ok || msg.Deleted() {
+		return ErrMessageNotFound
+	}
+	now := time.Now()
+	msg.Content = ""
+	msg.DeletedAt = &now
+	ms.index.remove(id)
+	return nil
+}
+
+// Revisions implements MessageStore
+func (ms *MemoryStorage) Revisions(id int) ([]models.Revision, error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	if _, ok := ms.messages[id]; !ok {
+		return nil, ErrMessageNotFound
+	}
+	return append([]models.Revision(nil), ms.revisions[id]...), nil
+}
+
+// Purge implements MessageStore
+func (ms *MemoryStorage) Purge(id int) error {
+	ms.Lock()
+	defer ms.Unlock()
+
 	if _, ok := ms.messages[id]; !ok {
 		return ErrMessageNotFound
 	}
 	delete(ms.messages, id)
+	i := sort.SearchInts(ms.ids, id)
+	ms.ids = append(ms.ids[:i], ms.ids[i+1:]...)
+	delete(ms.revisions, id)
+	ms.index.remove(id)
 	return nil
 }
 
-// Count returns the total number of messages
+// Search implements MessageStore
+func (ms *MemoryStorage) Search(q SearchQuery) ([]*models.Message, error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	results := []*models.Message{}
+	for _, id := range ms.index.lookup(Tokenize(q.Text)) {
+		if msg := ms.messages[id]; q.matches(msg) {
+			results = append(results, clone(msg))
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID > results[j].ID })
+	if len(results) > q.limit() {
+		results = results[:q.limit()]
+	}
+	return results, nil
+}
+
+// Count returns the number of messages that are not deleted
 func (ms *MemoryStorage) Count() int {
 	ms.RLock()
 	defer ms.RUnlock()
 
-	return len(ms.messages)
+	count := 0
+	for _, msg := range ms.messages {
+		if !msg.Deleted() {
+			count++
+		}
+	}
+	return count
+}
+
+// CountMessages implements MessageStore
+func (ms *MemoryStorage) CountMessages() (int, error) {
+	return ms.Count(), nil
+}
+
+// clone copies a message so callers cannot modify the stored one
+func clone(msg *models.Message) *models.Message {
+	c := *msg
+	return &c
 }
 
 // Common errors
 var (
 	ErrMessageNotFound = errors.New("message not found")
+	ErrMessageDeleted  = errors.New("message has been deleted")
 )
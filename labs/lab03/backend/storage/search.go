@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"html"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"lab03-backend/models"
+)
+
+// Search limits
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// SearchQuery selects messages containing every token of Text
+type SearchQuery struct {
+	Text string
+	// Username restricts results to one author when set
+	Username string
+	// From and To bound the creation time when set, both inclusive
+	From time.Time
+	To   time.Time
+	// Limit defaults to DefaultSearchLimit and is capped at MaxSearchLimit
+	Limit int
+}
+
+// limit returns Limit with the default and cap applied
+func (q SearchQuery) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultSearchLimit
+	case q.Limit > MaxSearchLimit:
+		return MaxSearchLimit
+	}
+	return q.Limit
+}
+
+// matches applies the filters other than Text
+func (q SearchQuery) matches(msg *models.Message) bool {
+	if q.Username != "" && msg.Username != q.Username {
+		return false
+	}
+	if !q.From.IsZero() && msg.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && msg.Timestamp.After(q.To) {
+		return false
+	}
+	return true
+}
+
+// Tokenize splits text into lowercase words, without duplicates, in order of
+// first appearance. Searches and the index use the same tokens.
+func Tokenize(text string) []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	for _, span := range tokenSpans(text) {
+		token := strings.ToLower(text[span[0]:span[1]])
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// tokenSpans returns the byte ranges of the words in text
+func tokenSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsNumber(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// index is an inverted index from tokens to message IDs. It is not safe for
+// concurrent use; MemoryStorage guards it with its lock.
+type index struct {
+	postings map[string]map[int]struct{}
+	// tokens remembers what each message was indexed under, so it can be
+	// removed without its old content
+	tokens map[int][]string
+}
+
+func newIndex() *index {
+	return &index{
+		postings: make(map[string]map[int]struct{}),
+		tokens:   make(map[int][]string),
+	}
+}
+
+// set indexes the message under the tokens of content, replacing what it
+// was indexed under before
+func (ix *index) set(id int, content string) {
+	ix.remove(id)
+	tokens := Tokenize(content)
+	for _, token := range tokens {
+		ids, ok := ix.postings[token]
+		if !ok {
+			ids = make(map[int]struct{})
+			ix.postings[token] = ids
+		}
+		ids[id] = struct{}{}
+	}
+	ix.tokens[id] = tokens
+}
+
+// remove drops the message from the index
+func (ix *index) remove(id int) {
+	for _, token := range ix.tokens[id] {
+		delete(ix.postings[token], id)
+		if len(ix.postings[token]) == 0 {
+			delete(ix.postings, token)
+		}
+	}
+	delete(ix.tokens, id)
+}
+
+// lookup returns the IDs of messages containing every token
+func (ix *index) lookup(tokens []string) []int {
+	if len(tokens) == 0 {
+		return nil
+	}
+	// Intersect starting from the rarest token
+	smallest := ix.postings[tokens[0]]
+	for _, token := range tokens[1:] {
+		if len(ix.postings[token]) < len(smallest) {
+			smallest = ix.postings[token]
+		}
+	}
+
+	var ids []int
+	for id := range smallest {
+		all := true
+		for _, token := range tokens {
+			if _, ok := ix.postings[token][id]; !ok {
+				all = false
+				break
+			}
+		}
+		if all {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// snippetContext is how many characters of context precede the first match
+// in a snippet, and snippetLength how many a snippet holds at most
+const (
+	snippetContext = 30
+	snippetLength  = 160
+)
+
+// Highlight returns an HTML-escaped excerpt of content around the first
+// word matching one of the query tokens, with matching words wrapped in
+// <mark> tags. Cut ends are marked with an ellipsis.
+func Highlight(content string, tokens []string) string {
+	wanted := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		wanted[token] = true
+	}
+	var matches [][2]int
+	for _, span := range tokenSpans(content) {
+		if wanted[strings.ToLower(content[span[0]:span[1]])] {
+			matches = append(matches, span)
+		}
+	}
+
+	// Choose the excerpt in runes so multi-byte characters are not split
+	start := 0
+	if len(matches) > 0 {
+		start = matches[0][0]
+		for i := 0; i < snippetContext && start > 0; i++ {
+			_, size := utf8.DecodeLastRuneInString(content[:start])
+			start -= size
+		}
+	}
+	end := start
+	for i := 0; i < snippetLength && end < len(content); i++ {
+		_, size := utf8.DecodeRuneInString(content[end:])
+		end += size
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] < start || m[0] >= end {
+			continue
+		}
+		matchEnd := min(m[1], end)
+		b.WriteString(html.EscapeString(content[pos:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(content[m[0]:matchEnd]))
+		b.WriteString("</mark>")
+		pos = matchEnd
+	}
+	b.WriteString(html.EscapeString(content[pos:end]))
+	if end < len(content) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"lab03-backend/models"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Hello, WORLD! hello-world 42 Привет")
+	want := []string{"hello", "world", "42", "привет"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if tokens := Tokenize(" ,.! "); len(tokens) != 0 {
+		t.Errorf("Expected no tokens, got %v", tokens)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("Go <is> fun, go!", []string{"go"})
+	want := "<mark>Go</mark> &lt;is&gt; fun, <mark>go</mark>!"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	long := strings.Repeat("a ", 100) + "needle" + strings.Repeat(" b", 100)
+	got = Highlight(long, []string{"needle"})
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("Expected ellipses on both cut ends, got %q", got)
+	}
+	if !strings.Contains(got, "<mark>needle</mark>") {
+		t.Errorf("Expected the match in the snippet, got %q", got)
+	}
+}
+
+func TestMessageStoreSearch(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			store.Create("alice", "Deploy the backend today")
+			store.Create("bob", "backend tests are green")
+			store.Create("alice", "lunch?")
+			third, _ := store.Create("bob", "Deploying the BACKEND again")
+
+			tests := []struct {
+				name  string
+				query SearchQuery
+				want  []int
+			}{
+				{"case-insensitive", SearchQuery{Text: "backend"}, []int{4, 2, 1}},
+				{"every word", SearchQuery{Text: "the backend"}, []int{4, 1}},
+				{"whole words only", SearchQuery{Text: "deploy"}, []int{1}},
+				{"no match", SearchQuery{Text: "dinner"}, nil},
+				{"username", SearchQuery{Text: "backend", Username: "bob"}, []int{4, 2}},
+				{"limit", SearchQuery{Text: "backend", Limit: 1}, []int{4}},
+				{"from", SearchQuery{Text: "backend", From: third.Timestamp}, []int{4}},
+				{"to", SearchQuery{Text: "backend", To: third.Timestamp.Add(-time.Nanosecond)}, []int{2, 1}},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					msgs, err := store.Search(tt.query)
+					if err != nil {
+						t.Fatalf("Search failed: %v", err)
+					}
+					if got := ids(msgs); !reflect.DeepEqual(got, tt.want) {
+						t.Errorf("Expected %v, got %v", tt.want, got)
+					}
+				})
+			}
+
+			store.Update(1, "Rolled back")
+			store.Delete(2)
+			msgs, err := store.Search(SearchQuery{Text: "backend"})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if got := ids(msgs); !reflect.DeepEqual(got, []int{4}) {
+				t.Errorf("Expected edits and deletes to update the index, got %v", got)
+			}
+			msgs, _ = store.Search(SearchQuery{Text: "rolled"})
+			if got := ids(msgs); !reflect.DeepEqual(got, []int{1}) {
+				t.Errorf("Expected the edited message under its new content, got %v", got)
+			}
+		})
+	}
+}
+
+// ids returns the IDs of msgs in order, or nil when there are none
+func ids(msgs []*models.Message) []int {
+	var result []int
+	for _, msg := range msgs {
+		result = append(result, msg.ID)
+	}
+	return result
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"lab03-backend/models"
+)
+
+// snapshot is the on-disk form of a MemoryStorage
+type snapshot struct {
+	NextID    int                       `json:"next_id"`
+	Messages  []*models.Message         `json:"messages"`
+	Revisions map[int][]models.Revision `json:"revisions"`
+}
+
+// SaveSnapshot writes every message and revision to path as JSON. The file
+// is replaced atomically, so a crash never leaves half a snapshot behind.
+func (ms *MemoryStorage) SaveSnapshot(path string) error {
+	ms.RLock()
+	data, err := json.Marshal(snapshot{
+		NextID:    ms.nextID,
+		Messages:  ms.sorted(),
+		Revisions: ms.revisions,
+	})
+	ms.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot replaces the contents of the storage with a snapshot written
+// by SaveSnapshot and rebuilds the search index. A missing file returns an
+// error satisfying errors.Is(err, fs.ErrNotExist).
+func (ms *MemoryStorage) LoadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	messages := make(map[int]*models.Message, len(snap.Messages))
+	index := newIndex()
+	nextID := snap.NextID
+	for _, msg := range snap.Messages {
+		if msg == nil {
+			return fmt.Errorf("failed to decode snapshot: null message")
+		}
+		messages[msg.ID] = msg
+		if !msg.Deleted() {
+			index.set(msg.ID, msg.Content)
+		}
+		nextID = max(nextID, msg.ID+1)
+	}
+	if snap.Revisions == nil {
+		snap.Revisions = make(map[int][]models.Revision)
+	}
+	ids := make([]int, 0, len(messages))
+	for id := range messages {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	ms.Lock()
+	defer ms.Unlock()
+	ms.messages = messages
+	ms.ids = ids
+	ms.revisions = snap.Revisions
+	ms.index = index
+	ms.nextID = max(nextID, 1)
+	return nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestMemoryStorageSnapshot(t *testing.T) {
+	path := t.TempDir() + "/messages.json"
+	store := NewMemoryStorage()
+	store.Create("alice", "hello world")
+	store.Update(1, "hello again")
+	store.Create("bob", "gone soon")
+	store.Delete(2)
+	if err := store.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	restored := NewMemoryStorage()
+	if err := restored.LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if count := restored.Count(); count != 1 {
+		t.Errorf("Expected 1 message, got %d", count)
+	}
+	if tombstone, err := restored.GetByID(2); err != nil || !tombstone.Deleted() {
+		t.Errorf("Expected the tombstone to be restored, got %+v, %v", tombstone, err)
+	}
+	if revisions, _ := restored.Revisions(1); len(revisions) != 2 {
+		t.Errorf("Expected 2 revisions, got %+v", revisions)
+	}
+
+	results, _ := restored.Search(SearchQuery{Text: "again"})
+	if len(results) != 1 || results[0].ID != 1 {
+		t.Errorf("Expected the search index to be rebuilt, got %+v", results)
+	}
+	if results, _ := restored.Search(SearchQuery{Text: "gone"}); len(results) != 0 {
+		t.Errorf("Expected tombstones to stay out of the index, got %+v", results)
+	}
+	if msg, _ := restored.Create("carol", "new"); msg.ID != 3 {
+		t.Errorf("Expected IDs to continue at 3, got %d", msg.ID)
+	}
+	if page, _ := restored.List(Page{After: 1}); len(page.Messages) != 2 || page.Messages[0].ID != 2 || page.Messages[1].ID != 3 {
+		t.Errorf("Expected restored and new messages in ID order, got %+v", page)
+	}
+
+	if err := NewMemoryStorage().LoadSnapshot(t.TempDir() + "/missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist for a missing snapshot, got %v", err)
+	}
+}
@@ -0,0 +1,451 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"lab03-backend/models"
+)
+
+// sqliteMigrations upgrade the schema in order. PRAGMA user_version holds
+// how many have been applied.
+var sqliteMigrations = []func(tx *sql.Tx) error{
+	execMigration(`CREATE TABLE IF NOT EXISTS messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL,
+		content TEXT NOT NULL,
+		timestamp TIMESTAMP NOT NULL
+	)`),
+	execMigration(`ALTER TABLE messages ADD COLUMN edited_at TIMESTAMP;
+	ALTER TABLE messages ADD COLUMN deleted_at TIMESTAMP;
+	CREATE TABLE message_revisions (
+		message_id INTEGER NOT NULL,
+		revision INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		PRIMARY KEY (message_id, revision)
+	);
+	INSERT INTO message_revisions (message_id, revision, content, timestamp)
+		SELECT id, 1, content, timestamp FROM messages`),
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`CREATE TABLE message_terms (
+			term TEXT NOT NULL,
+			message_id INTEGER NOT NULL,
+			PRIMARY KEY (term, message_id)
+		);
+		CREATE INDEX idx_message_terms_message_id ON message_terms(message_id)`)
+		if err != nil {
+			return err
+		}
+		return reindex(tx)
+	},
+	execMigration(`ALTER TABLE messages ADD COLUMN user_id INTEGER NOT NULL DEFAULT 0`),
+}
+
+// execMigration returns a migration that runs SQL statements
+func execMigration(statements string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(statements)
+		return err
+	}
+}
+
+// reindex rebuilds the search index of every message that is not deleted
+func reindex(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, content FROM messages WHERE deleted_at IS NULL")
+	if err != nil {
+		return err
+	}
+	contents := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		contents[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, content := range contents {
+		if err := setTerms(tx, id, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const messageColumns = "id, username, user_id, content, timestamp, edited_at, deleted_at"
+
+// SQLiteStorage implements MessageStore on a SQLite database, so messages
+// survive restarts
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// NewSQLiteStorage opens the database at path, creating the file and schema
+// if needed. Use ":memory:" for a throwaway database.
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection also keeps
+	// ":memory:" databases from being created per connection
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStorage{db: db}, nil
+}
+
+// migrate applies the migrations the database has not seen yet
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to migrate schema: %w", err)
+		}
+		if err := sqliteMigrations[version](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", version+1, err)
+		}
+		// PRAGMA does not take parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", version+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// List implements MessageStore
+func (s *SQLiteStorage) List(page Page) (*MessagePage, error) {
+	query := "SELECT " + messageColumns + " FROM messages WHERE id > ?"
+	args := []any{page.After}
+	if page.Before > 0 {
+		query += " AND id < ?"
+		args = append(args, page.Before)
+	}
+	if page.forward() {
+		query += " ORDER BY id ASC LIMIT ?"
+	} else {
+		query += " ORDER BY id DESC LIMIT ?"
+	}
+	// One extra row tells whether there are more
+	limit := page.limit()
+	args = append(args, limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*models.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	result := &MessagePage{HasMore: len(messages) > limit}
+	if result.HasMore {
+		messages = messages[:limit]
+	}
+	if !page.forward() {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	result.Messages = messages
+	return result, nil
+}
+
+// GetByID implements MessageStore
+func (s *SQLiteStorage) GetByID(id int) (*models.Message, error) {
+	msg, err := scanMessage(s.db.QueryRow("SELECT "+messageColumns+" FROM messages WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	return msg, err
+}
+
+// scanMessage reads a row of messageColumns
+func scanMessage(row interface{ Scan(...any) error }) (*models.Message, error) {
+	var msg models.Message
+	var editedAt, deletedAt sql.NullTime
+	err := row.Scan(&msg.ID, &msg.Username, &msg.UserID, &msg.Content, &msg.Timestamp, &editedAt, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load message: %w", err)
+	}
+	if editedAt.Valid {
+		msg.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		msg.DeletedAt = &deletedAt.Time
+	}
+	return &msg, nil
+}
+
+// Create implements MessageStore
+func (s *SQLiteStorage) Create(username, content string) (*models.Message, error) {
+	return s.CreateByUser(0, username, content)
+}
+
+// CreateByUser implements MessageStore
+func (s *SQLiteStorage) CreateByUser(userID int, username, content string) (*models.Message, error) {
+	msg := models.NewMessage(0, username, content)
+	msg.UserID = userID
+	msg.Timestamp = msg.Timestamp.UTC()
+
+	err := s.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			"INSERT INTO messages (username, user_id, content, timestamp) VALUES (?, ?, ?, ?)",
+			msg.Username, msg.UserID, msg.Content, msg.Timestamp,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create message: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to create message: %w", err)
+		}
+		msg.ID = int(id)
+		if err := setTerms(tx, msg.ID, content); err != nil {
+			return err
+		}
+		return addRevision(tx, msg.ID, content, msg.Timestamp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Update implements MessageStore
+func (s *SQLiteStorage) Update(id int, content string) (*models.Message, error) {
+	err := s.inTx(func(tx *sql.Tx) error {
+		var deletedAt sql.NullTime
+		err := tx.QueryRow("SELECT deleted_at FROM messages WHERE id = ?", id).Scan(&deletedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMessageNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load message: %w", err)
+		}
+		if deletedAt.Valid {
+			return ErrMessageDeleted
+		}
+
+		now := time.Now().UTC()
+		if _, err := tx.Exec("UPDATE messages SET content = ?, edited_at = ? WHERE id = ?", content, now, id); err != nil {
+			return fmt.Errorf("failed to update message: %w", err)
+		}
+		if err := setTerms(tx, id, content); err != nil {
+			return err
+		}
+		return addRevision(tx, id, content, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(id)
+}
+
+// addRevision stores the next revision of a message
+func addRevision(tx *sql.Tx, id int, content string, at time.Time) error {
+	_, err := tx.Exec(
+		`INSERT INTO message_revisions (message_id, revision, content, timestamp)
+		SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ? FROM message_revisions WHERE message_id = ?`,
+		id, content, at, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store revision: %w", err)
+	}
+	return nil
+}
+
+// setTerms replaces the search index entries of a message with the tokens
+// of content
+func setTerms(tx *sql.Tx, id int, content string) error {
+	if _, err := tx.Exec("DELETE FROM message_terms WHERE message_id = ?", id); err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	for _, token := range Tokenize(content) {
+		if _, err := tx.Exec("INSERT INTO message_terms (term, message_id) VALUES (?, ?)", token, id); err != nil {
+			return fmt.Errorf("failed to update search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// Delete implements MessageStore
+func (s *SQLiteStorage) Delete(id int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			"UPDATE messages SET content = '', deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+			time.Now().UTC(), id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to delete message: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return ErrMessageNotFound
+		}
+		return setTerms(tx, id, "")
+	})
+}
+
+// Search implements MessageStore
+func (s *SQLiteStorage) Search(q SearchQuery) ([]*models.Message, error) {
+	tokens := Tokenize(q.Text)
+	if len(tokens) == 0 {
+		return []*models.Message{}, nil
+	}
+
+	placeholders := strings.Repeat("?, ", len(tokens)-1) + "?"
+	query := "SELECT " + messageColumns + ` FROM messages WHERE id IN (
+		SELECT message_id FROM message_terms WHERE term IN (` + placeholders + `)
+		GROUP BY message_id HAVING COUNT(*) = ?)`
+	var args []any
+	for _, token := range tokens {
+		args = append(args, token)
+	}
+	args = append(args, len(tokens))
+	if q.Username != "" {
+		query += " AND username = ?"
+		args = append(args, q.Username)
+	}
+	// Timestamps are stored in UTC, so the text comparison orders them
+	if !q.From.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		query += " AND timestamp <= ?"
+		args = append(args, q.To.UTC())
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, q.limit())
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []*models.Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	return messages, nil
+}
+
+// Revisions implements MessageStore
+func (s *SQLiteStorage) Revisions(id int) ([]models.Revision, error) {
+	if _, err := s.GetByID(id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(
+		"SELECT revision, content, timestamp FROM message_revisions WHERE message_id = ? ORDER BY revision", id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.Revision{}
+	for rows.Next() {
+		var rev models.Revision
+		if err := rows.Scan(&rev.Number, &rev.Content, &rev.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to load revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// Purge implements MessageStore
+func (s *SQLiteStorage) Purge(id int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("DELETE FROM messages WHERE id = ?", id)
+		if err != nil {
+			return fmt.Errorf("failed to purge message: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return ErrMessageNotFound
+		}
+		if _, err := tx.Exec("DELETE FROM message_revisions WHERE message_id = ?", id); err != nil {
+			return fmt.Errorf("failed to purge revisions: %w", err)
+		}
+		return setTerms(tx, id, "")
+	})
+}
+
+// CountMessages implements MessageStore
+func (s *SQLiteStorage) CountMessages() (int, error) {
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM messages WHERE deleted_at IS NULL").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+	return count, nil
+}
+
+// inTx runs fn in a transaction that is committed if fn succeeds
+func (s *SQLiteStorage) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
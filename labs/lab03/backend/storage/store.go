@@ -0,0 +1,78 @@
+package storage
+
+import "lab03-backend/models"
+
+// Page limits
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+// MessageStore persists chat messages.
+//
+// Deleting a message leaves a tombstone without content in its place, so
+// List and GetByID still return it with DeletedAt set. Every version of the
+// content is kept as a revision until the message is purged.
+type MessageStore interface {
+	// List returns a page of messages including tombstones, oldest first
+	List(page Page) (*MessagePage, error)
+	GetByID(id int) (*models.Message, error)
+	Create(username, content string) (*models.Message, error)
+	// CreateByUser is Create for an author with a user ID, which is kept
+	// with the message
+	CreateByUser(userID int, username, content string) (*models.Message, error)
+	// Update stores content as a new revision. Tombstones cannot be
+	// updated and return ErrMessageDeleted.
+	Update(id int, content string) (*models.Message, error)
+	// Delete turns a message into a tombstone. Deleting a tombstone returns
+	// ErrMessageNotFound.
+	Delete(id int) error
+	// Revisions returns every version of a message, oldest first
+	Revisions(id int) ([]models.Revision, error)
+	// Purge removes a message or tombstone and its revisions for good
+	Purge(id int) error
+	// Search returns the newest messages matching q. The index behind it is
+	// kept up to date by Create, Update, Delete and Purge; tombstones never
+	// match.
+	Search(q SearchQuery) ([]*models.Message, error)
+	// CountMessages returns the number of messages that are not deleted
+	CountMessages() (int, error)
+}
+
+// Page selects messages by ID, which grows with the timestamp.
+//
+// With only After set the page holds the oldest messages after it, which is
+// how clients poll for new messages. Otherwise it holds the newest messages
+// before Before (or overall), which is how clients scroll back.
+type Page struct {
+	// After excludes messages with an ID up to and including it
+	After int
+	// Before excludes messages with an ID from it on; 0 means no bound
+	Before int
+	// Limit defaults to DefaultPageLimit and is capped at MaxPageLimit
+	Limit int
+}
+
+// forward reports whether the page is read from the oldest message
+func (p Page) forward() bool {
+	return p.After > 0 && p.Before == 0
+}
+
+// limit returns Limit with the default and cap applied
+func (p Page) limit() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultPageLimit
+	case p.Limit > MaxPageLimit:
+		return MaxPageLimit
+	}
+	return p.Limit
+}
+
+// MessagePage is the result of List
+type MessagePage struct {
+	Messages []*models.Message
+	// HasMore reports whether further messages match in the direction the
+	// page was read: newer ones when paging forward, older ones otherwise
+	HasMore bool
+}
@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stores returns a fresh instance of every MessageStore implementation
+func stores(t *testing.T) map[string]MessageStore {
+	t.Helper()
+
+	sqlite, err := NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage failed: %v", err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+
+	return map[string]MessageStore{
+		"memory": NewMemoryStorage(),
+		"sqlite": sqlite,
+	}
+}
+
+func TestMessageStoreCRUD(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			msg, err := store.Create("alice", "hello")
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+			if msg.ID != 1 || msg.Timestamp.IsZero() {
+				t.Errorf("Expected ID 1 and a timestamp, got %+v", msg)
+			}
+
+			updated, err := store.Update(msg.ID, "hello again")
+			if err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			if updated.Content != "hello again" || updated.Username != "alice" {
+				t.Errorf("Unexpected updated message %+v", updated)
+			}
+
+			got, err := store.GetByID(msg.ID)
+			if err != nil || got.Content != "hello again" {
+				t.Errorf("Expected updated content, got %+v, %v", got, err)
+			}
+
+			if got.EditedAt == nil || got.EditedAt.Before(got.Timestamp) {
+				t.Errorf("Expected edited_at after the timestamp, got %v", got.EditedAt)
+			}
+
+			if err := store.Delete(msg.ID); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			tombstone, err := store.GetByID(msg.ID)
+			if err != nil {
+				t.Fatalf("Expected a tombstone, got %v", err)
+			}
+			if !tombstone.Deleted() || tombstone.Content != "" {
+				t.Errorf("Expected tombstone without content, got %+v", tombstone)
+			}
+			if _, err := store.Update(msg.ID, "gone"); !errors.Is(err, ErrMessageDeleted) {
+				t.Errorf("Expected ErrMessageDeleted on update, got %v", err)
+			}
+			if err := store.Delete(msg.ID); !errors.Is(err, ErrMessageNotFound) {
+				t.Errorf("Expected ErrMessageNotFound on second delete, got %v", err)
+			}
+			if count, err := store.CountMessages(); err != nil || count != 0 {
+				t.Errorf("Expected 0 messages, got %d, %v", count, err)
+			}
+
+			revisions, err := store.Revisions(msg.ID)
+			if err != nil {
+				t.Fatalf("Revisions failed: %v", err)
+			}
+			if len(revisions) != 2 || revisions[0].Content != "hello" || revisions[1].Content != "hello again" || revisions[1].Number != 2 {
+				t.Errorf("Expected both versions to be kept, got %+v", revisions)
+			}
+
+			if err := store.Purge(msg.ID); err != nil {
+				t.Fatalf("Purge failed: %v", err)
+			}
+			if _, err := store.GetByID(msg.ID); !errors.Is(err, ErrMessageNotFound) {
+				t.Errorf("Expected ErrMessageNotFound after purge, got %v", err)
+			}
+			if _, err := store.Revisions(msg.ID); !errors.Is(err, ErrMessageNotFound) {
+				t.Errorf("Expected revisions to be purged, got %v", err)
+			}
+			if err := store.Purge(msg.ID); !errors.Is(err, ErrMessageNotFound) {
+				t.Errorf("Expected ErrMessageNotFound on second purge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMessageStoreCreateByUser(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateByUser(7, "alice@example.com", "hello"); err != nil {
+				t.Fatalf("CreateByUser failed: %v", err)
+			}
+			msg, err := store.GetByID(1)
+			if err != nil || msg.UserID != 7 || msg.Username != "alice@example.com" {
+				t.Errorf("Expected a message by user 7, got %+v, %v", msg, err)
+			}
+		})
+	}
+}
+
+func TestMessageStoreList(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				if _, err := store.Create("alice", "message"); err != nil {
+					t.Fatalf("Create failed: %v", err)
+				}
+			}
+			if err := store.Purge(5); err != nil {
+				t.Fatalf("Purge failed: %v", err)
+			}
+
+			tests := []struct {
+				name    string
+				page    Page
+				want    []int
+				hasMore bool
+			}{
+				{"newest", Page{Limit: 3}, []int{8, 9, 10}, true},
+				{"everything", Page{}, []int{1, 2, 3, 4, 6, 7, 8, 9, 10}, false},
+				{"after", Page{After: 3, Limit: 3}, []int{4, 6, 7}, true},
+				{"after to the end", Page{After: 7, Limit: 3}, []int{8, 9, 10}, false},
+				{"before", Page{Before: 7, Limit: 3}, []int{3, 4, 6}, true},
+				{"before to the start", Page{Before: 4, Limit: 3}, []int{1, 2, 3}, false},
+				{"between", Page{After: 2, Before: 9, Limit: 2}, []int{7, 8}, true},
+				{"past the end", Page{After: 10}, nil, false},
+				{"empty range", Page{After: 8, Before: 5}, nil, false},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					page, err := store.List(tt.page)
+					if err != nil {
+						t.Fatalf("List failed: %v", err)
+					}
+					var ids []int
+					for _, msg := range page.Messages {
+						ids = append(ids, msg.ID)
+					}
+					if len(ids) != len(tt.want) {
+						t.Fatalf("Expected IDs %v, got %v", tt.want, ids)
+					}
+					for i := range ids {
+						if ids[i] != tt.want[i] {
+							t.Fatalf("Expected IDs %v, got %v", tt.want, ids)
+						}
+					}
+					if page.HasMore != tt.hasMore {
+						t.Errorf("Expected HasMore %v, got %v", tt.hasMore, page.HasMore)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSQLiteStoragePersists(t *testing.T) {
+	path := t.TempDir() + "/messages.db"
+
+	store, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage failed: %v", err)
+	}
+	if _, err := store.Create("alice", "still here"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	store.Close()
+
+	reopened, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage failed: %v", err)
+	}
+	defer reopened.Close()
+
+	msg, err := reopened.GetByID(1)
+	if err != nil || msg.Content != "still here" {
+		t.Errorf("Expected message to survive reopening, got %+v, %v", msg, err)
+	}
+}
+
+func TestSQLiteStorageMigratesOldSchema(t *testing.T) {
+	path := t.TempDir() + "/messages.db"
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin()
+	if err == nil {
+		if err = sqliteMigrations[0](tx); err == nil {
+			_, err = tx.Exec("PRAGMA user_version = 1")
+		}
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}
+	if err == nil {
+		_, err = db.Exec("INSERT INTO messages (username, content, timestamp) VALUES ('alice', 'before', ?)", time.Now().UTC())
+	}
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to create old schema: %v", err)
+	}
+
+	store, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage failed: %v", err)
+	}
+	defer store.Close()
+
+	revisions, err := store.Revisions(1)
+	if err != nil || len(revisions) != 1 || revisions[0].Content != "before" {
+		t.Errorf("Expected existing content as first revision, got %+v, %v", revisions, err)
+	}
+	if _, err := store.Update(1, "after"); err != nil {
+		t.Errorf("Update after migration failed: %v", err)
+	}
+}
@@ -3,6 +3,10 @@ import 'package:provider/provider.dart';
 import 'screens/chat_screen.dart';
 import 'services/api_service.dart';
 
+// Access token of the course backend, or the username when the server runs
+// with -dev-auth: flutter run --dart-define=API_TOKEN=...
+const String apiToken = String.fromEnvironment('API_TOKEN');
+
 void main() {
   runApp(const MyApp());
 }
@@ -15,7 +19,7 @@ class MyApp extends StatelessWidget {
     return MultiProvider(
       providers: [
         Provider<ApiService>(
-          create: (_) => ApiService(),
+          create: (_) => ApiService(token: apiToken),
         ),
         ChangeNotifierProxyProvider<ApiService, ChatProvider>(
           create: (context) => ChatProvider(
@@ -9,7 +9,11 @@ class ApiService {
   static const Duration timeout = Duration(seconds: 30);
   late final http.Client _client;
 
-  ApiService({http.Client? client}) : _client = client ?? http.Client();
+  /// Bearer token sent with every request. The server needs it to create,
+  /// edit and delete messages; reads work without it.
+  String? token;
+
+  ApiService({http.Client? client, this.token}) : _client = client ?? http.Client();
 
   void dispose() {
     _client.close();
@@ -18,6 +22,7 @@ class ApiService {
   Map<String, String> _getHeaders() => {
         'Content-Type': 'application/json',
         'Accept': 'application/json',
+        if (token != null && token!.isNotEmpty) 'Authorization': 'Bearer $token',
       };
 
   Future<T> _handleResponse<T>(
@@ -123,6 +123,21 @@ void main() {
         // Should not throw an exception
       });
 
+      test('should send the bearer token when set', () async {
+        final authHeaders = <String?>[];
+        final mockClient = MockClient((request) async {
+          authHeaders.add(request.headers['Authorization']);
+          return http.Response('', 204);
+        });
+
+        final apiService = ApiService(client: mockClient);
+        await apiService.deleteMessage(1);
+        apiService.token = 'secret-token';
+        await apiService.deleteMessage(1);
+
+        expect(authHeaders, equals([null, 'Bearer secret-token']));
+      });
+
       test('should get HTTP status successfully', () async {
         final mockClient = MockClient((request) async {
           if (request.url.toString().contains('/api/status/200')) {
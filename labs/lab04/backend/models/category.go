@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/jsonbody"
 	"gorm.io/gorm"
 )
 
@@ -73,15 +74,10 @@ func (c *Category) BeforeUpdate(tx *gorm.DB) error {
 	return nil
 }
 
-// TODO: Implement Validate method for CreateCategoryRequest
+// Validate checks the validate tags of the request. Uniqueness of the name
+// is left to the database.
 func (req *CreateCategoryRequest) Validate() error {
-	// TODO: Add validation logic for GORM model
-	// - Name should be unique (checked at database level via GORM)
-	// - Color should be valid hex color
-	// - Description should not exceed limits
-	// Example using validator package:
-	// return validator.New().Struct(req)
-	return nil
+	return jsonbody.Validate(req)
 }
 
 // TODO: Implement ToCategory method
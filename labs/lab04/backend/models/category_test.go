@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/jsonbody"
+)
+
+func TestCreateCategoryRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        CreateCategoryRequest
+		wantFields []string
+	}{
+		{
+			name: "valid request",
+			req:  CreateCategoryRequest{Name: "Go", Color: "#00ADD8"},
+		},
+		{
+			name:       "short name and bad color",
+			req:        CreateCategoryRequest{Name: "G", Color: "blue"},
+			wantFields: []string{"name", "color"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			var fields []string
+			var bodyErr *jsonbody.Error
+			if errors.As(err, &bodyErr) {
+				for _, f := range bodyErr.Fields {
+					fields = append(fields, f.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected a *jsonbody.Error, got %v", err)
+			}
+			if len(fields) != len(tt.wantFields) {
+				t.Fatalf("Expected errors for %v, got %v", tt.wantFields, fields)
+			}
+			for i := range fields {
+				if fields[i] != tt.wantFields[i] {
+					t.Errorf("Expected errors for %v, got %v", tt.wantFields, fields)
+				}
+			}
+		})
+	}
+}
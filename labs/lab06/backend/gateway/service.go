@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/cors"
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/jsonbody"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
@@ -69,23 +71,10 @@ func NewService(calculatorAddr string) (*Service, error) {
 // setupRoutes configures HTTP routes
 func (s *Service) setupRoutes() {
 	// Enable CORS middleware for all requests
-	s.router.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Set CORS headers for all requests
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-			w.Header().Set("Access-Control-Expose-Headers", "Content-Length")
-
-			// Handle preflight OPTIONS requests
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	})
+	s.router.Use(cors.MustNew(cors.Options{
+		AllowedOrigins: []string{"*"},
+		ExposedHeaders: []string{"Content-Length"},
+	}).Handler)
 
 	api := s.router.PathPrefix("/api/v1").Subrouter()
 
@@ -111,8 +100,8 @@ func (s *Service) GetRouter() *mux.Router {
 // handleAdd handles addition requests
 func (s *Service) handleAdd(w http.ResponseWriter, r *http.Request) {
 	var req OperationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := jsonbody.Decode(w, r, &req); err != nil {
+		jsonbody.WriteError(w, err)
 		return
 	}
 
@@ -131,8 +120,8 @@ func (s *Service) handleAdd(w http.ResponseWriter, r *http.Request) {
 // handleSubtract handles subtraction requests
 func (s *Service) handleSubtract(w http.ResponseWriter, r *http.Request) {
 	var req OperationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := jsonbody.Decode(w, r, &req); err != nil {
+		jsonbody.WriteError(w, err)
 		return
 	}
 
@@ -151,8 +140,8 @@ func (s *Service) handleSubtract(w http.ResponseWriter, r *http.Request) {
 // handleMultiply handles multiplication requests
 func (s *Service) handleMultiply(w http.ResponseWriter, r *http.Request) {
 	var req OperationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := jsonbody.Decode(w, r, &req); err != nil {
+		jsonbody.WriteError(w, err)
 		return
 	}
 
@@ -171,8 +160,8 @@ func (s *Service) handleMultiply(w http.ResponseWriter, r *http.Request) {
 // handleDivide handles division requests
 func (s *Service) handleDivide(w http.ResponseWriter, r *http.Request) {
 	var req OperationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := jsonbody.Decode(w, r, &req); err != nil {
+		jsonbody.WriteError(w, err)
 		return
 	}
 
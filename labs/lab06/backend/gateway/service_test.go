@@ -266,3 +266,47 @@ func TestService_InvalidRequestBody(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", rr.Code)
 	}
 }
+
+func TestService_StrictRequestBody(t *testing.T) {
+	service := createTestService()
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		wantStatus  int
+		wantField   string
+	}{
+		{"unknown field", `{"a":1,"b":2,"c":3}`, "application/json", http.StatusUnprocessableEntity, "c"},
+		{"trailing data", `{"a":1,"b":2} {"a":3}`, "application/json", http.StatusBadRequest, ""},
+		{"wrong type", `{"a":"one","b":2}`, "application/json", http.StatusUnprocessableEntity, "a"},
+		{"missing content type", `{"a":1,"b":2}`, "", http.StatusUnsupportedMediaType, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/calculate/add", bytes.NewBufferString(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			service.GetRouter().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			var resp struct {
+				Error  string `json:"error"`
+				Fields []struct {
+					Field string `json:"field"`
+				} `json:"fields"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || resp.Error == "" {
+				t.Errorf("Expected a JSON error message, got %v", err)
+			}
+			if tt.wantField != "" && (len(resp.Fields) != 1 || resp.Fields[0].Field != tt.wantField) {
+				t.Errorf("Expected a field error for %q, got %+v", tt.wantField, resp.Fields)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/cors"
 	"google.golang.org/grpc"
 
 	"lab06-backend/calculator"
@@ -91,24 +92,15 @@ func startWebSocketService() {
 	mux.HandleFunc("/stats", wsServiceInstance.GetStatsHandler())
 
 	// Add CORS middleware
-	corsHandler := func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	}
+	corsPolicy := cors.MustNew(cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodOptions},
+		AllowedHeaders: []string{"Content-Type"},
+	})
 
 	server := &http.Server{
 		Addr:    ":8081",
-		Handler: corsHandler(mux),
+		Handler: corsPolicy.Handler(mux),
 	}
 
 	log.Println("WebSocket service starting on :8081")
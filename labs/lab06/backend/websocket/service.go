@@ -213,10 +213,6 @@ func (h *Hub) broadcastToOthers(message Message, sender *Client) {
 func (s *Service) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🔗 New WebSocket connection request from %s", r.RemoteAddr)
 
-	// Add CORS headers for WebSocket handshake
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("❌ WebSocket upgrade failed: %v", err)
@@ -259,7 +255,6 @@ func (s *Service) handleStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	json.NewEncoder(w).Encode(stats)
 }
 
@@ -0,0 +1,17 @@
+module github.com/timur-harin/sum25-go-flutter-course/pkg/auth
+
+go 1.24
+
+require (
+	github.com/golang-jwt/jwt/v4 v4.5.0
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/crypto v0.39.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
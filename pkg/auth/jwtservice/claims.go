@@ -0,0 +1,40 @@
+package jwtservice
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Token types stored in the typ claim
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// TokenTypeMFAPending proves the password step of a two-factor login.
+	// It can only be exchanged for a token pair, see CompleteMFA.
+	TokenTypeMFAPending = "mfa_pending"
+)
+
+// Claims represents JWT token claims
+type Claims struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+	// TokenType tells access and refresh tokens apart, so a refresh token
+	// cannot be used to call the API
+	TokenType string `json:"typ,omitempty"`
+	// FamilyID is shared by every token issued from the same login. Reusing
+	// a rotated refresh token revokes the whole family.
+	FamilyID string `json:"fid,omitempty"`
+	// Roles and Permissions are evaluated by the policy package. Refresh
+	// copies them into the new tokens; RefreshSubject lets the caller load
+	// the current ones instead.
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+	// SessionVersion is the user's session version at issue time. Tokens
+	// with an older version than the store's are rejected, see RevokeSessions.
+	SessionVersion int `json:"sv,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Valid validates the claims (required by jwt.Claims interface)
+func (c Claims) Valid() error {
+	return c.RegisteredClaims.Valid()
+}
@@ -0,0 +1,481 @@
+package jwtservice
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// TokenTTL is how long tokens from GenerateToken stay valid
+const TokenTTL = 24 * time.Hour
+
+// Default lifetimes for token pairs
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 7 * 24 * time.Hour
+	DefaultMFATTL     = 5 * time.Minute
+)
+
+// familyPrefix keeps family IDs and token IDs apart in the revocation store
+const familyPrefix = "family:"
+
+// Config configures a JWTService
+type Config struct {
+	// SecretKey signs and verifies tokens with HS256. It is ignored when
+	// Keys is set.
+	SecretKey string
+	// Keys holds asymmetric (RS256, EdDSA) or HMAC keys identified by kid
+	Keys *KeySet
+	// AccessTTL defaults to DefaultAccessTTL
+	AccessTTL time.Duration
+	// RefreshTTL defaults to DefaultRefreshTTL
+	RefreshTTL time.Duration
+	// MFATTL is the time between the password and second factor steps of a
+	// login. It defaults to DefaultMFATTL.
+	MFATTL time.Duration
+	// Store records revoked tokens and used refresh tokens. It defaults to
+	// an in-memory store, which only works for a single instance.
+	Store RevocationStore
+}
+
+// TokenPair is a short-lived access token with the refresh token that
+// replaces it
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// Subject describes who a token is issued to
+type Subject struct {
+	UserID      int
+	Email       string
+	Roles       []string
+	Permissions []string
+}
+
+// JWTService handles JWT token operations
+type JWTService struct {
+	keys       *KeySet
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	mfaTTL     time.Duration
+	store      RevocationStore
+}
+
+// NewJWTService creates a new JWT service
+// Requirements:
+// - secretKey must not be empty
+func NewJWTService(secretKey string) (*JWTService, error) {
+	return New(Config{SecretKey: secretKey})
+}
+
+// New creates a JWT service from cfg
+func New(cfg Config) (*JWTService, error) {
+	if cfg.Keys == nil && cfg.SecretKey == "" {
+		return nil, NewValidationError("secretKey", "must not be empty")
+	}
+	if cfg.AccessTTL < 0 || cfg.RefreshTTL < 0 || cfg.MFATTL < 0 {
+		return nil, NewValidationError("ttl", "must not be negative")
+	}
+
+	keys := cfg.Keys
+	if keys == nil {
+		// A single secret without kid keeps tokens compatible with services
+		// that only know the shared secret
+		hmac := NewHMACKey("", []byte(cfg.SecretKey))
+		keys = &KeySet{active: hmac, keys: map[string]*Key{"": hmac}}
+	}
+
+	j := &JWTService{
+		keys:       keys,
+		accessTTL:  cfg.AccessTTL,
+		refreshTTL: cfg.RefreshTTL,
+		mfaTTL:     cfg.MFATTL,
+		store:      cfg.Store,
+	}
+	if j.accessTTL == 0 {
+		j.accessTTL = DefaultAccessTTL
+	}
+	if j.refreshTTL == 0 {
+		j.refreshTTL = DefaultRefreshTTL
+	}
+	if j.mfaTTL == 0 {
+		j.mfaTTL = DefaultMFATTL
+	}
+	if j.store == nil {
+		j.store = NewMemoryRevocationStore()
+	}
+	return j, nil
+}
+
+// GenerateToken creates a new JWT token with user claims
+// Requirements:
+// - userID must be positive
+// - email must not be empty
+// - Token expires in 24 hours
+// - Use HS256 signing method
+func (j *JWTService) GenerateToken(userID int, email string) (string, error) {
+	if err := validateSubject(userID, email); err != nil {
+		return "", err
+	}
+	version, err := j.store.SessionVersion(context.Background(), strconv.Itoa(userID))
+	if err != nil {
+		return "", err
+	}
+	token, _, err := j.sign(Subject{UserID: userID, Email: email}, TokenTypeAccess, "", version, TokenTTL)
+	return token, err
+}
+
+// GenerateTokenPair starts a new token family for the user and returns its
+// first access and refresh tokens
+func (j *JWTService) GenerateTokenPair(userID int, email string) (*TokenPair, error) {
+	return j.IssueTokenPair(Subject{UserID: userID, Email: email})
+}
+
+// IssueTokenPair is GenerateTokenPair for a subject with roles and permissions
+func (j *JWTService) IssueTokenPair(subject Subject) (*TokenPair, error) {
+	if err := validateSubject(subject.UserID, subject.Email); err != nil {
+		return nil, err
+	}
+	return j.issuePair(context.Background(), subject, rand.Text())
+}
+
+// Refresh exchanges a refresh token for a new pair in the same family. Each
+// refresh token can be used once; presenting it again revokes the family,
+// because it means the token was stolen or replayed.
+//
+// The new pair carries the roles and permissions of the old one. Callers that
+// can look the user up should use RefreshSubject, so that changed roles take
+// effect on the next refresh.
+func (j *JWTService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	return j.RefreshSubject(ctx, refreshToken, func(ctx context.Context, claims *Claims) (Subject, error) {
+		return Subject{
+			UserID:      claims.UserID,
+			Email:       claims.Email,
+			Roles:       claims.Roles,
+			Permissions: claims.Permissions,
+		}, nil
+	})
+}
+
+// RefreshSubject is Refresh, issuing the new pair to the subject load returns
+// for the claims of the refresh token. load runs once the token has been
+// checked and marked as used; its error is returned unchanged. The subject
+// must be the user the token was issued to.
+func (j *JWTService) RefreshSubject(ctx context.Context, refreshToken string, load func(ctx context.Context, claims *Claims) (Subject, error)) (*TokenPair, error) {
+	claims, err := j.parse(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeRefresh || claims.FamilyID == "" {
+		return nil, ErrWrongTokenType
+	}
+
+	revoked, err := j.store.IsRevoked(ctx, claims.ID, familyPrefix+claims.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	if err := j.checkSessionVersion(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	first, err := j.store.MarkUsed(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		if err := j.RevokeFamily(ctx, claims.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenReused
+	}
+
+	subject, err := load(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if subject.UserID != claims.UserID {
+		return nil, ErrInvalidClaims
+	}
+	if err := validateSubject(subject.UserID, subject.Email); err != nil {
+		return nil, err
+	}
+	return j.issuePair(ctx, subject, claims.FamilyID)
+}
+
+// ValidateToken parses and validates a JWT token
+// Requirements:
+// - Check token signature with secret key
+// - Verify token is not expired
+// - Return parsed claims on success
+//
+// Refresh tokens are rejected, as are tokens whose jti or family has been
+// revoked.
+func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
+	return j.ValidateTokenContext(context.Background(), tokenString)
+}
+
+// ValidateTokenContext is ValidateToken with a context for the revocation store
+func (j *JWTService) ValidateTokenContext(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != "" && claims.TokenType != TokenTypeAccess {
+		return nil, ErrWrongTokenType
+	}
+
+	ids := []string{claims.ID}
+	if claims.FamilyID != "" {
+		ids = append(ids, familyPrefix+claims.FamilyID)
+	}
+	revoked, err := j.store.IsRevoked(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	if err := j.checkSessionVersion(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// IssueMFAToken returns a short-lived token for a subject that passed the
+// password step of a login but still has to present a second factor
+func (j *JWTService) IssueMFAToken(subject Subject) (string, time.Time, error) {
+	if err := validateSubject(subject.UserID, subject.Email); err != nil {
+		return "", time.Time{}, err
+	}
+	version, err := j.store.SessionVersion(context.Background(), strconv.Itoa(subject.UserID))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return j.sign(subject, TokenTypeMFAPending, "", version, j.mfaTTL)
+}
+
+// ValidateMFAToken checks a token from IssueMFAToken without using it up
+func (j *JWTService) ValidateMFAToken(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeMFAPending {
+		return nil, ErrWrongTokenType
+	}
+
+	revoked, err := j.store.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	if err := j.checkSessionVersion(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// CompleteMFA exchanges a token from IssueMFAToken for a new token family.
+// Call it only after the second factor was verified. Each MFA token can be
+// exchanged once.
+func (j *JWTService) CompleteMFA(ctx context.Context, tokenString string) (*TokenPair, error) {
+	claims, err := j.ValidateMFAToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := j.store.MarkUsed(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		return nil, ErrTokenReused
+	}
+
+	subject := Subject{
+		UserID:      claims.UserID,
+		Email:       claims.Email,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}
+	return j.issuePair(ctx, subject, rand.Text())
+}
+
+// RevokeToken denylists a single token by its jti until it expires
+func (j *JWTService) RevokeToken(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return ErrInvalidClaims
+	}
+	return j.store.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// RevokeFamily revokes every access and refresh token issued from the same
+// login, e.g. on logout
+func (j *JWTService) RevokeFamily(ctx context.Context, familyID string) error {
+	if familyID == "" {
+		return ErrInvalidClaims
+	}
+	// No token of the family can outlive a refresh token issued right now
+	return j.store.Revoke(ctx, familyPrefix+familyID, time.Now().Add(j.refreshTTL))
+}
+
+// RevokeSessions invalidates every access and refresh token issued to the
+// user so far, e.g. after a password reset. Tokens issued afterwards are
+// not affected.
+func (j *JWTService) RevokeSessions(ctx context.Context, userID int) error {
+	if userID <= 0 {
+		return NewValidationError("userID", "must be positive")
+	}
+	_, err := j.store.BumpSessionVersion(ctx, strconv.Itoa(userID))
+	return err
+}
+
+// checkSessionVersion rejects tokens issued before the user's sessions were
+// revoked
+func (j *JWTService) checkSessionVersion(ctx context.Context, claims *Claims) error {
+	version, err := j.store.SessionVersion(ctx, strconv.Itoa(claims.UserID))
+	if err != nil {
+		return err
+	}
+	if claims.SessionVersion < version {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+func (j *JWTService) issuePair(ctx context.Context, subject Subject, familyID string) (*TokenPair, error) {
+	version, err := j.store.SessionVersion(ctx, strconv.Itoa(subject.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	access, accessExp, err := j.sign(subject, TokenTypeAccess, familyID, version, j.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, refreshExp, err := j.sign(subject, TokenTypeRefresh, familyID, version, j.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		AccessExpiresAt:  accessExp,
+		RefreshExpiresAt: refreshExp,
+	}, nil
+}
+
+func (j *JWTService) sign(subject Subject, tokenType, familyID string, version int, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := Claims{
+		UserID:         subject.UserID,
+		Email:          subject.Email,
+		TokenType:      tokenType,
+		FamilyID:       familyID,
+		Roles:          subject.Roles,
+		Permissions:    subject.Permissions,
+		SessionVersion: version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        rand.Text(),
+			Subject:   strconv.Itoa(subject.UserID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	key := j.keys.Active()
+	if key == nil {
+		return "", time.Time{}, ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+
+	signed, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// parse checks the signature, expiry and required claims of a token of any type
+func (j *JWTService) parse(tokenString string) (*Claims, error) {
+	if tokenString == "" {
+		return nil, ErrEmptyToken
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, j.verificationKey)
+	if err != nil {
+		var methodErr InvalidSigningMethodError
+		if errors.As(err, &methodErr) {
+			return nil, methodErr
+		}
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrInvalidToken
+	}
+	if !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if claims.UserID <= 0 || claims.Email == "" || claims.ExpiresAt == nil {
+		return nil, ErrInvalidClaims
+	}
+
+	return claims, nil
+}
+
+// JWKS returns the public verification keys
+func (j *JWTService) JWKS() JWKS {
+	return j.keys.JWKS()
+}
+
+// JWKSHandler serves the public keys at /.well-known/jwks.json
+func (j *JWTService) JWKSHandler() http.Handler {
+	return j.keys.JWKSHandler()
+}
+
+// verificationKey picks the key named by the kid header. The algorithm in
+// the header must match the key's own algorithm; trusting the header would
+// let an attacker sign HS256 tokens with a published RSA public key.
+func (j *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := j.keys.Lookup(kid)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, NewInvalidSigningMethodError(token.Header["alg"])
+	}
+	return key.verifyKey, nil
+}
+
+func validateSubject(userID int, email string) error {
+	if userID <= 0 {
+		return NewValidationError("userID", "must be positive")
+	}
+	if email == "" {
+		return NewValidationError("email", "must not be empty")
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package jwtservice
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Key is a signing or verification key identified by its kid
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	// signKey is nil for keys that can only verify
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(kid string, secret []byte) *Key {
+	return &Key{ID: kid, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewRSAKey creates an RS256 signing key. An empty kid is replaced by the
+// key's RFC 7638 thumbprint.
+func NewRSAKey(kid string, private *rsa.PrivateKey) *Key {
+	return newKey(kid, jwt.SigningMethodRS256, private, &private.PublicKey)
+}
+
+// NewRSAPublicKey creates an RS256 key that can only verify tokens
+func NewRSAPublicKey(kid string, public *rsa.PublicKey) *Key {
+	return newKey(kid, jwt.SigningMethodRS256, nil, public)
+}
+
+// NewEd25519Key creates an EdDSA signing key
+func NewEd25519Key(kid string, private ed25519.PrivateKey) *Key {
+	return newKey(kid, jwt.SigningMethodEdDSA, private, private.Public())
+}
+
+// NewEd25519PublicKey creates an EdDSA key that can only verify tokens
+func NewEd25519PublicKey(kid string, public ed25519.PublicKey) *Key {
+	return newKey(kid, jwt.SigningMethodEdDSA, nil, public)
+}
+
+func newKey(kid string, method jwt.SigningMethod, signKey, verifyKey interface{}) *Key {
+	k := &Key{ID: kid, Method: method, signKey: signKey, verifyKey: verifyKey}
+	if k.ID == "" {
+		k.ID = k.thumbprint()
+	}
+	return k
+}
+
+// CanSign reports whether the key holds a private key
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// ParseKeyPEM parses an RSA or Ed25519 key from PEM. Private keys may be
+// PKCS#1 or PKCS#8, public keys PKCS#1 or PKIX. An empty kid is replaced by
+// the key's thumbprint.
+func ParseKeyPEM(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", block.Type, err)
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(kid, key), nil
+	case *rsa.PublicKey:
+		return NewRSAPublicKey(kid, key), nil
+	case ed25519.PrivateKey:
+		return NewEd25519Key(kid, key), nil
+	case ed25519.PublicKey:
+		return NewEd25519PublicKey(kid, key), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// LoadKeyFile reads a PEM key from path; see ParseKeyPEM
+func LoadKeyFile(kid, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := ParseKeyPEM(kid, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// KeySet holds one active signing key and any number of keys that are only
+// used for verification, so tokens signed with a retired key stay valid
+// until they expire
+type KeySet struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   map[string]*Key
+}
+
+// NewKeySet creates a KeySet that signs with active and also accepts tokens
+// signed by verifiers
+func NewKeySet(active *Key, verifiers ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key)}
+	if err := ks.Rotate(active); err != nil {
+		return nil, err
+	}
+	for _, k := range verifiers {
+		if err := ks.Add(k); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// NewVerifierKeySet creates a KeySet without a signing key, for services
+// that accept tokens issued elsewhere. Issuing tokens with it fails with
+// ErrNoSigningKey.
+func NewVerifierKeySet(keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key)}
+	for _, k := range keys {
+		if err := ks.Add(k); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// Rotate makes next the signing key. The previous signing key is kept for
+// verification until it is removed.
+func (ks *KeySet) Rotate(next *Key) error {
+	if next == nil || !next.CanSign() {
+		return NewValidationError("key", "active key must contain a private key")
+	}
+	if next.ID == "" {
+		return NewValidationError("kid", "must not be empty")
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys[next.ID] = next
+	ks.active = next
+	return nil
+}
+
+// Add registers a verification key
+func (ks *KeySet) Add(k *Key) error {
+	if k == nil || k.ID == "" {
+		return NewValidationError("kid", "must not be empty")
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if existing, ok := ks.keys[k.ID]; ok && existing != k {
+		return NewValidationError("kid", fmt.Sprintf("duplicate key id %q", k.ID))
+	}
+	ks.keys[k.ID] = k
+	return nil
+}
+
+// Remove drops a verification key. The active key cannot be removed.
+func (ks *KeySet) Remove(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.active != nil && ks.active.ID == kid {
+		return NewValidationError("kid", "cannot remove the active key")
+	}
+	delete(ks.keys, kid)
+	return nil
+}
+
+// Active returns the current signing key
+func (ks *KeySet) Active() *Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active
+}
+
+// Lookup returns the key with the given kid
+func (ks *KeySet) Lookup(kid string) (*Key, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ParseJWK turns a public RSA or Ed25519 JWK into a verification key
+func ParseJWK(jwk JWK) (*Key, error) {
+	if jwk.KeyID == "" {
+		return nil, NewValidationError("kid", "must not be empty")
+	}
+	switch {
+	case jwk.KeyType == "RSA" && (jwk.Algorithm == "" || jwk.Algorithm == "RS256"):
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, NewValidationError("jwk", fmt.Sprintf("invalid RSA key %q", jwk.KeyID))
+		}
+		public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return NewRSAPublicKey(jwk.KeyID, public), nil
+	case jwk.KeyType == "OKP" && jwk.Curve == "Ed25519" && (jwk.Algorithm == "" || jwk.Algorithm == "EdDSA"):
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, NewValidationError("jwk", fmt.Sprintf("invalid Ed25519 key %q", jwk.KeyID))
+		}
+		return NewEd25519PublicKey(jwk.KeyID, ed25519.PublicKey(x)), nil
+	}
+	return nil, NewValidationError("jwk", fmt.Sprintf("unsupported key %q of type %s", jwk.KeyID, jwk.KeyType))
+}
+
+// ParseJWKS creates a verifier KeySet from a JWK Set document. Keys not
+// meant for signatures or of unsupported types are skipped.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+	var keys []*Key
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := ParseJWK(jwk)
+		if err != nil {
+			if jwk.KeyType != "RSA" && jwk.KeyType != "OKP" {
+				continue
+			}
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewVerifierKeySet(keys...)
+}
+
+// JWKS returns the public keys of the set. Symmetric keys are never
+// published.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		if jwk, ok := k.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+func (k *Key) jwk() (JWK, bool) {
+	switch public := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     k.ID,
+			Use:       "sig",
+			Algorithm: k.Method.Alg(),
+			N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			KeyID:     k.ID,
+			Use:       "sig",
+			Algorithm: k.Method.Alg(),
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(public),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint of the public key
+func (k *Key) thumbprint() string {
+	jwk, ok := k.jwk()
+	if !ok {
+		return ""
+	}
+
+	// Members must be in lexicographic order with no whitespace
+	var canonical []byte
+	switch jwk.KeyType {
+	case "RSA":
+		canonical, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N})
+	case "OKP":
+		canonical, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X})
+	}
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// JWKSHandler serves the key set at /.well-known/jwks.json
+func (ks *KeySet) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(ks.JWKS())
+	})
+}
@@ -0,0 +1,318 @@
+package jwtservice
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	return key
+}
+
+func newKeyService(t *testing.T, keys *KeySet) *JWTService {
+	t.Helper()
+	service, err := New(Config{Keys: keys})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return service
+}
+
+func TestJWTService_AsymmetricSigning(t *testing.T) {
+	tests := []struct {
+		name string
+		key  *Key
+		alg  string
+	}{
+		{"RS256", NewRSAKey("rsa-1", newRSAKey(t)), "RS256"},
+		{"EdDSA", NewEd25519Key("ed-1", newEd25519Key(t)), "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewKeySet(tt.key)
+			if err != nil {
+				t.Fatalf("NewKeySet() error = %v", err)
+			}
+			service := newKeyService(t, keys)
+
+			token, err := service.GenerateToken(123, "test@example.com")
+			if err != nil {
+				t.Fatalf("GenerateToken() error = %v", err)
+			}
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(token, &Claims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified() error = %v", err)
+			}
+			if parsed.Header["alg"] != tt.alg || parsed.Header["kid"] != tt.key.ID {
+				t.Errorf("Unexpected header %v", parsed.Header)
+			}
+
+			claims, err := service.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("ValidateToken() error = %v", err)
+			}
+			if claims.UserID != 123 {
+				t.Errorf("ValidateToken() userID = %v, want 123", claims.UserID)
+			}
+		})
+	}
+}
+
+func TestJWTService_KeyRotation(t *testing.T) {
+	oldKey := NewRSAKey("old", newRSAKey(t))
+	newKey := NewEd25519Key("new", newEd25519Key(t))
+
+	keys, _ := NewKeySet(oldKey)
+	service := newKeyService(t, keys)
+	oldToken, _ := service.GenerateToken(123, "test@example.com")
+
+	if err := keys.Rotate(newKey); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	newToken, _ := service.GenerateToken(123, "test@example.com")
+
+	parsed, _, _ := new(jwt.Parser).ParseUnverified(newToken, &Claims{})
+	if parsed.Header["kid"] != "new" {
+		t.Errorf("New tokens should be signed with the rotated key, got kid %v", parsed.Header["kid"])
+	}
+
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := service.ValidateToken(token); err != nil {
+			t.Errorf("Token signed with %s key should verify: %v", name, err)
+		}
+	}
+
+	if err := keys.Remove("new"); err == nil {
+		t.Error("Remove() should refuse to remove the active key")
+	}
+	if err := keys.Remove("old"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := service.ValidateToken(oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateToken(removed kid) error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestJWTService_VerifyOnlyKeys(t *testing.T) {
+	private := newRSAKey(t)
+	signer := newKeyService(t, mustKeySet(t, NewRSAKey("k1", private)))
+
+	// A service that only holds the public key can verify but not sign
+	if _, err := NewKeySet(NewRSAPublicKey("k1", &private.PublicKey)); err == nil {
+		t.Error("NewKeySet() should require a private active key")
+	}
+	verifier := newKeyService(t, mustKeySet(t, NewEd25519Key("other", newEd25519Key(t)), NewRSAPublicKey("k1", &private.PublicKey)))
+
+	token, _ := signer.GenerateToken(123, "test@example.com")
+	if _, err := verifier.ValidateToken(token); err != nil {
+		t.Errorf("ValidateToken() with public key error = %v", err)
+	}
+}
+
+func TestJWTService_RejectsAlgorithmConfusion(t *testing.T) {
+	private := newRSAKey(t)
+	service := newKeyService(t, mustKeySet(t, NewRSAKey("rsa", private)))
+
+	// Sign an HS256 token using the published RSA public key as the secret
+	publicDER, _ := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	claims := Claims{UserID: 1, Email: "attacker@example.com"}
+	claims.ExpiresAt = jwt.NewNumericDate(jwt.TimeFunc().Add(TokenTTL))
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "rsa"
+	token, err := forged.SignedString(publicPEM)
+	if err != nil {
+		t.Fatalf("Failed to sign forged token: %v", err)
+	}
+
+	_, err = service.ValidateToken(token)
+	var methodErr InvalidSigningMethodError
+	if !errors.As(err, &methodErr) {
+		t.Fatalf("ValidateToken(forged) error = %v, want InvalidSigningMethodError", err)
+	}
+	if methodErr.Method != "HS256" {
+		t.Errorf("InvalidSigningMethodError.Method = %v, want HS256", methodErr.Method)
+	}
+
+	// The default HMAC service rejects asymmetric tokens the same way
+	hmacService, _ := NewJWTService("secret")
+	rsaToken, _ := service.GenerateToken(1, "user@example.com")
+	if _, err := hmacService.ValidateToken(rsaToken); err == nil {
+		t.Error("HMAC service should reject RS256 tokens")
+	}
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	edKey := newEd25519Key(t)
+
+	pkcs8RSA, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	pkcs8Ed, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	pkixRSA, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	pkixEd, _ := x509.MarshalPKIXPublicKey(edKey.Public())
+
+	tests := []struct {
+		name      string
+		block     *pem.Block
+		alg       string
+		canSign   bool
+		wantError bool
+	}{
+		{"PKCS1 RSA private", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, "RS256", true, false},
+		{"PKCS8 RSA private", &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8RSA}, "RS256", true, false},
+		{"PKCS8 Ed25519 private", &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Ed}, "EdDSA", true, false},
+		{"PKCS1 RSA public", &pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)}, "RS256", false, false},
+		{"PKIX RSA public", &pem.Block{Type: "PUBLIC KEY", Bytes: pkixRSA}, "RS256", false, false},
+		{"PKIX Ed25519 public", &pem.Block{Type: "PUBLIC KEY", Bytes: pkixEd}, "EdDSA", false, false},
+		{"certificate", &pem.Block{Type: "CERTIFICATE", Bytes: []byte("x")}, "", false, true},
+		{"garbage", &pem.Block{Type: "PRIVATE KEY", Bytes: []byte("x")}, "", false, true},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_")+".pem")
+			if err := os.WriteFile(path, pem.EncodeToMemory(tt.block), 0o600); err != nil {
+				t.Fatalf("Failed to write key: %v", err)
+			}
+
+			key, err := LoadKeyFile("", path)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("LoadKeyFile() error = %v, wantError %v", err, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+			if key.Method.Alg() != tt.alg || key.CanSign() != tt.canSign {
+				t.Errorf("LoadKeyFile() = %s canSign=%v, want %s canSign=%v", key.Method.Alg(), key.CanSign(), tt.alg, tt.canSign)
+			}
+			if key.ID == "" {
+				t.Error("LoadKeyFile() should derive a kid from the key")
+			}
+		})
+	}
+
+	// Private and public halves of the same key get the same thumbprint kid
+	private, _ := ParseKeyPEM("", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8RSA}))
+	public, _ := ParseKeyPEM("", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkixRSA}))
+	if private.ID != public.ID {
+		t.Errorf("Thumbprints differ: %s != %s", private.ID, public.ID)
+	}
+
+	if _, err := ParseKeyPEM("", []byte("not pem")); err == nil {
+		t.Error("ParseKeyPEM() should fail without a PEM block")
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	rsaKey := NewRSAKey("rsa", newRSAKey(t))
+	edKey := NewEd25519Key("ed", newEd25519Key(t))
+	keys := mustKeySet(t, edKey, rsaKey, NewHMACKey("hmac", []byte("secret")))
+	service := newKeyService(t, keys)
+
+	rr := httptest.NewRecorder()
+	service.JWKSHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("JWKSHandler() status = %d, want 200", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(rr.Body).Decode(&set); err != nil {
+		t.Fatalf("Failed to decode JWKS: %v", err)
+	}
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2 (symmetric keys must not be published)", len(set.Keys))
+	}
+
+	byID := map[string]JWK{}
+	for _, k := range set.Keys {
+		byID[k.KeyID] = k
+	}
+	if k := byID["rsa"]; k.KeyType != "RSA" || k.Algorithm != "RS256" || k.N == "" || k.E != "AQAB" {
+		t.Errorf("Unexpected RSA JWK %+v", k)
+	}
+	if k := byID["ed"]; k.KeyType != "OKP" || k.Curve != "Ed25519" || k.Algorithm != "EdDSA" || k.X == "" {
+		t.Errorf("Unexpected Ed25519 JWK %+v", k)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaSigner := newKeyService(t, mustKeySet(t, NewRSAKey("rsa", newRSAKey(t))))
+	edSigner := newKeyService(t, mustKeySet(t, NewEd25519Key("ed", newEd25519Key(t))))
+
+	set := JWKS{Keys: append(rsaSigner.JWKS().Keys, edSigner.JWKS().Keys...)}
+	set.Keys = append(set.Keys,
+		JWK{KeyType: "EC", KeyID: "ec", Use: "sig", Algorithm: "ES256"},
+		JWK{KeyType: "RSA", KeyID: "enc", Use: "enc"},
+	)
+	data, _ := json.Marshal(set)
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatalf("ParseJWKS() error = %v", err)
+	}
+	verifier := newKeyService(t, keys)
+	for _, signer := range []*JWTService{rsaSigner, edSigner} {
+		token, _ := signer.GenerateToken(123, "test@example.com")
+		if _, err := verifier.ValidateToken(token); err != nil {
+			t.Errorf("ValidateToken() with JWKS key error = %v", err)
+		}
+	}
+	if _, ok := keys.Lookup("ec"); ok {
+		t.Error("ParseJWKS() should skip unsupported key types")
+	}
+	if _, ok := keys.Lookup("enc"); ok {
+		t.Error("ParseJWKS() should skip encryption keys")
+	}
+
+	if _, err := verifier.GenerateToken(123, "test@example.com"); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("GenerateToken() with verifier keys error = %v, want %v", err, ErrNoSigningKey)
+	}
+
+	if _, err := ParseJWKS([]byte(`{"keys":[{"kty":"OKP","crv":"Ed25519","kid":"bad","x":"AAAA"}]}`)); err == nil {
+		t.Error("ParseJWKS() should reject a malformed Ed25519 key")
+	}
+}
+
+func mustKeySet(t *testing.T, active *Key, verifiers ...*Key) *KeySet {
+	t.Helper()
+	keys, err := NewKeySet(active, verifiers...)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	return keys
+}
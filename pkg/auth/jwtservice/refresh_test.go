@@ -0,0 +1,297 @@
+package jwtservice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newSQLiteStore(t *testing.T) *SQLRevocationStore {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	store := NewSQLRevocationStore(db)
+	if err := store.CreateSchema(context.Background()); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	return store
+}
+
+// forEachStore runs fn against every RevocationStore implementation
+func forEachStore(t *testing.T, fn func(t *testing.T, service *JWTService)) {
+	stores := map[string]func(t *testing.T) RevocationStore{
+		"memory": func(t *testing.T) RevocationStore { return NewMemoryRevocationStore() },
+		"sql":    func(t *testing.T) RevocationStore { return newSQLiteStore(t) },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			service, err := New(Config{SecretKey: "test-secret", Store: newStore(t)})
+			if err != nil {
+				t.Fatalf("Failed to create service: %v", err)
+			}
+			fn(t, service)
+		})
+	}
+}
+
+func TestJWTService_TokenPair(t *testing.T) {
+	service, _ := NewJWTService("test-secret")
+
+	pair, err := service.GenerateTokenPair(123, "test@example.com")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	if !pair.AccessExpiresAt.Before(pair.RefreshExpiresAt) {
+		t.Error("Access token should expire before the refresh token")
+	}
+
+	claims, err := service.ValidateToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("Access token should be valid: %v", err)
+	}
+	if claims.TokenType != TokenTypeAccess || claims.FamilyID == "" {
+		t.Errorf("Unexpected access claims: %+v", claims)
+	}
+
+	if _, err := service.ValidateToken(pair.RefreshToken); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("ValidateToken(refresh) error = %v, want %v", err, ErrWrongTokenType)
+	}
+	if _, err := service.Refresh(context.Background(), pair.AccessToken); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("Refresh(access) error = %v, want %v", err, ErrWrongTokenType)
+	}
+}
+
+func TestJWTService_RefreshRotation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, service *JWTService) {
+		ctx := context.Background()
+
+		first, _ := service.GenerateTokenPair(123, "test@example.com")
+		second, err := service.Refresh(ctx, first.RefreshToken)
+		if err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+		if second.RefreshToken == first.RefreshToken {
+			t.Error("Refresh() should rotate the refresh token")
+		}
+
+		// Replaying the rotated token revokes the whole family
+		if _, err := service.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrTokenReused) {
+			t.Fatalf("Refresh(reused) error = %v, want %v", err, ErrTokenReused)
+		}
+		if _, err := service.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("Refresh(after reuse) error = %v, want %v", err, ErrTokenRevoked)
+		}
+		if _, err := service.ValidateToken(second.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("ValidateToken(after reuse) error = %v, want %v", err, ErrTokenRevoked)
+		}
+
+		// Other families are unaffected
+		other, _ := service.GenerateTokenPair(123, "test@example.com")
+		if _, err := service.ValidateToken(other.AccessToken); err != nil {
+			t.Errorf("Unrelated family should stay valid: %v", err)
+		}
+	})
+}
+
+func TestJWTService_RefreshSubject(t *testing.T) {
+	forEachStore(t, func(t *testing.T, service *JWTService) {
+		ctx := context.Background()
+
+		first, _ := service.IssueTokenPair(Subject{UserID: 123, Email: "test@example.com", Roles: []string{"user"}})
+		second, err := service.RefreshSubject(ctx, first.RefreshToken, func(ctx context.Context, claims *Claims) (Subject, error) {
+			return Subject{UserID: claims.UserID, Email: claims.Email, Roles: []string{"editor"}}, nil
+		})
+		if err != nil {
+			t.Fatalf("RefreshSubject() error = %v", err)
+		}
+		claims, err := service.ValidateToken(second.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateToken() error = %v", err)
+		}
+		if len(claims.Roles) != 1 || claims.Roles[0] != "editor" {
+			t.Errorf("Roles = %v, want [editor]", claims.Roles)
+		}
+
+		// The subject cannot change to another user
+		_, err = service.RefreshSubject(ctx, second.RefreshToken, func(ctx context.Context, claims *Claims) (Subject, error) {
+			return Subject{UserID: 456, Email: "other@example.com"}, nil
+		})
+		if !errors.Is(err, ErrInvalidClaims) {
+			t.Errorf("RefreshSubject(other user) error = %v, want %v", err, ErrInvalidClaims)
+		}
+	})
+}
+
+func TestJWTService_RevokeToken(t *testing.T) {
+	forEachStore(t, func(t *testing.T, service *JWTService) {
+		ctx := context.Background()
+
+		token, _ := service.GenerateToken(123, "test@example.com")
+		other, _ := service.GenerateToken(123, "test@example.com")
+		claims, _ := service.ValidateToken(token)
+
+		if err := service.RevokeToken(ctx, claims); err != nil {
+			t.Fatalf("RevokeToken() error = %v", err)
+		}
+		if _, err := service.ValidateToken(token); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("ValidateToken(revoked) error = %v, want %v", err, ErrTokenRevoked)
+		}
+		if _, err := service.ValidateToken(other); err != nil {
+			t.Errorf("Other tokens should stay valid: %v", err)
+		}
+	})
+}
+
+func TestJWTService_RevokeFamily(t *testing.T) {
+	forEachStore(t, func(t *testing.T, service *JWTService) {
+		ctx := context.Background()
+
+		pair, _ := service.GenerateTokenPair(123, "test@example.com")
+		claims, _ := service.ValidateToken(pair.AccessToken)
+
+		if err := service.RevokeFamily(ctx, claims.FamilyID); err != nil {
+			t.Fatalf("RevokeFamily() error = %v", err)
+		}
+		if _, err := service.ValidateToken(pair.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("ValidateToken() error = %v, want %v", err, ErrTokenRevoked)
+		}
+		if _, err := service.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("Refresh() error = %v, want %v", err, ErrTokenRevoked)
+		}
+	})
+}
+
+func TestJWTService_RevokeSessions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, service *JWTService) {
+		ctx := context.Background()
+
+		pair, _ := service.GenerateTokenPair(123, "test@example.com")
+		access, _ := service.GenerateToken(123, "test@example.com")
+		other, _ := service.GenerateTokenPair(456, "other@example.com")
+
+		if err := service.RevokeSessions(ctx, 123); err != nil {
+			t.Fatalf("RevokeSessions() error = %v", err)
+		}
+		for name, token := range map[string]string{"pair": pair.AccessToken, "single": access} {
+			if _, err := service.ValidateToken(token); !errors.Is(err, ErrTokenRevoked) {
+				t.Errorf("ValidateToken(%s) error = %v, want %v", name, err, ErrTokenRevoked)
+			}
+		}
+		if _, err := service.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("Refresh() error = %v, want %v", err, ErrTokenRevoked)
+		}
+		if _, err := service.ValidateToken(other.AccessToken); err != nil {
+			t.Errorf("Other users' tokens should stay valid: %v", err)
+		}
+
+		// Tokens issued afterwards carry the new version
+		fresh, _ := service.GenerateTokenPair(123, "test@example.com")
+		if _, err := service.ValidateToken(fresh.AccessToken); err != nil {
+			t.Errorf("ValidateToken(new token) error = %v", err)
+		}
+		if _, err := service.Refresh(ctx, fresh.RefreshToken); err != nil {
+			t.Errorf("Refresh(new token) error = %v", err)
+		}
+	})
+}
+
+func TestJWTService_MFAToken(t *testing.T) {
+	forEachStore(t, func(t *testing.T, service *JWTService) {
+		ctx := context.Background()
+		subject := Subject{UserID: 123, Email: "test@example.com", Roles: []string{"editor"}}
+
+		pending, expiresAt, err := service.IssueMFAToken(subject)
+		if err != nil {
+			t.Fatalf("IssueMFAToken() error = %v", err)
+		}
+		if ttl := time.Until(expiresAt); ttl <= 0 || ttl > DefaultMFATTL {
+			t.Errorf("IssueMFAToken() expires in %s, want at most %s", ttl, DefaultMFATTL)
+		}
+
+		// The pending token is no access or refresh token
+		if _, err := service.ValidateToken(pending); !errors.Is(err, ErrWrongTokenType) {
+			t.Errorf("ValidateToken(pending) error = %v, want %v", err, ErrWrongTokenType)
+		}
+		if _, err := service.Refresh(ctx, pending); !errors.Is(err, ErrWrongTokenType) {
+			t.Errorf("Refresh(pending) error = %v, want %v", err, ErrWrongTokenType)
+		}
+		pair, _ := service.GenerateTokenPair(123, "test@example.com")
+		if _, err := service.CompleteMFA(ctx, pair.AccessToken); !errors.Is(err, ErrWrongTokenType) {
+			t.Errorf("CompleteMFA(access) error = %v, want %v", err, ErrWrongTokenType)
+		}
+
+		if claims, err := service.ValidateMFAToken(ctx, pending); err != nil || claims.UserID != 123 {
+			t.Fatalf("ValidateMFAToken() = %+v, %v", claims, err)
+		}
+		completed, err := service.CompleteMFA(ctx, pending)
+		if err != nil {
+			t.Fatalf("CompleteMFA() error = %v", err)
+		}
+		claims, err := service.ValidateToken(completed.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateToken() error = %v", err)
+		}
+		if len(claims.Roles) != 1 || claims.Roles[0] != "editor" {
+			t.Errorf("Roles = %v, want [editor]", claims.Roles)
+		}
+		if _, err := service.CompleteMFA(ctx, pending); !errors.Is(err, ErrTokenReused) {
+			t.Errorf("CompleteMFA() again error = %v, want %v", err, ErrTokenReused)
+		}
+	})
+}
+
+func TestRevocationStore_Expiry(t *testing.T) {
+	stores := map[string]RevocationStore{
+		"memory": NewMemoryRevocationStore(),
+		"sql":    newSQLiteStore(t),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if err := store.Revoke(ctx, "expired", time.Now().Add(-time.Minute)); err != nil {
+				t.Fatalf("Revoke() error = %v", err)
+			}
+			if revoked, _ := store.IsRevoked(ctx, "expired"); revoked {
+				t.Error("Expired revocations should be ignored")
+			}
+
+			first, err := store.MarkUsed(ctx, "jti", time.Now().Add(time.Hour))
+			if err != nil || !first {
+				t.Fatalf("MarkUsed() = %v, %v, want true", first, err)
+			}
+			if again, _ := store.MarkUsed(ctx, "jti", time.Now().Add(time.Hour)); again {
+				t.Error("MarkUsed() should report a second use")
+			}
+		})
+	}
+}
+
+func TestSQLRevocationStore_Purge(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+
+	store.Revoke(ctx, "expired", time.Now().Add(-time.Minute))
+	store.Revoke(ctx, "active", time.Now().Add(time.Hour))
+	if err := store.Purge(ctx); err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+
+	var count int
+	store.db.QueryRow("SELECT COUNT(*) FROM jwt_revocations").Scan(&count)
+	if count != 1 {
+		t.Errorf("Purge() left %d rows, want 1", count)
+	}
+}
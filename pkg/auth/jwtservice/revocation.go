@@ -0,0 +1,113 @@
+package jwtservice
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationStore records revoked token IDs and which refresh tokens have
+// already been used. Entries only need to be kept until expiresAt, after
+// which the token would be rejected as expired anyway.
+type RevocationStore interface {
+	// Revoke denylists id until expiresAt
+	Revoke(ctx context.Context, id string, expiresAt time.Time) error
+	// IsRevoked reports whether any of ids is denylisted
+	IsRevoked(ctx context.Context, ids ...string) (bool, error)
+	// MarkUsed records the use of a refresh token. It returns false if the
+	// token had been used before. It must be atomic across instances.
+	MarkUsed(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+	// SessionVersion returns the current session version of a user, 0 if it
+	// was never bumped
+	SessionVersion(ctx context.Context, userID string) (int, error)
+	// BumpSessionVersion increments the session version of a user, which
+	// invalidates every token issued before. It must be atomic across
+	// instances.
+	BumpSessionVersion(ctx context.Context, userID string) (int, error)
+}
+
+// MemoryRevocationStore is a RevocationStore for a single process
+type MemoryRevocationStore struct {
+	mu       sync.Mutex
+	revoked  map[string]time.Time
+	used     map[string]time.Time
+	versions map[string]int
+}
+
+// NewMemoryRevocationStore creates an empty MemoryRevocationStore
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked:  make(map[string]time.Time),
+		used:     make(map[string]time.Time),
+		versions: make(map[string]int),
+	}
+}
+
+// Revoke implements RevocationStore
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge(time.Now())
+	if current, ok := s.revoked[id]; !ok || expiresAt.After(current) {
+		s.revoked[id] = expiresAt
+	}
+	return nil
+}
+
+// IsRevoked implements RevocationStore
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		if expiresAt, ok := s.revoked[id]; ok && now.Before(expiresAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MarkUsed implements RevocationStore
+func (s *MemoryRevocationStore) MarkUsed(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+	if _, ok := s.used[jti]; ok {
+		return false, nil
+	}
+	s.used[jti] = expiresAt
+	return true, nil
+}
+
+// SessionVersion implements RevocationStore
+func (s *MemoryRevocationStore) SessionVersion(ctx context.Context, userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.versions[userID], nil
+}
+
+// BumpSessionVersion implements RevocationStore
+func (s *MemoryRevocationStore) BumpSessionVersion(ctx context.Context, userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[userID]++
+	return s.versions[userID], nil
+}
+
+// purge drops expired entries; callers must hold s.mu
+func (s *MemoryRevocationStore) purge(now time.Time) {
+	for id, expiresAt := range s.revoked {
+		if !now.Before(expiresAt) {
+			delete(s.revoked, id)
+		}
+	}
+	for jti, expiresAt := range s.used {
+		if !now.Before(expiresAt) {
+			delete(s.used, jti)
+		}
+	}
+}
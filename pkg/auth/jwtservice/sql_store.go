@@ -0,0 +1,147 @@
+package jwtservice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RevocationSchema creates the tables used by SQLRevocationStore. It works
+// on both PostgreSQL and SQLite.
+const RevocationSchema = `
+CREATE TABLE IF NOT EXISTS jwt_revocations (
+	id VARCHAR(128) PRIMARY KEY,
+	expires_at TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS jwt_used_refresh_tokens (
+	jti VARCHAR(128) PRIMARY KEY,
+	expires_at TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS jwt_session_versions (
+	user_id VARCHAR(128) PRIMARY KEY,
+	version INTEGER NOT NULL
+);`
+
+// SQLRevocationStore is a RevocationStore shared by every instance using
+// the same database
+type SQLRevocationStore struct {
+	db *sql.DB
+}
+
+// NewSQLRevocationStore creates a SQLRevocationStore. The tables must exist;
+// see RevocationSchema and CreateSchema.
+func NewSQLRevocationStore(db *sql.DB) *SQLRevocationStore {
+	return &SQLRevocationStore{db: db}
+}
+
+// CreateSchema creates the store's tables if they do not exist
+func (s *SQLRevocationStore) CreateSchema(ctx context.Context) error {
+	for _, stmt := range strings.Split(RevocationSchema, ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create revocation schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Revoke implements RevocationStore
+func (s *SQLRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO jwt_revocations (id, expires_at) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET expires_at = excluded.expires_at`,
+		id, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked implements RevocationStore
+func (s *SQLRevocationStore) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	args := make([]interface{}, 0, len(ids)+1)
+	placeholders := make([]string, 0, len(ids))
+	for i, id := range ids {
+		args = append(args, id)
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+	}
+	args = append(args, time.Now().UTC())
+
+	var count int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM jwt_revocations WHERE id IN ("+strings.Join(placeholders, ", ")+") AND expires_at > $"+strconv.Itoa(len(args)),
+		args...).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check revocation: %w", err)
+	}
+	return count > 0, nil
+}
+
+// MarkUsed implements RevocationStore. The primary key makes the insert
+// atomic, so only one concurrent refresh with the same token succeeds.
+func (s *SQLRevocationStore) MarkUsed(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO jwt_used_refresh_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`,
+		jti, expiresAt.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to record refresh token use: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// SessionVersion implements RevocationStore
+func (s *SQLRevocationStore) SessionVersion(ctx context.Context, userID string) (int, error) {
+	var version int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT version FROM jwt_session_versions WHERE user_id = $1", userID,
+	).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load session version: %w", err)
+	}
+	return version, nil
+}
+
+// BumpSessionVersion implements RevocationStore
+func (s *SQLRevocationStore) BumpSessionVersion(ctx context.Context, userID string) (int, error) {
+	var version int
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO jwt_session_versions (user_id, version) VALUES ($1, 1)
+		ON CONFLICT (user_id) DO UPDATE SET version = jwt_session_versions.version + 1
+		RETURNING version`,
+		userID,
+	).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to bump session version: %w", err)
+	}
+	return version, nil
+}
+
+// Purge deletes entries that have expired. Call it periodically to keep the
+// tables small.
+func (s *SQLRevocationStore) Purge(ctx context.Context) error {
+	now := time.Now().UTC()
+	for _, table := range []string{"jwt_revocations", "jwt_used_refresh_tokens"} {
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM "+table+" WHERE expires_at <= $1", now); err != nil {
+			return fmt.Errorf("failed to purge %s: %w", table, err)
+		}
+	}
+	return nil
+}
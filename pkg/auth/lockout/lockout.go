@@ -0,0 +1,238 @@
+// Package lockout slows down and then blocks repeated failed logins, per
+// account and per client IP.
+//
+// After a few free failures each further failure doubles the delay before
+// the next attempt is accepted. Reaching the failure limit within the window
+// locks the account or IP for LockoutDuration, which only an administrator
+// can lift early.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Defaults for zero Config fields
+const (
+	DefaultMaxAccountFailures = 5
+	DefaultMaxIPFailures      = 20
+	DefaultFreeFailures       = 2
+	DefaultWindow             = 15 * time.Minute
+	DefaultLockoutDuration    = 15 * time.Minute
+	DefaultBaseDelay          = time.Second
+	DefaultMaxDelay           = time.Minute
+)
+
+// Audit event types
+const (
+	EventLocked   = "login.locked"
+	EventUnlocked = "login.unlocked"
+)
+
+// ErrLocked is matched by every *LockedError
+var ErrLocked = errors.New("too many failed login attempts")
+
+// LockedError is returned by Check while an account or IP is locked or
+// backing off
+type LockedError struct {
+	// Locked is true for a lockout and false for a backoff delay
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", ErrLocked, e.RetryAfter.Round(time.Second))
+}
+
+// Is makes errors.Is(err, ErrLocked) match
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Event is an audit record of a lockout or unlock
+type Event struct {
+	Type string    `json:"type"`
+	Key  string    `json:"key"`
+	Time time.Time `json:"time"`
+	// Failures that triggered a lockout
+	Failures int `json:"failures,omitempty"`
+	// Until is when a lockout ends
+	Until time.Time `json:"until,omitzero"`
+	// Actor identifies the administrator who lifted a lockout
+	Actor string `json:"actor,omitempty"`
+}
+
+// Config configures a Limiter. Zero fields use the defaults above.
+type Config struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	// FreeFailures is how many failures are allowed before backoff starts;
+	// a negative value starts backoff after the first failure
+	FreeFailures int
+	// Window is how long a failure counts towards the limit
+	Window          time.Duration
+	LockoutDuration time.Duration
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	// Store defaults to a MemoryStore, which only works for a single instance
+	Store Store
+	// Audit receives lockout and unlock events
+	Audit func(ctx context.Context, e Event)
+}
+
+// Limiter tracks failed logins
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+}
+
+// New creates a Limiter
+func New(cfg Config) *Limiter {
+	if cfg.MaxAccountFailures <= 0 {
+		cfg.MaxAccountFailures = DefaultMaxAccountFailures
+	}
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = DefaultMaxIPFailures
+	}
+	if cfg.FreeFailures < 0 {
+		cfg.FreeFailures = 0
+	} else if cfg.FreeFailures == 0 {
+		cfg.FreeFailures = DefaultFreeFailures
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = DefaultLockoutDuration
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = DefaultBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = DefaultMaxDelay
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	if cfg.Audit == nil {
+		cfg.Audit = func(context.Context, Event) {}
+	}
+	return &Limiter{cfg: cfg, now: time.Now}
+}
+
+// AccountKey names the record of an account
+func AccountKey(account string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(account))
+}
+
+// IPKey names the record of a client IP address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns a *LockedError if account or ip may not attempt a login
+// right now. Empty values are skipped.
+func (l *Limiter) Check(ctx context.Context, account, ip string) error {
+	now := l.now()
+
+	var worst *LockedError
+	for _, key := range keys(account, ip) {
+		r, err := l.cfg.Store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		var wait *LockedError
+		if r.LockedUntil.After(now) {
+			wait = &LockedError{Locked: true, RetryAfter: r.LockedUntil.Sub(now)}
+		} else if next := r.LastFailure.Add(l.delay(r.Failures)); next.After(now) {
+			wait = &LockedError{RetryAfter: next.Sub(now)}
+		}
+		if wait != nil && (worst == nil || wait.RetryAfter > worst.RetryAfter) {
+			worst = wait
+		}
+	}
+
+	if worst != nil {
+		return worst
+	}
+	return nil
+}
+
+// Failure records a failed login for account and ip and locks whichever
+// reached its limit
+func (l *Limiter) Failure(ctx context.Context, account, ip string) error {
+	now := l.now()
+	windowStart := now.Add(-l.cfg.Window)
+
+	for _, key := range keys(account, ip) {
+		r, err := l.cfg.Store.AddFailure(ctx, key, now, windowStart)
+		if err != nil {
+			return err
+		}
+
+		limit := l.cfg.MaxAccountFailures
+		if strings.HasPrefix(key, "ip:") {
+			limit = l.cfg.MaxIPFailures
+		}
+		if r.Failures < limit {
+			continue
+		}
+
+		until := now.Add(l.cfg.LockoutDuration)
+		if err := l.cfg.Store.Lock(ctx, key, until); err != nil {
+			return err
+		}
+		l.cfg.Audit(ctx, Event{Type: EventLocked, Key: key, Time: now, Failures: r.Failures, Until: until})
+	}
+	return nil
+}
+
+// Success clears the failures of account after a successful login. The IP
+// record is kept, so an attacker cannot reset it by logging into their own
+// account between guesses.
+func (l *Limiter) Success(ctx context.Context, account string) error {
+	return l.cfg.Store.Reset(ctx, AccountKey(account))
+}
+
+// Status returns the record for key, e.g. AccountKey(email)
+func (l *Limiter) Status(ctx context.Context, key string) (Record, error) {
+	return l.cfg.Store.Get(ctx, key)
+}
+
+// Unlock lifts the lockout and clears the failures of key on behalf of actor
+func (l *Limiter) Unlock(ctx context.Context, key, actor string) error {
+	if err := l.cfg.Store.Reset(ctx, key); err != nil {
+		return err
+	}
+	l.cfg.Audit(ctx, Event{Type: EventUnlocked, Key: key, Time: l.now(), Actor: actor})
+	return nil
+}
+
+// delay is the wait after the given number of failures
+func (l *Limiter) delay(failures int) time.Duration {
+	n := failures - l.cfg.FreeFailures
+	if n <= 0 {
+		return 0
+	}
+
+	d := l.cfg.BaseDelay
+	for i := 1; i < n && d < l.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, l.cfg.MaxDelay)
+}
+
+func keys(account, ip string) []string {
+	var keys []string
+	if strings.TrimSpace(account) != "" {
+		keys = append(keys, AccountKey(account))
+	}
+	if ip != "" {
+		keys = append(keys, IPKey(ip))
+	}
+	return keys
+}
@@ -0,0 +1,262 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newSQLiteStore(t *testing.T) *SQLStore {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	store := NewSQLStore(db)
+	if err := store.CreateSchema(context.Background()); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	return store
+}
+
+// clock is a manually advanced time source
+type clock struct {
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// limiter creates a Limiter that reads the time from c
+func (c *clock) limiter(cfg Config) *Limiter {
+	l := New(cfg)
+	l.now = func() time.Time { return c.now }
+	return l
+}
+
+// forEachStore runs fn against every Store implementation
+func forEachStore(t *testing.T, fn func(t *testing.T, store Store)) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"sql":    func(t *testing.T) Store { return newSQLiteStore(t) },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			fn(t, newStore(t))
+		})
+	}
+}
+
+func retryAfter(t *testing.T, err error) *LockedError {
+	t.Helper()
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("Check() error = %v, want *LockedError", err)
+	}
+	return locked
+}
+
+func TestLimiter_BackoffAndLockout(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		c := newClock()
+
+		var events []Event
+		l := c.limiter(Config{
+			MaxAccountFailures: 5,
+			FreeFailures:       2,
+			BaseDelay:          time.Second,
+			LockoutDuration:    10 * time.Minute,
+			Store:              store,
+			Audit:              func(ctx context.Context, e Event) { events = append(events, e) },
+		})
+
+		// Free failures do not delay the next attempt
+		for i := 0; i < 2; i++ {
+			if err := l.Failure(ctx, "Alice@example.com", "10.0.0.1"); err != nil {
+				t.Fatalf("Failure() error = %v", err)
+			}
+			if err := l.Check(ctx, "alice@example.com", "10.0.0.1"); err != nil {
+				t.Fatalf("Check() after %d failures error = %v, want nil", i+1, err)
+			}
+		}
+
+		// Then the delay doubles with every failure
+		for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+			l.Failure(ctx, "alice@example.com", "10.0.0.1")
+			locked := retryAfter(t, l.Check(ctx, "alice@example.com", ""))
+			if locked.Locked || locked.RetryAfter != want {
+				t.Errorf("failure %d: Check() = %+v, want backoff of %s", i+3, locked, want)
+			}
+			c.Advance(want)
+			if err := l.Check(ctx, "alice@example.com", ""); err != nil {
+				t.Errorf("Check() after waiting error = %v, want nil", err)
+			}
+		}
+
+		// The fifth failure locks the account
+		l.Failure(ctx, "alice@example.com", "10.0.0.1")
+		locked := retryAfter(t, l.Check(ctx, "alice@example.com", "10.0.0.9"))
+		if !locked.Locked || locked.RetryAfter != 10*time.Minute {
+			t.Errorf("Check() = %+v, want 10m lockout", locked)
+		}
+		if !errors.Is(locked, ErrLocked) {
+			t.Error("LockedError should match ErrLocked")
+		}
+		if err := l.Check(ctx, "bob@example.com", "10.0.0.2"); err != nil {
+			t.Errorf("Other accounts should not be locked: %v", err)
+		}
+		if locked := retryAfter(t, l.Check(ctx, "bob@example.com", "10.0.0.1")); locked.Locked {
+			t.Errorf("The IP should only be backing off below its limit, got %+v", locked)
+		}
+		if len(events) != 1 || events[0].Type != EventLocked || events[0].Key != AccountKey("alice@example.com") || events[0].Failures != 5 {
+			t.Errorf("Unexpected audit events %+v", events)
+		}
+
+		// The lockout expires and the counter starts over
+		c.Advance(10 * time.Minute)
+		if err := l.Check(ctx, "alice@example.com", ""); err != nil {
+			t.Errorf("Check() after lockout error = %v, want nil", err)
+		}
+		l.Failure(ctx, "alice@example.com", "")
+		if err := l.Check(ctx, "alice@example.com", ""); err != nil {
+			t.Errorf("Check() after first failure following a lockout error = %v, want nil", err)
+		}
+	})
+}
+
+func TestLimiter_Window(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		c := newClock()
+		l := c.limiter(Config{MaxAccountFailures: 3, Window: time.Minute, Store: store})
+
+		l.Failure(ctx, "alice@example.com", "")
+		l.Failure(ctx, "alice@example.com", "")
+		c.Advance(2 * time.Minute)
+		l.Failure(ctx, "alice@example.com", "")
+
+		r, err := l.Status(ctx, AccountKey("alice@example.com"))
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+		if r.Failures != 1 || !r.LockedUntil.IsZero() {
+			t.Errorf("Status() = %+v, want 1 failure and no lock after the window passed", r)
+		}
+	})
+}
+
+func TestLimiter_IPLockout(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		c := newClock()
+		l := c.limiter(Config{MaxAccountFailures: 100, MaxIPFailures: 3, FreeFailures: 10, Store: store})
+
+		// Spraying many accounts from one IP locks the IP
+		for _, account := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+			l.Failure(ctx, account, "10.0.0.1")
+		}
+		if locked := retryAfter(t, l.Check(ctx, "d@example.com", "10.0.0.1")); !locked.Locked {
+			t.Errorf("Check() = %+v, want IP lockout", locked)
+		}
+		if err := l.Check(ctx, "d@example.com", "10.0.0.2"); err != nil {
+			t.Errorf("Other IPs should not be locked: %v", err)
+		}
+
+		// A successful login does not reset the IP
+		l.Success(ctx, "a@example.com")
+		if err := l.Check(ctx, "", "10.0.0.1"); err == nil {
+			t.Error("Success() should not clear the IP lockout")
+		}
+	})
+}
+
+func TestLimiter_Unlock(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		c := newClock()
+
+		var events []Event
+		l := c.limiter(Config{
+			MaxAccountFailures: 1,
+			Store:              store,
+			Audit:              func(ctx context.Context, e Event) { events = append(events, e) },
+		})
+
+		l.Failure(ctx, "alice@example.com", "")
+		if err := l.Check(ctx, "alice@example.com", ""); err == nil {
+			t.Fatal("Expected account to be locked")
+		}
+
+		if err := l.Unlock(ctx, AccountKey("alice@example.com"), "admin@example.com"); err != nil {
+			t.Fatalf("Unlock() error = %v", err)
+		}
+		if err := l.Check(ctx, "alice@example.com", ""); err != nil {
+			t.Errorf("Check() after Unlock() error = %v, want nil", err)
+		}
+		if len(events) != 2 || events[1].Type != EventUnlocked || events[1].Actor != "admin@example.com" {
+			t.Errorf("Unexpected audit events %+v", events)
+		}
+	})
+}
+
+func TestLimiter_ConcurrentFailures(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		l := New(Config{MaxAccountFailures: 1000, Store: store})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := l.Failure(ctx, "alice@example.com", ""); err != nil {
+					t.Errorf("Failure() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		r, _ := l.Status(ctx, AccountKey("alice@example.com"))
+		if r.Failures != 20 {
+			t.Errorf("Failures = %d, want 20", r.Failures)
+		}
+	})
+}
+
+func TestSQLStore_Purge(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+
+	now := time.Now()
+	store.AddFailure(ctx, "account:old", now.Add(-time.Hour), now.Add(-2*time.Hour))
+	store.AddFailure(ctx, "account:locked", now.Add(-time.Hour), now.Add(-2*time.Hour))
+	store.Lock(ctx, "account:locked", now.Add(time.Hour))
+	store.AddFailure(ctx, "account:recent", now, now.Add(-time.Hour))
+
+	if err := store.Purge(ctx, now.Add(-30*time.Minute)); err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+
+	for key, want := range map[string]bool{"account:old": false, "account:locked": true, "account:recent": true} {
+		r, _ := store.Get(ctx, key)
+		if got := r != (Record{}); got != want {
+			t.Errorf("%s kept = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Schema creates the table used by SQLStore. It works on both PostgreSQL
+// and SQLite.
+const Schema = `
+CREATE TABLE IF NOT EXISTS login_attempts (
+	subject VARCHAR(320) PRIMARY KEY,
+	failures INTEGER NOT NULL DEFAULT 0,
+	last_failure TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP NOT NULL
+)`
+
+// SQLStore is a Store shared by every instance using the same database
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore creates a SQLStore. The table must exist; see Schema and
+// CreateSchema.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// CreateSchema creates the store's table if it does not exist
+func (s *SQLStore) CreateSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, Schema); err != nil {
+		return fmt.Errorf("failed to create lockout schema: %w", err)
+	}
+	return nil
+}
+
+// Get implements Store
+func (s *SQLStore) Get(ctx context.Context, key string) (Record, error) {
+	var r Record
+	err := s.db.QueryRowContext(ctx,
+		"SELECT failures, last_failure, locked_until FROM login_attempts WHERE subject = $1", key,
+	).Scan(&r.Failures, &r.LastFailure, &r.LockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to load login attempts: %w", err)
+	}
+	return r, nil
+}
+
+// AddFailure implements Store. The upsert increments the counter in a single
+// statement, so failures on different instances are never lost.
+func (s *SQLStore) AddFailure(ctx context.Context, key string, now, windowStart time.Time) (Record, error) {
+	var r Record
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO login_attempts (subject, failures, last_failure, locked_until) VALUES ($1, 1, $2, $3)
+		ON CONFLICT (subject) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure < $4 THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure = excluded.last_failure
+		RETURNING failures, last_failure, locked_until`,
+		key, now.UTC(), time.Time{}.UTC(), windowStart.UTC(),
+	).Scan(&r.Failures, &r.LastFailure, &r.LockedUntil)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return r, nil
+}
+
+// Lock implements Store
+func (s *SQLStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO login_attempts (subject, failures, last_failure, locked_until) VALUES ($1, 0, $2, $2)
+		ON CONFLICT (subject) DO UPDATE SET failures = 0, locked_until = excluded.locked_until`,
+		key, until.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", key, err)
+	}
+	return nil
+}
+
+// Reset implements Store
+func (s *SQLStore) Reset(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE subject = $1", key); err != nil {
+		return fmt.Errorf("failed to reset %s: %w", key, err)
+	}
+	return nil
+}
+
+// Purge deletes records whose last failure is before windowStart and that
+// are not locked. Call it periodically to keep the table small.
+func (s *SQLStore) Purge(ctx context.Context, windowStart time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM login_attempts WHERE last_failure < $1 AND locked_until <= $2",
+		windowStart.UTC(), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to purge login attempts: %w", err)
+	}
+	return nil
+}
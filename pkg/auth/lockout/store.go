@@ -0,0 +1,91 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Record is the failed-login state of one account or IP address
+type Record struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure,omitzero"`
+	LockedUntil time.Time `json:"locked_until,omitzero"`
+}
+
+// Store keeps failed-login records by key. Implementations must make
+// AddFailure atomic so that concurrent failures are all counted.
+type Store interface {
+	// Get returns the record for key, or a zero Record if there is none
+	Get(ctx context.Context, key string) (Record, error)
+	// AddFailure records a failure at now and returns the updated record. The
+	// count starts over at 1 if the previous failure was before windowStart.
+	AddFailure(ctx context.Context, key string, now, windowStart time.Time) (Record, error)
+	// Lock locks key until until and clears its failure count
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset forgets key, lifting any lock
+	Reset(ctx context.Context, key string) error
+}
+
+// MemoryStore is a Store for a single process
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], nil
+}
+
+// AddFailure implements Store
+func (s *MemoryStore) AddFailure(ctx context.Context, key string, now, windowStart time.Time) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge(now, windowStart)
+	r := s.records[key]
+	if r.LastFailure.Before(windowStart) {
+		r.Failures = 0
+	}
+	r.Failures++
+	r.LastFailure = now
+	s.records[key] = r
+	return r, nil
+}
+
+// Lock implements Store
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.records[key]
+	r.Failures = 0
+	r.LockedUntil = until
+	s.records[key] = r
+	return nil
+}
+
+// Reset implements Store
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// purge drops records that no longer affect anything; callers must hold s.mu
+func (s *MemoryStore) purge(now, windowStart time.Time) {
+	for key, r := range s.records {
+		if r.LastFailure.Before(windowStart) && !r.LockedUntil.After(now) {
+			delete(s.records, key)
+		}
+	}
+}
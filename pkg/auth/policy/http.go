@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+)
+
+// ErrorResponse is the JSON body of 401 and 403 responses
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Code is a stable machine-readable reason
+	Code string `json:"code"`
+	// Required names the permission that was missing
+	Required string `json:"required,omitempty"`
+}
+
+// Error codes used in ErrorResponse
+const (
+	CodeUnauthenticated        = "unauthenticated"
+	CodeInsufficientPermission = "insufficient_permission"
+)
+
+// Forbidden returns the 403 body for a missing permission
+func Forbidden(permission string) ErrorResponse {
+	return ErrorResponse{
+		Error:    "forbidden",
+		Code:     CodeInsufficientPermission,
+		Required: permission,
+	}
+}
+
+// Unauthenticated returns the 401 body for a missing or invalid token
+func Unauthenticated() ErrorResponse {
+	return ErrorResponse{Error: "unauthorized", Code: CodeUnauthenticated}
+}
+
+// TokenValidator checks a bearer token and returns its claims
+type TokenValidator interface {
+	ValidateToken(token string) (*jwtservice.Claims, error)
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying claims
+func WithClaims(ctx context.Context, claims *jwtservice.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by Authenticate
+func ClaimsFromContext(ctx context.Context) (*jwtservice.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*jwtservice.Claims)
+	return claims, ok
+}
+
+// Authenticate validates the "Authorization: Bearer" token and stores its
+// claims in the request context. Its signature matches mux.MiddlewareFunc.
+func Authenticate(tokens TokenValidator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+				writeJSON(w, http.StatusUnauthorized, Unauthenticated())
+				return
+			}
+
+			claims, err := tokens.ValidateToken(strings.TrimSpace(token))
+			if err != nil {
+				writeJSON(w, http.StatusUnauthorized, Unauthenticated())
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// Require rejects requests whose claims lack permission with 403. It must
+// run after Authenticate; requests without claims get 401.
+func (p *Policy) Require(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				writeJSON(w, http.StatusUnauthorized, Unauthenticated())
+				return
+			}
+			if !p.Subject(claims).Can(permission) {
+				writeJSON(w, http.StatusForbidden, Forbidden(permission))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
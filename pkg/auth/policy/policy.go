@@ -0,0 +1,163 @@
+// Package policy decides what an authenticated user may do, based on the
+// roles and permissions carried in jwtservice.Claims.
+//
+// Permissions are "resource:action" strings such as "posts:write". A granted
+// permission may use "*" for the action ("posts:*") or on its own to allow
+// everything.
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+)
+
+// Policy maps roles to the permissions they grant
+type Policy struct {
+	roles map[string][]string
+}
+
+// New creates a Policy from a role -> permissions table
+func New(roles map[string][]string) *Policy {
+	p := &Policy{roles: make(map[string][]string, len(roles))}
+	for role, perms := range roles {
+		p.roles[role] = append([]string(nil), perms...)
+	}
+	return p
+}
+
+// Subject is an authenticated user with their effective permissions
+type Subject struct {
+	UserID      int
+	Roles       []string
+	Permissions []string
+}
+
+// Subject builds the subject for claims, expanding roles into permissions
+func (p *Policy) Subject(claims *jwtservice.Claims) *Subject {
+	s := &Subject{
+		UserID: claims.UserID,
+		Roles:  claims.Roles,
+	}
+
+	seen := make(map[string]bool)
+	add := func(perms []string) {
+		for _, perm := range perms {
+			if !seen[perm] {
+				seen[perm] = true
+				s.Permissions = append(s.Permissions, perm)
+			}
+		}
+	}
+	add(claims.Permissions)
+	for _, role := range claims.Roles {
+		add(p.roles[role])
+	}
+	return s
+}
+
+// HasRole reports whether the subject has role
+func (s *Subject) HasRole(role string) bool {
+	for _, r := range s.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the subject has been granted permission
+func (s *Subject) Can(permission string) bool {
+	for _, granted := range s.Permissions {
+		if matches(granted, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches checks a granted permission, which may contain wildcards, against
+// a required one
+func matches(granted, required string) bool {
+	if granted == "*" || granted == required {
+		return true
+	}
+	resource, action, ok := strings.Cut(granted, ":")
+	if !ok || action != "*" {
+		return false
+	}
+	reqResource, _, _ := strings.Cut(required, ":")
+	return resource == reqResource
+}
+
+// Rule decides whether a subject may act on a resource. The resource is nil
+// for rules that only depend on the subject.
+type Rule func(s *Subject, resource any) bool
+
+// Permission allows subjects granted permission
+func Permission(permission string) Rule {
+	return func(s *Subject, resource any) bool {
+		return s.Can(permission)
+	}
+}
+
+// Role allows subjects with role
+func Role(role string) Rule {
+	return func(s *Subject, resource any) bool {
+		return s.HasRole(role)
+	}
+}
+
+// Owner allows the subject whose user ID ownerID returns for the resource.
+// Resources of any other type are denied.
+func Owner[T any](ownerID func(T) int) Rule {
+	return func(s *Subject, resource any) bool {
+		r, ok := resource.(T)
+		return ok && s.UserID != 0 && ownerID(r) == s.UserID
+	}
+}
+
+// Any allows the request if at least one rule does, e.g.
+// Any(Owner(postAuthor), Role("editor"))
+func Any(rules ...Rule) Rule {
+	return func(s *Subject, resource any) bool {
+		for _, rule := range rules {
+			if rule(s, resource) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// All allows the request only if every rule does
+func All(rules ...Rule) Rule {
+	return func(s *Subject, resource any) bool {
+		for _, rule := range rules {
+			if !rule(s, resource) {
+				return false
+			}
+		}
+		return len(rules) > 0
+	}
+}
+
+// DeniedError is returned when a rule denies access
+type DeniedError struct {
+	// Action names what was attempted, typically the required permission
+	Action string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("permission denied: %s", e.Action)
+}
+
+// Authorize evaluates rule for the subject and resource and returns a
+// *DeniedError naming action when it does not allow access
+func Authorize(s *Subject, action string, resource any, rule Rule) error {
+	if s == nil || !rule(s, resource) {
+		return &DeniedError{Action: action}
+	}
+	return nil
+}
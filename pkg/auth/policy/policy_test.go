@@ -0,0 +1,166 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/jwtservice"
+)
+
+var testPolicy = New(map[string][]string{
+	"admin":  {"*"},
+	"editor": {"posts:*"},
+	"user":   {"posts:read", "comments:write"},
+})
+
+type post struct {
+	AuthorID int
+}
+
+func TestSubject_Can(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     *jwtservice.Claims
+		permission string
+		want       bool
+	}{
+		{"admin wildcard", &jwtservice.Claims{Roles: []string{"admin"}}, "users:delete", true},
+		{"resource wildcard", &jwtservice.Claims{Roles: []string{"editor"}}, "posts:delete", true},
+		{"resource wildcard other resource", &jwtservice.Claims{Roles: []string{"editor"}}, "users:read", false},
+		{"exact permission", &jwtservice.Claims{Roles: []string{"user"}}, "posts:read", true},
+		{"missing permission", &jwtservice.Claims{Roles: []string{"user"}}, "posts:write", false},
+		{"scoped permission in claims", &jwtservice.Claims{Permissions: []string{"reports:export"}}, "reports:export", true},
+		{"unknown role", &jwtservice.Claims{Roles: []string{"ghost"}}, "posts:read", false},
+		{"no roles", &jwtservice.Claims{}, "posts:read", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testPolicy.Subject(tt.claims).Can(tt.permission); got != tt.want {
+				t.Errorf("Can(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	ownerOrEditor := Any(Owner(func(p post) int { return p.AuthorID }), Role("editor"))
+
+	author := testPolicy.Subject(&jwtservice.Claims{UserID: 1, Roles: []string{"user"}})
+	other := testPolicy.Subject(&jwtservice.Claims{UserID: 2, Roles: []string{"user"}})
+	editor := testPolicy.Subject(&jwtservice.Claims{UserID: 3, Roles: []string{"editor"}})
+
+	tests := []struct {
+		name     string
+		rule     Rule
+		subject  *Subject
+		resource any
+		want     bool
+	}{
+		{"owner", ownerOrEditor, author, post{AuthorID: 1}, true},
+		{"not owner", ownerOrEditor, other, post{AuthorID: 1}, false},
+		{"editor", ownerOrEditor, editor, post{AuthorID: 1}, true},
+		{"owner of other resource type", Owner(func(p post) int { return p.AuthorID }), author, "post", false},
+		{"all", All(Permission("posts:read"), Role("user")), author, nil, true},
+		{"all missing one", All(Permission("posts:write"), Role("user")), author, nil, false},
+		{"all empty", All(), author, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule(tt.subject, tt.resource); got != tt.want {
+				t.Errorf("rule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	subject := testPolicy.Subject(&jwtservice.Claims{UserID: 2, Roles: []string{"user"}})
+
+	err := Authorize(subject, "posts:update", post{AuthorID: 1}, Owner(func(p post) int { return p.AuthorID }))
+	var denied *DeniedError
+	if !errors.As(err, &denied) || denied.Action != "posts:update" {
+		t.Errorf("Authorize() error = %v, want DeniedError for posts:update", err)
+	}
+
+	if err := Authorize(subject, "posts:read", nil, Permission("posts:read")); err != nil {
+		t.Errorf("Authorize() error = %v, want nil", err)
+	}
+	if err := Authorize(nil, "posts:read", nil, Permission("posts:read")); err == nil {
+		t.Error("Authorize(nil subject) should deny")
+	}
+}
+
+type fakeValidator map[string]*jwtservice.Claims
+
+func (f fakeValidator) ValidateToken(token string) (*jwtservice.Claims, error) {
+	if claims, ok := f[token]; ok {
+		return claims, nil
+	}
+	return nil, jwtservice.ErrInvalidToken
+}
+
+func TestRequire(t *testing.T) {
+	tokens := fakeValidator{
+		"admin": {UserID: 1, Roles: []string{"admin"}},
+		"user":  {UserID: 2, Roles: []string{"user"}},
+	}
+
+	mux := http.NewServeMux()
+	handler := Authenticate(tokens)(testPolicy.Require("users:read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := ClaimsFromContext(r.Context()); !ok {
+			t.Error("Claims should be available to the handler")
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+	mux.Handle("/users", handler)
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantBody   ErrorResponse
+	}{
+		{"allowed", "Bearer admin", http.StatusOK, ErrorResponse{}},
+		{"forbidden", "Bearer user", http.StatusForbidden, Forbidden("users:read")},
+		{"invalid token", "Bearer nope", http.StatusUnauthorized, Unauthenticated()},
+		{"missing token", "", http.StatusUnauthorized, Unauthenticated()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+
+			var body ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %+v, want %+v", body, tt.wantBody)
+			}
+		})
+	}
+
+	// Require without Authenticate has no claims to check
+	rr := httptest.NewRecorder()
+	testPolicy.Require("users:read")(http.NotFoundHandler()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("status without claims = %d, want 401", rr.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package security
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// hashPrefixLength is the number of hex characters of the SHA-1 used as the
+// range key, as in the Have I Been Pwned k-anonymity API
+const hashPrefixLength = 5
+
+// BreachedPasswords is an offline breach corpus of SHA-1 password hashes,
+// grouped by their 5 character prefix so that a lookup only ever touches the
+// suffixes in one range
+type BreachedPasswords struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedPasswords reads a breach corpus file; see ParseBreachedPasswords
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached passwords file: %w", err)
+	}
+	defer f.Close()
+
+	b, err := ParseBreachedPasswords(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// ParseBreachedPasswords reads one uppercase or lowercase SHA-1 hex digest
+// per line, optionally followed by ":count" as in the Pwned Passwords
+// downloads. Blank lines and lines starting with # are skipped.
+func ParseBreachedPasswords(r io.Reader) (*BreachedPasswords, error) {
+	b := &BreachedPasswords{ranges: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		digest, _, _ := strings.Cut(text, ":")
+		digest = strings.ToUpper(digest)
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha1.Size*2 {
+			return nil, fmt.Errorf("line %d: invalid SHA-1 hash %q", line, digest)
+		}
+
+		prefix := digest[:hashPrefixLength]
+		b.ranges[prefix] = append(b.ranges[prefix], digest[hashPrefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, suffixes := range b.ranges {
+		sort.Strings(suffixes)
+	}
+	return b, nil
+}
+
+// Range returns the hash suffixes stored under a 5 character prefix
+func (b *BreachedPasswords) Range(prefix string) []string {
+	return b.ranges[strings.ToUpper(prefix)]
+}
+
+// Contains implements BreachChecker
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := b.Range(digest[:hashPrefixLength])
+	suffix := digest[hashPrefixLength:]
+	i := sort.SearchStrings(suffixes, suffix)
+	return i < len(suffixes) && suffixes[i] == suffix
+}
@@ -0,0 +1,245 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashing algorithms
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// ErrUnknownHashFormat is returned for hashes no registered Hasher produced
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Hasher hashes and verifies passwords with one algorithm. Hashes are
+// self-describing strings that record the algorithm and its parameters, so
+// they can be verified after the configured cost changes.
+type Hasher interface {
+	// Algorithm names the algorithm, e.g. "argon2id"
+	Algorithm() string
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash. It returns
+	// ErrUnknownHashFormat if hash was not produced by this algorithm.
+	Verify(password, hash string) (bool, error)
+	// NeedsRehash reports whether hash was produced with parameters other
+	// than the hasher's current ones
+	NeedsRehash(hash string) bool
+}
+
+// BcryptHasher hashes passwords with bcrypt. Its hashes use bcrypt's own
+// "$2a$<cost>$..." format, which already records the algorithm and cost.
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher creates a BcryptHasher. A zero cost uses bcrypt.DefaultCost.
+func NewBcryptHasher(cost int) (*BcryptHasher, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+	}
+	return &BcryptHasher{Cost: cost}, nil
+}
+
+// Algorithm implements Hasher
+func (h *BcryptHasher) Algorithm() string {
+	return AlgorithmBcrypt
+}
+
+// Hash implements Hasher
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify implements Hasher
+func (h *BcryptHasher) Verify(password, hash string) (bool, error) {
+	if !isBcryptHash(hash) {
+		return false, ErrUnknownHashFormat
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NeedsRehash implements Hasher
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+func isBcryptHash(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Argon2Params are the argon2id cost parameters
+type Argon2Params struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for argon2id
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2Hasher hashes passwords with argon2id. Hashes use the PHC string
+// format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>.
+type Argon2Hasher struct {
+	Params Argon2Params
+}
+
+// NewArgon2Hasher creates an Argon2Hasher. Zero fields of params take their
+// value from DefaultArgon2Params.
+func NewArgon2Hasher(params Argon2Params) (*Argon2Hasher, error) {
+	if params.Memory == 0 {
+		params.Memory = DefaultArgon2Params.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = DefaultArgon2Params.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = DefaultArgon2Params.Parallelism
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = DefaultArgon2Params.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = DefaultArgon2Params.KeyLength
+	}
+	if params.Memory < 8*uint32(params.Parallelism) {
+		return nil, fmt.Errorf("argon2 memory must be at least 8 KiB per thread, got %d", params.Memory)
+	}
+	return &Argon2Hasher{Params: params}, nil
+}
+
+// Algorithm implements Hasher
+func (h *Argon2Hasher) Algorithm() string {
+	return AlgorithmArgon2id
+}
+
+// Hash implements Hasher
+func (h *Argon2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := h.Params
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgorithmArgon2id, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify implements Hasher
+func (h *Argon2Hasher) Verify(password, hash string) (bool, error) {
+	params, salt, key, err := decodeArgon2(hash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// NeedsRehash implements Hasher
+func (h *Argon2Hasher) NeedsRehash(hash string) bool {
+	params, salt, _, err := decodeArgon2(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.Params.Memory ||
+		params.Iterations != h.Params.Iterations ||
+		params.Parallelism != h.Params.Parallelism ||
+		params.KeyLength != h.Params.KeyLength ||
+		uint32(len(salt)) != h.Params.SaltLength
+}
+
+// decodeArgon2 parses a PHC argon2id string
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	for _, field := range strings.Split(parts[3], ",") {
+		name, value, _ := strings.Cut(field, "=")
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return params, nil, nil, fmt.Errorf("invalid argon2 parameter %q", field)
+		}
+		switch name {
+		case "m":
+			params.Memory = uint32(n)
+		case "t":
+			params.Iterations = uint32(n)
+		case "p":
+			if n > 255 {
+				return params, nil, nil, fmt.Errorf("invalid argon2 parameter %q", field)
+			}
+			params.Parallelism = uint8(n)
+		default:
+			return params, nil, nil, fmt.Errorf("unknown argon2 parameter %q", field)
+		}
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, errors.New("missing argon2 parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2 hash")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
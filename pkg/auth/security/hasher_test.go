@@ -0,0 +1,153 @@
+package security
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fastArgon2 keeps the tests quick; production uses DefaultArgon2Params
+var fastArgon2 = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestHashers(t *testing.T) {
+	bcryptHasher, err := NewBcryptHasher(4)
+	if err != nil {
+		t.Fatalf("NewBcryptHasher() error = %v", err)
+	}
+	argon2Hasher, err := NewArgon2Hasher(fastArgon2)
+	if err != nil {
+		t.Fatalf("NewArgon2Hasher() error = %v", err)
+	}
+
+	tests := []struct {
+		hasher Hasher
+		prefix string
+	}{
+		{bcryptHasher, "$2a$04$"},
+		{argon2Hasher, "$argon2id$v=19$m=1024,t=1,p=1$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hasher.Algorithm(), func(t *testing.T) {
+			hash, err := tt.hasher.Hash("password123")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Errorf("Hash() = %q, want prefix %q", hash, tt.prefix)
+			}
+
+			if ok, err := tt.hasher.Verify("password123", hash); !ok || err != nil {
+				t.Errorf("Verify(correct) = %v, %v, want true, nil", ok, err)
+			}
+			if ok, err := tt.hasher.Verify("wrong", hash); ok || err != nil {
+				t.Errorf("Verify(wrong) = %v, %v, want false, nil", ok, err)
+			}
+			if _, err := tt.hasher.Verify("password123", "plain"); err != ErrUnknownHashFormat {
+				t.Errorf("Verify(unknown format) error = %v, want %v", err, ErrUnknownHashFormat)
+			}
+			if tt.hasher.NeedsRehash(hash) {
+				t.Error("NeedsRehash() should be false for a hash with current parameters")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	oldBcrypt, _ := NewPasswordServiceWithConfig(HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: 4})
+	newBcrypt, _ := NewPasswordServiceWithConfig(HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: 5})
+	argon2Service, _ := NewPasswordServiceWithConfig(HashConfig{Algorithm: AlgorithmArgon2id, Argon2: fastArgon2})
+	strongerArgon2, _ := NewPasswordServiceWithConfig(HashConfig{
+		Algorithm: AlgorithmArgon2id,
+		Argon2:    Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1},
+	})
+
+	bcryptHash, _ := oldBcrypt.HashPassword("password123")
+	argon2Hash, _ := argon2Service.HashPassword("password123")
+
+	tests := []struct {
+		name    string
+		service *PasswordService
+		hash    string
+		want    bool
+	}{
+		{"same bcrypt cost", oldBcrypt, bcryptHash, false},
+		{"higher bcrypt cost", newBcrypt, bcryptHash, true},
+		{"bcrypt to argon2id", argon2Service, bcryptHash, true},
+		{"same argon2 params", argon2Service, argon2Hash, false},
+		{"more argon2 memory", strongerArgon2, argon2Hash, true},
+		{"argon2id to bcrypt", oldBcrypt, argon2Hash, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.service.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+			// Old hashes must keep verifying whatever the configuration
+			if !tt.service.VerifyPassword("password123", tt.hash) {
+				t.Error("VerifyPassword() should accept hashes from any supported algorithm")
+			}
+		})
+	}
+}
+
+func TestNewPasswordServiceWithConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     HashConfig
+		wantErr bool
+	}{
+		{"defaults", HashConfig{}, false},
+		{"argon2id", HashConfig{Algorithm: AlgorithmArgon2id}, false},
+		{"unknown algorithm", HashConfig{Algorithm: "md5"}, true},
+		{"bcrypt cost too high", HashConfig{BcryptCost: 40}, true},
+		{"argon2 memory too low", HashConfig{Argon2: Argon2Params{Memory: 4, Parallelism: 1}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPasswordServiceWithConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPasswordServiceWithConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeArgon2Rejects(t *testing.T) {
+	for _, hash := range []string{
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+	} {
+		if _, _, _, err := decodeArgon2(hash); err == nil {
+			t.Errorf("decodeArgon2(%q) should fail", hash)
+		}
+	}
+}
+
+func TestDummyVerify(t *testing.T) {
+	service := NewPasswordService()
+	hash, _ := service.HashPassword("password123")
+
+	// Warm up the lazily created dummy hash
+	service.DummyVerify("password123")
+
+	measure := func(f func()) time.Duration {
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			f()
+		}
+		return time.Since(start)
+	}
+	real := measure(func() { service.VerifyPassword("wrong", hash) })
+	dummy := measure(func() { service.DummyVerify("wrong") })
+
+	// Both run a full bcrypt comparison; allow generous scheduling noise
+	if dummy < real/4 {
+		t.Errorf("DummyVerify() took %v, expected comparable to VerifyPassword() %v", dummy, real)
+	}
+}
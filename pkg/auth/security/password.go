@@ -0,0 +1,128 @@
+package security
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// bcryptCost is the work factor used by NewPasswordService
+const bcryptCost = 10
+
+// HashConfig selects the algorithm and cost of new password hashes
+type HashConfig struct {
+	// Algorithm is AlgorithmBcrypt or AlgorithmArgon2id, defaulting to bcrypt
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// PasswordService handles password operations. New hashes use the configured
+// Hasher; hashes from any supported algorithm can still be verified.
+type PasswordService struct {
+	hasher  Hasher
+	hashers []Hasher
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// NewPasswordService creates a new password service
+func NewPasswordService() *PasswordService {
+	bcryptHasher := &BcryptHasher{Cost: bcryptCost}
+	return &PasswordService{
+		hasher:  bcryptHasher,
+		hashers: []Hasher{bcryptHasher, &Argon2Hasher{Params: DefaultArgon2Params}},
+	}
+}
+
+// NewPasswordServiceWithConfig creates a password service that hashes with
+// the algorithm and cost in cfg
+func NewPasswordServiceWithConfig(cfg HashConfig) (*PasswordService, error) {
+	bcryptHasher, err := NewBcryptHasher(cfg.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	argon2Hasher, err := NewArgon2Hasher(cfg.Argon2)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PasswordService{hashers: []Hasher{bcryptHasher, argon2Hasher}}
+	switch cfg.Algorithm {
+	case "", AlgorithmBcrypt:
+		p.hasher = bcryptHasher
+	case AlgorithmArgon2id:
+		p.hasher = argon2Hasher
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+	return p, nil
+}
+
+// Algorithm names the algorithm used for new hashes
+func (p *PasswordService) Algorithm() string {
+	return p.hasher.Algorithm()
+}
+
+// HashPassword hashes a password using bcrypt
+// Requirements:
+// - password must not be empty
+// - use bcrypt with cost 10
+// - return the hashed password as string
+//
+// Services built with NewPasswordServiceWithConfig use the configured
+// algorithm instead.
+func (p *PasswordService) HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password cannot be empty")
+	}
+	return p.hasher.Hash(password)
+}
+
+// VerifyPassword checks if password matches hash
+// Requirements:
+// - password and hash must not be empty
+// - return true if password matches hash
+// - return false if password doesn't match
+func (p *PasswordService) VerifyPassword(password, hash string) bool {
+	if password == "" || hash == "" {
+		return false
+	}
+	for _, h := range p.hashers {
+		ok, err := h.Verify(password, hash)
+		if errors.Is(err, ErrUnknownHashFormat) {
+			continue
+		}
+		return err == nil && ok
+	}
+	return false
+}
+
+// NeedsRehash reports whether hash should be replaced by a fresh one because
+// it uses a different algorithm or cost than the service is configured with.
+// Call it after a successful VerifyPassword, while the plain password is at hand.
+func (p *PasswordService) NeedsRehash(hash string) bool {
+	return p.hasher.NeedsRehash(hash)
+}
+
+// DummyVerify spends as long as VerifyPassword against a real hash and
+// always fails. Call it when the user does not exist so that response
+// timing does not reveal which accounts are registered.
+func (p *PasswordService) DummyVerify(password string) {
+	p.dummyOnce.Do(func() {
+		p.dummyHash, _ = p.hasher.Hash(rand.Text())
+	})
+	p.hasher.Verify(password, p.dummyHash)
+}
+
+// ValidatePassword checks if password meets basic requirements
+// Requirements:
+// - At least 6 characters
+// - Contains at least one letter and one number
+//
+// It applies DefaultPasswordPolicy; failures are ValidationErrors.
+func ValidatePassword(password string) error {
+	return DefaultPasswordPolicy.Validate(password)
+}
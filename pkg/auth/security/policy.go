@@ -0,0 +1,168 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules reported in ValidationError.Rule
+const (
+	RuleMinLength       = "min_length"
+	RuleMaxLength       = "max_length"
+	RuleCharacterClass  = "character_class"
+	RuleBannedSubstring = "banned_substring"
+	RuleBreached        = "breached"
+)
+
+// CharClass is a class of characters a password may be required to contain
+type CharClass string
+
+// Character classes
+const (
+	Letter    CharClass = "letter"
+	Lowercase CharClass = "lowercase letter"
+	Uppercase CharClass = "uppercase letter"
+	Digit     CharClass = "number"
+	Symbol    CharClass = "symbol"
+)
+
+func (c CharClass) matches(r rune) bool {
+	switch c {
+	case Letter:
+		return unicode.IsLetter(r)
+	case Lowercase:
+		return unicode.IsLower(r)
+	case Uppercase:
+		return unicode.IsUpper(r)
+	case Digit:
+		return unicode.IsDigit(r)
+	case Symbol:
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+	default:
+		return false
+	}
+}
+
+// ValidationError is a single password rule that was violated
+type ValidationError struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors lists every rule a password violated
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// BreachChecker reports whether a password appears in a breach corpus
+type BreachChecker interface {
+	Contains(password string) bool
+}
+
+// PasswordPolicy describes the rules a password must satisfy. The zero
+// value accepts any password.
+type PasswordPolicy struct {
+	// MinLength and MaxLength count characters, not bytes. A zero MaxLength
+	// means no limit.
+	MinLength int
+	MaxLength int
+	// Require lists classes that must each appear at least once
+	Require []CharClass
+	// BannedSubstrings are rejected anywhere in the password, ignoring case
+	BannedSubstrings []string
+	// Breached, when set, rejects passwords found in a breach corpus
+	Breached BreachChecker
+}
+
+// minPersonalLength is the shortest part of an email or name that is banned;
+// shorter fragments would reject too many unrelated passwords
+const minPersonalLength = 3
+
+// DefaultPasswordPolicy is the basic policy behind ValidatePassword
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength: 6,
+	Require:   []CharClass{Letter, Digit},
+}
+
+// UserPasswordPolicy is the policy for user account passwords. MaxLength
+// stays within the 72 bytes bcrypt can hash for ASCII passwords.
+var UserPasswordPolicy = PasswordPolicy{
+	MinLength: 8,
+	MaxLength: 72,
+	Require:   []CharClass{Uppercase, Lowercase, Digit},
+}
+
+// Validate checks password against every rule and returns all violations
+// as ValidationErrors, or nil. personal values such as the user's email and
+// name are split into words and banned like BannedSubstrings.
+func (p PasswordPolicy) Validate(password string, personal ...string) error {
+	var errs ValidationErrors
+	add := func(rule, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(RuleMinLength, "password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(RuleMaxLength, "password must be at most %d characters long", p.MaxLength)
+	}
+
+	for _, class := range p.Require {
+		if !strings.ContainsFunc(password, class.matches) {
+			add(RuleCharacterClass, "password must contain at least one %s", class)
+		}
+	}
+
+	lower := strings.ToLower(password)
+	for _, banned := range p.BannedSubstrings {
+		if banned != "" && strings.Contains(lower, strings.ToLower(banned)) {
+			add(RuleBannedSubstring, "password must not contain %q", banned)
+		}
+	}
+	for _, word := range personalWords(personal) {
+		if strings.Contains(lower, word) {
+			add(RuleBannedSubstring, "password must not contain your name or email")
+			break
+		}
+	}
+
+	if p.Breached != nil && password != "" && p.Breached.Contains(password) {
+		add(RuleBreached, "password has appeared in a data breach and must not be used")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// personalWords splits emails and names into lowercase words long enough to
+// be worth banning, e.g. "john.doe@example.com" gives "john" and "doe"
+func personalWords(values []string) []string {
+	var words []string
+	for _, value := range values {
+		local, _, _ := strings.Cut(strings.ToLower(value), "@")
+		for _, word := range strings.FieldsFunc(local, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if utf8.RuneCountInString(word) >= minPersonalLength {
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
@@ -0,0 +1,116 @@
+package security
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func rules(err error) []string {
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	var out []string
+	for _, e := range errs {
+		out = append(out, e.Rule)
+	}
+	return out
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        16,
+		Require:          []CharClass{Uppercase, Lowercase, Digit, Symbol},
+		BannedSubstrings: []string{"password"},
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		want     []string
+	}{
+		{"valid", "Str0ng!Key", nil, nil},
+		{"every rule at once", "pass", nil, []string{RuleMinLength, RuleCharacterClass, RuleCharacterClass, RuleCharacterClass}},
+		{"too long", "Str0ng!Key-Str0ng!Key", nil, []string{RuleMaxLength}},
+		{"length counts characters", "Пароль1!Aa", nil, nil},
+		{"banned substring ignores case", "MyPassWord1!", nil, []string{RuleBannedSubstring}},
+		{"contains email local part", "Alice.99!x", []string{"alice.smith@example.com"}, []string{RuleBannedSubstring}},
+		{"contains name", "xSmith99!", []string{"Alice Smith"}, []string{RuleBannedSubstring}},
+		{"short name fragments are allowed", "Al!ce2024X", []string{"Al Li"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.personal...)
+			if got := rules(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() rules = %v, want %v (error: %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidationErrors_Error(t *testing.T) {
+	err := UserPasswordPolicy.Validate("short")
+	msg := err.Error()
+	for _, want := range []string{"at least 8 characters", "uppercase letter", "number"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Error() = %q, want it to mention %q", msg, want)
+		}
+	}
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	corpus := "# sample\n" +
+		sha1Hex("Password123") + ":52579\n" +
+		strings.ToLower(sha1Hex("Qwerty123")) + "\n\n"
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(corpus), 0o600); err != nil {
+		t.Fatalf("Failed to write corpus: %v", err)
+	}
+	breached, err := LoadBreachedPasswords(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords() error = %v", err)
+	}
+
+	for password, want := range map[string]bool{
+		"Password123": true,
+		"Qwerty123":   true,
+		"Str0ng!Key":  false,
+	} {
+		if got := breached.Contains(password); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", password, got, want)
+		}
+	}
+
+	digest := sha1Hex("Password123")
+	if r := breached.Range(strings.ToLower(digest[:5])); len(r) != 1 || r[0] != digest[5:] {
+		t.Errorf("Range() = %v, want [%s]", r, digest[5:])
+	}
+
+	policy := UserPasswordPolicy
+	policy.Breached = breached
+	if got := rules(policy.Validate("Password123")); !reflect.DeepEqual(got, []string{RuleBreached}) {
+		t.Errorf("Validate(breached) rules = %v, want [%s]", got, RuleBreached)
+	}
+
+	if _, err := ParseBreachedPasswords(strings.NewReader("not-a-hash\n")); err == nil {
+		t.Error("ParseBreachedPasswords() should reject invalid lines")
+	}
+	if _, err := LoadBreachedPasswords(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadBreachedPasswords() should fail for a missing file")
+	}
+}
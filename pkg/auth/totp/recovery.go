@@ -0,0 +1,45 @@
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// DefaultRecoveryCodes is how many recovery codes a user gets
+const DefaultRecoveryCodes = 10
+
+// recoveryCodeGroups of four base32 characters give 80 random bits per
+// code, enough that a fast hash cannot be reversed by brute force
+const recoveryCodeGroups = 4
+
+// GenerateRecoveryCodes returns n random single-use codes formatted as
+// xxxx-xxxx-xxxx-xxxx. Show them to the user once and store only their
+// HashRecoveryCode.
+func GenerateRecoveryCodes(n int) []string {
+	codes := make([]string, n)
+	for i := range codes {
+		text := strings.ToLower(rand.Text())
+		groups := make([]string, recoveryCodeGroups)
+		for g := range groups {
+			groups[g] = text[g*4 : g*4+4]
+		}
+		codes[i] = strings.Join(groups, "-")
+	}
+	return codes
+}
+
+// HashRecoveryCode returns the value to store for a recovery code. Case,
+// dashes and spaces are ignored.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// LooksLikeRecoveryCode tells recovery codes apart from TOTP codes
+func LooksLikeRecoveryCode(code string) bool {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(code)
+	return len(normalized) == recoveryCodeGroups*4
+}
@@ -0,0 +1,165 @@
+// Package totp implements time-based one-time passwords (RFC 6238) as used
+// by authenticator apps, and recovery codes for users who lose their device.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for zero Config fields. They match what authenticator apps
+// assume when an otpauth URI does not say otherwise.
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30 * time.Second
+	DefaultSkew   = 1
+)
+
+// secretSize is the length of generated secrets in bytes, the size of an
+// HMAC-SHA1 key recommended by RFC 4226
+const secretSize = 20
+
+// ErrInvalidSecret is returned for secrets that are not valid base32
+var ErrInvalidSecret = errors.New("invalid totp secret")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Config configures a TOTP. Zero fields use the defaults above.
+type Config struct {
+	// Issuer names the service in authenticator apps
+	Issuer string
+	Digits int
+	Period time.Duration
+	// Skew is how many periods before and after the current one are
+	// accepted, to tolerate clock drift and slow typing. A negative value
+	// accepts the current period only.
+	Skew int
+}
+
+// TOTP generates and verifies codes for base32 encoded secrets
+type TOTP struct {
+	cfg Config
+}
+
+// New creates a TOTP
+func New(cfg Config) *TOTP {
+	if cfg.Digits <= 0 {
+		cfg.Digits = DefaultDigits
+	}
+	if cfg.Period <= 0 {
+		cfg.Period = DefaultPeriod
+	}
+	if cfg.Skew < 0 {
+		cfg.Skew = 0
+	} else if cfg.Skew == 0 {
+		cfg.Skew = DefaultSkew
+	}
+	return &TOTP{cfg: cfg}
+}
+
+// GenerateSecret returns a new random base32 secret
+func GenerateSecret() string {
+	secret := make([]byte, secretSize)
+	rand.Read(secret)
+	return encoding.EncodeToString(secret)
+}
+
+// URI returns the otpauth URI that authenticator apps import, usually
+// rendered as a QR code
+func (t *TOTP) URI(account, secret string) string {
+	label := account
+	if t.cfg.Issuer != "" {
+		label = t.cfg.Issuer + ":" + account
+	}
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	if t.cfg.Issuer != "" {
+		params.Set("issuer", t.cfg.Issuer)
+	}
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", strconv.Itoa(t.cfg.Digits))
+	params.Set("period", strconv.Itoa(int(t.cfg.Period.Seconds())))
+
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: params.Encode()}
+	return u.String()
+}
+
+// Step returns the time step that at falls into
+func (t *TOTP) Step(at time.Time) int64 {
+	return at.Unix() / int64(t.cfg.Period.Seconds())
+}
+
+// Code returns the code for secret at the given time
+func (t *TOTP) Code(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(t.Step(at)), t.cfg.Digits), nil
+}
+
+// Verify checks code against secret at the given time, accepting the
+// configured skew. It returns the matched time step, which callers should
+// store and require to increase so that a code cannot be replayed.
+func (t *TOTP) Verify(secret, code string, at time.Time) (int64, bool, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != t.cfg.Digits {
+		return 0, false, nil
+	}
+
+	current := t.Step(at)
+	var matched int64
+	ok := false
+	// Check every candidate so the time taken does not reveal which matched
+	for step := current - int64(t.cfg.Skew); step <= current+int64(t.cfg.Skew); step++ {
+		candidate := hotp(key, uint64(step), t.cfg.Digits)
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(code)) == 1 && !ok {
+			matched, ok = step, true
+		}
+	}
+	return matched, ok, nil
+}
+
+// hotp computes an RFC 4226 HMAC-based one-time password
+func hotp(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// decodeSecret accepts secrets with or without padding, spaces and in any
+// case, as users tend to type them
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := encoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
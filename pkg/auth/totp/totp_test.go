@@ -0,0 +1,152 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	totp := New(Config{Digits: 8})
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := totp.Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	totp := New(Config{})
+	secret := rfcSecret
+	now := time.Unix(1700000000, 0)
+
+	code, _ := totp.Code(secret, now)
+	previous, _ := totp.Code(secret, now.Add(-30*time.Second))
+	tooOld, _ := totp.Code(secret, now.Add(-90*time.Second))
+
+	tests := []struct {
+		name     string
+		code     string
+		wantOK   bool
+		wantStep int64
+	}{
+		{"current", code, true, totp.Step(now)},
+		{"with spaces", code[:3] + " " + code[3:], true, totp.Step(now)},
+		{"previous step within skew", previous, true, totp.Step(now) - 1},
+		{"outside skew", tooOld, false, 0},
+		{"wrong length", "12345", false, 0},
+		{"empty", "", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok, err := totp.Verify(secret, tt.code, now)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Verify() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+
+	if _, _, err := totp.Verify("not base32!", code, now); err != ErrInvalidSecret {
+		t.Errorf("Verify() error = %v, want %v", err, ErrInvalidSecret)
+	}
+}
+
+func TestVerify_NoSkew(t *testing.T) {
+	totp := New(Config{Skew: -1})
+	now := time.Unix(1700000000, 0)
+	previous, _ := totp.Code(rfcSecret, now.Add(-30*time.Second))
+
+	if _, ok, _ := totp.Verify(rfcSecret, previous, now); ok {
+		t.Error("Verify() accepted the previous step without skew")
+	}
+}
+
+func TestURI(t *testing.T) {
+	totp := New(Config{Issuer: "Course App"})
+	uri, err := url.Parse(totp.URI("alice@example.com", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("URI() is not a valid URL: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("URI() = %s, want otpauth://totp/...", uri)
+	}
+	if uri.Path != "/Course App:alice@example.com" {
+		t.Errorf("label = %q, want issuer:account", uri.Path)
+	}
+	query := uri.Query()
+	for key, want := range map[string]string{"secret": "JBSWY3DPEHPK3PXP", "issuer": "Course App", "digits": "6", "period": "30"} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret := GenerateSecret()
+	if len(secret) != 32 {
+		t.Errorf("GenerateSecret() length = %d, want 32", len(secret))
+	}
+	if _, err := decodeSecret(strings.ToLower(secret)); err != nil {
+		t.Errorf("lowercase secret should decode: %v", err)
+	}
+	if GenerateSecret() == secret {
+		t.Error("GenerateSecret() returned the same secret twice")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := GenerateRecoveryCodes(DefaultRecoveryCodes)
+	if len(codes) != DefaultRecoveryCodes {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes, want %d", len(codes), DefaultRecoveryCodes)
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 19 || strings.Count(code, "-") != 3 {
+			t.Errorf("code %q is not formatted as xxxx-xxxx-xxxx-xxxx", code)
+		}
+		if !LooksLikeRecoveryCode(code) {
+			t.Errorf("LooksLikeRecoveryCode(%q) = false", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	code := codes[0]
+	typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+	if HashRecoveryCode(typed) != HashRecoveryCode(code) {
+		t.Error("HashRecoveryCode() should ignore case, dashes and spaces")
+	}
+	if LooksLikeRecoveryCode("123456") {
+		t.Error("a TOTP code should not look like a recovery code")
+	}
+}
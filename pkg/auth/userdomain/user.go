@@ -0,0 +1,114 @@
+package userdomain
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/pkg/auth/security"
+)
+
+// User represents a user entity in the domain
+type User struct {
+	ID            int       `json:"id"`
+	Email         string    `json:"email"`
+	Name          string    `json:"name"`
+	Password      string    `json:"-"` // Never serialize password
+	Roles         []string  `json:"roles,omitempty"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Validation limits for user fields
+const (
+	minNameLength = 2
+	maxNameLength = 50
+)
+
+var emailPattern = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+
+// NewUser creates a new user with validation
+// Requirements:
+// - Email must be valid format
+// - Name must be 2-51 characters
+// - Password must be at least 8 characters
+// - CreatedAt and UpdatedAt should be set to current time
+func NewUser(email, name, password string) (*User, error) {
+	now := time.Now()
+	user := &User{
+		Email:     email,
+		Name:      name,
+		Password:  password,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Validate checks if the user data is valid
+func (u *User) Validate() error {
+	if err := ValidateEmail(u.Email); err != nil {
+		return err
+	}
+	if err := ValidateName(u.Name); err != nil {
+		return err
+	}
+	// Passwords may not contain the user's own email or name
+	return security.UserPasswordPolicy.Validate(u.Password, u.Email, u.Name)
+}
+
+// ValidateEmail checks if email format is valid
+func ValidateEmail(email string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return errors.New("email cannot be empty")
+	}
+	if !emailPattern.MatchString(email) {
+		return errors.New("invalid email format")
+	}
+	return nil
+}
+
+// ValidateName checks if name is valid
+func ValidateName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if n := len([]rune(name)); n < minNameLength || n > maxNameLength {
+		return errors.New("name must be between 2 and 50 characters")
+	}
+	return nil
+}
+
+// ValidatePassword checks if password meets security requirements. It
+// applies security.UserPasswordPolicy and reports every violated rule as
+// security.ValidationErrors.
+func ValidatePassword(password string) error {
+	return security.UserPasswordPolicy.Validate(password)
+}
+
+// UpdateName updates the user's name with validation
+func (u *User) UpdateName(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	u.Name = strings.TrimSpace(name)
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateEmail updates the user's email with validation
+func (u *User) UpdateEmail(email string) error {
+	if err := ValidateEmail(email); err != nil {
+		return err
+	}
+	u.Email = strings.ToLower(strings.TrimSpace(email))
+	u.UpdatedAt = time.Now()
+	return nil
+}
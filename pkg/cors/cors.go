@@ -0,0 +1,318 @@
+// Package cors implements a Cross-Origin Resource Sharing policy for net/http
+// handlers. It is shared by the main backend (through a gin adapter) and the
+// gorilla/mux based lab services, so it depends on nothing but the standard
+// library.
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default values used when the corresponding option is empty
+var (
+	DefaultMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions}
+	DefaultHeaders = []string{"Content-Type", "Authorization", "Accept", "Origin", "X-Requested-With"}
+)
+
+// ErrWildcardCredentials is returned by New for a policy that allows every
+// origin and credentials at once. Browsers refuse "*" with credentials, so
+// such a policy could only work by echoing any origin, which lets every site
+// make authenticated requests.
+var ErrWildcardCredentials = errors.New("cors: allowing every origin with credentials is not permitted")
+
+// Route overrides the allowed methods and headers for paths under PathPrefix
+type Route struct {
+	PathPrefix string
+	Methods    []string
+	Headers    []string
+}
+
+// Options configures a Policy
+type Options struct {
+	// AllowedOrigins holds exact origins ("https://app.example.com"), wildcard
+	// subdomain patterns ("https://*.example.com") or "*" for any origin
+	AllowedOrigins []string
+	// AllowedMethods defaults to DefaultMethods
+	AllowedMethods []string
+	// AllowedHeaders defaults to DefaultHeaders
+	AllowedHeaders []string
+	// ExposedHeaders lists response headers readable by the browser
+	ExposedHeaders []string
+	// AllowCredentials sets Access-Control-Allow-Credentials: true
+	AllowCredentials bool
+	// MaxAge controls how long browsers may cache preflight results
+	MaxAge time.Duration
+	// Strict rejects disallowed preflight requests with 403 instead of
+	// answering them without CORS headers
+	Strict bool
+	// Routes holds per-path method and header overrides
+	Routes []Route
+}
+
+// Policy decides which cross-origin requests are allowed and writes the
+// matching CORS response headers
+type Policy struct {
+	anyOrigin   bool
+	origins     map[string]struct{}
+	wildcards   []wildcard
+	methods     []string
+	headers     []string
+	exposed     string
+	credentials bool
+	maxAge      string
+	strict      bool
+	routes      []route
+}
+
+// wildcard matches origins like https://*.example.com
+type wildcard struct {
+	scheme string
+	suffix string // ".example.com" or ".example.com:8443"
+}
+
+type route struct {
+	prefix  string
+	methods []string
+	headers []string
+}
+
+// New creates a Policy from opts. It fails with ErrWildcardCredentials if
+// AllowedOrigins contains "*" and AllowCredentials is set.
+func New(opts Options) (*Policy, error) {
+	p := &Policy{
+		origins:     make(map[string]struct{}),
+		methods:     normalizeMethods(opts.AllowedMethods, DefaultMethods),
+		headers:     normalizeHeaders(opts.AllowedHeaders, DefaultHeaders),
+		exposed:     strings.Join(opts.ExposedHeaders, ", "),
+		credentials: opts.AllowCredentials,
+		strict:      opts.Strict,
+	}
+
+	if opts.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+
+	for _, origin := range opts.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "":
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://*")
+			p.wildcards = append(p.wildcards, wildcard{scheme: scheme, suffix: host})
+		default:
+			p.origins[origin] = struct{}{}
+		}
+	}
+
+	for _, r := range opts.Routes {
+		p.routes = append(p.routes, route{
+			prefix:  r.PathPrefix,
+			methods: normalizeMethods(r.Methods, p.methods),
+			headers: normalizeHeaders(r.Headers, p.headers),
+		})
+	}
+	// Longest prefix wins
+	sort.SliceStable(p.routes, func(i, j int) bool {
+		return len(p.routes[i].prefix) > len(p.routes[j].prefix)
+	})
+
+	if p.anyOrigin && p.credentials {
+		return nil, ErrWildcardCredentials
+	}
+	return p, nil
+}
+
+// MustNew is New for options known to be valid. It panics if New fails.
+func MustNew(opts Options) *Policy {
+	p, err := New(opts)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// ParseOrigins splits a comma-separated origin list such as the CORS_ORIGINS
+// environment variable
+func ParseOrigins(s string) []string {
+	var origins []string
+	for _, origin := range strings.Split(s, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// Handler wraps next with the policy. Its signature matches
+// mux.MiddlewareFunc, so it can be passed to Router.Use directly.
+func (p *Policy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.Apply(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Apply writes CORS headers for r and reports whether the request was a
+// preflight that has been fully answered. Callers must not write anything
+// else when it returns true.
+func (p *Policy) Apply(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	header := w.Header()
+
+	if !isPreflight(r) {
+		// Unless every origin gets the same "*", the response depends on
+		// the Origin header, including when it is missing or disallowed.
+		// Caches must not serve one origin's response to another.
+		if !p.anyOrigin {
+			header.Add("Vary", "Origin")
+		}
+		if origin != "" && p.AllowsOrigin(origin) {
+			p.writeOrigin(header, origin)
+			if p.exposed != "" {
+				header.Set("Access-Control-Expose-Headers", p.exposed)
+			}
+		}
+		return false
+	}
+
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	rt := p.routeFor(r.URL.Path)
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requested := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+
+	if !p.AllowsOrigin(origin) || !contains(rt.methods, method) || !allowsHeaders(rt.headers, requested) {
+		if p.strict {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return true
+	}
+
+	p.writeOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(rt.methods, ", "))
+	header.Set("Access-Control-Allow-Headers", strings.Join(rt.headers, ", "))
+	if p.maxAge != "" {
+		header.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// AllowsOrigin reports whether origin matches the allowlist
+func (p *Policy) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if _, ok := p.origins[origin]; ok {
+		return true
+	}
+
+	if len(p.wildcards) == 0 {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, wc := range p.wildcards {
+		if u.Scheme == wc.scheme && strings.HasSuffix(u.Host, wc.suffix) && len(u.Host) > len(wc.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOrigin sends "*" when any origin is allowed and echoes the request
+// origin otherwise
+func (p *Policy) writeOrigin(header http.Header, origin string) {
+	if p.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *Policy) routeFor(path string) route {
+	for _, rt := range p.routes {
+		if strings.HasPrefix(path, rt.prefix) {
+			return rt
+		}
+	}
+	return route{methods: p.methods, headers: p.headers}
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+func normalizeMethods(methods, fallback []string) []string {
+	if len(methods) == 0 {
+		return fallback
+	}
+	result := make([]string, 0, len(methods))
+	for _, m := range methods {
+		result = append(result, strings.ToUpper(strings.TrimSpace(m)))
+	}
+	return result
+}
+
+func normalizeHeaders(headers, fallback []string) []string {
+	if len(headers) == 0 {
+		return fallback
+	}
+	result := make([]string, 0, len(headers))
+	for _, h := range headers {
+		result = append(result, http.CanonicalHeaderKey(strings.TrimSpace(h)))
+	}
+	return result
+}
+
+func parseHeaderList(s string) []string {
+	var headers []string
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, http.CanonicalHeaderKey(h))
+		}
+	}
+	return headers
+}
+
+func allowsHeaders(allowed, requested []string) bool {
+	for _, h := range requested {
+		if !contains(allowed, h) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
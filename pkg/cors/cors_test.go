@@ -0,0 +1,222 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func preflight(path, origin, method, headers string) *http.Request {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return req
+}
+
+func TestParseOrigins(t *testing.T) {
+	got := ParseOrigins(" http://localhost:3000, https://*.example.com ,,")
+	want := []string{"http://localhost:3000", "https://*.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestAllowsOrigin(t *testing.T) {
+	policy := MustNew(Options{AllowedOrigins: []string{"http://localhost:3000", "https://*.example.com"}})
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"http://localhost:3000", true},
+		{"HTTP://LOCALHOST:3000", true},
+		{"http://localhost:8080", false},
+		{"https://app.example.com", true},
+		{"https://a.b.example.com", true},
+		{"https://example.com", false},
+		{"http://app.example.com", false},
+		{"https://evilexample.com", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := policy.AllowsOrigin(tt.origin); got != tt.want {
+				t.Errorf("AllowsOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimpleRequestEchoesOrigin(t *testing.T) {
+	handler := MustNew(Options{
+		AllowedOrigins:   []string{"http://localhost:3000"},
+		AllowCredentials: true,
+		ExposedHeaders:   []string{"X-Request-ID"},
+	}).Handler(okHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Errorf("Expected echoed origin, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentials header, got %q", got)
+	}
+	if got := rr.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Expected Vary: Origin, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID" {
+		t.Errorf("Expected exposed headers, got %q", got)
+	}
+
+	// Disallowed origins are served without CORS headers
+	req.Header.Set("Origin", "http://evil.test")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no allow-origin header, got %q", got)
+	}
+}
+
+func TestVaryOrigin(t *testing.T) {
+	tests := []struct {
+		name     string
+		origins  []string
+		origin   string
+		wantVary bool
+	}{
+		{"allowed origin", []string{"http://localhost:3000"}, "http://localhost:3000", true},
+		{"disallowed origin", []string{"http://localhost:3000"}, "http://evil.test", true},
+		{"no origin", []string{"http://localhost:3000"}, "", true},
+		{"wildcard subdomain", []string{"https://*.example.com"}, "", true},
+		{"any origin", []string{"*"}, "http://somewhere.test", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := MustNew(Options{AllowedOrigins: tt.origins}).Handler(okHandler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Vary") == "Origin"; got != tt.wantVary {
+				t.Errorf("Expected Vary: Origin %v, got %q", tt.wantVary, rr.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestNewRejectsAnyOriginWithCredentials(t *testing.T) {
+	for _, origins := range [][]string{{"*"}, {"http://localhost:3000", " * "}} {
+		if _, err := New(Options{AllowedOrigins: origins, AllowCredentials: true}); !errors.Is(err, ErrWildcardCredentials) {
+			t.Errorf("Expected %v for %q with credentials, got %v", ErrWildcardCredentials, origins, err)
+		}
+	}
+	if _, err := New(Options{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}); err != nil {
+		t.Errorf("Expected wildcard subdomains with credentials to be accepted, got %v", err)
+	}
+}
+
+func TestAnyOriginSendsStar(t *testing.T) {
+	handler := MustNew(Options{AllowedOrigins: []string{"*"}}).Handler(okHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "http://somewhere.test")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected *, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no credentials header, got %q", got)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	handler := MustNew(Options{
+		AllowedOrigins: []string{"http://localhost:3000"},
+		MaxAge:         10 * time.Minute,
+		Routes: []Route{
+			{PathPrefix: "/health", Methods: []string{"get"}},
+			{PathPrefix: "/api/upload", Headers: []string{"content-type", "x-upload-id"}},
+		},
+	}).Handler(okHandler)
+
+	tests := []struct {
+		name        string
+		req         *http.Request
+		wantAllowed bool
+	}{
+		{"allowed", preflight("/api/v1/ping", "http://localhost:3000", "POST", "content-type"), true},
+		{"disallowed origin", preflight("/api/v1/ping", "http://evil.test", "POST", ""), false},
+		{"disallowed method", preflight("/api/v1/ping", "http://localhost:3000", "PATCH", ""), false},
+		{"disallowed header", preflight("/api/v1/ping", "http://localhost:3000", "GET", "X-Upload-ID"), false},
+		{"route method override", preflight("/health", "http://localhost:3000", "POST", ""), false},
+		{"route header override", preflight("/api/upload", "http://localhost:3000", "POST", "X-Upload-ID"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, tt.req)
+
+			if rr.Code != http.StatusNoContent {
+				t.Errorf("Expected status 204, got %d", rr.Code)
+			}
+			allowed := rr.Header().Get("Access-Control-Allow-Origin") != ""
+			if allowed != tt.wantAllowed {
+				t.Errorf("Expected allowed=%v, got headers %v", tt.wantAllowed, rr.Header())
+			}
+			if tt.wantAllowed && rr.Header().Get("Access-Control-Max-Age") != "600" {
+				t.Errorf("Expected Max-Age 600, got %q", rr.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestStrictPreflightReturnsForbidden(t *testing.T) {
+	handler := MustNew(Options{AllowedOrigins: []string{"http://localhost:3000"}, Strict: true}).Handler(okHandler)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, preflight("/", "http://evil.test", "GET", ""))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, preflight("/", "http://localhost:3000", "GET", ""))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rr.Code)
+	}
+}
+
+func TestPlainOptionsPassesThrough(t *testing.T) {
+	handler := MustNew(Options{AllowedOrigins: []string{"*"}}).Handler(okHandler)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected OPTIONS without preflight headers to reach handler, got %d", rr.Code)
+	}
+}
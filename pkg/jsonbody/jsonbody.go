@@ -0,0 +1,189 @@
+// Package jsonbody decodes JSON request bodies strictly and checks them
+// against their validate struct tags. It is shared by the lab services, so
+// they reject malformed input the same way.
+package jsonbody
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxBytes is the body size limit of Decode
+const DefaultMaxBytes = 1 << 20
+
+// FieldError describes why one field of a request was rejected
+type FieldError struct {
+	// Field is the JSON path of the field, such as "name" or "tags[1]"
+	Field string `json:"field"`
+	// Rule is the validate tag or check that failed, such as "required"
+	Rule string `json:"rule"`
+	// Message is a human-readable explanation
+	Message string `json:"message"`
+}
+
+// Error is returned by Decode and Validate. Status is the HTTP status to
+// respond with: 415 for a wrong Content-Type, 413 for an oversized body,
+// 400 for malformed JSON and 422 when fields are unknown, have the wrong
+// type or fail validation, in which case Fields lists them. A malformed validate tag is
+// reported with 500.
+type Error struct {
+	Status  int
+	Message string
+	Fields  []FieldError
+}
+
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + " " + f.Message
+	}
+	return e.Message + ": " + strings.Join(messages, "; ")
+}
+
+// Decode reads a JSON body of at most DefaultMaxBytes into dst and
+// validates it. See DecodeLimit.
+func Decode(w http.ResponseWriter, r *http.Request, dst any) error {
+	return DecodeLimit(w, r, dst, DefaultMaxBytes)
+}
+
+// DecodeLimit reads a JSON body of at most maxBytes into dst, which must be
+// a pointer. The request must be sent as application/json (or a +json
+// type), hold exactly one JSON value and no fields unknown to dst. When dst
+// points to a struct, its validate tags are checked afterwards. Every
+// failure is an *Error.
+func DecodeLimit(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	if err := checkContentType(r.Header.Get("Content-Type")); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return decodeError(err)
+		}
+		return &Error{Status: http.StatusBadRequest, Message: "request body must contain a single JSON value"}
+	}
+	return Validate(dst)
+}
+
+// checkContentType accepts application/json and structured types such as
+// application/merge-patch+json, with any parameters
+func checkContentType(value string) error {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+	return &Error{Status: http.StatusUnsupportedMediaType, Message: "Content-Type must be application/json"}
+}
+
+// decodeError turns an error of json.Decoder into an *Error
+func decodeError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		tooLarge  *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		return &Error{
+			Status:  http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit),
+		}
+	case errors.Is(err, io.EOF):
+		return &Error{Status: http.StatusBadRequest, Message: "request body must not be empty"}
+	case errors.As(err, &syntaxErr):
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("malformed JSON at position %d", syntaxErr.Offset),
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &Error{Status: http.StatusBadRequest, Message: "malformed JSON: unexpected end of body"}
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return &Error{
+				Status:  http.StatusBadRequest,
+				Message: "request body must be " + jsonType(typeErr.Type),
+			}
+		}
+		return &Error{
+			Status:  http.StatusUnprocessableEntity,
+			Message: "validation failed",
+			Fields: []FieldError{{
+				Field:   typeErr.Field,
+				Rule:    "type",
+				Message: "must be " + jsonType(typeErr.Type),
+			}},
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields; the name is
+		// quoted in the message
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		if name, err := strconv.Unquote(field); err == nil {
+			field = name
+		}
+		return &Error{
+			Status:  http.StatusUnprocessableEntity,
+			Message: "validation failed",
+			Fields: []FieldError{{
+				Field:   field,
+				Rule:    "unknown",
+				Message: "is not a known field",
+			}},
+		}
+	}
+	return &Error{Status: http.StatusBadRequest, Message: "invalid request body"}
+}
+
+// jsonType names the JSON type a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	}
+	return "an object"
+}
+
+// WriteError responds with err as {"error": ..., "fields": [...]}, using
+// its status if it is an *Error and 400 otherwise. Services with their own
+// response envelope use the fields of Error instead.
+func WriteError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	var bodyErr *Error
+	if errors.As(err, &bodyErr) {
+		status = bodyErr.Status
+	} else {
+		bodyErr = &Error{Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields,omitempty"`
+	}{bodyErr.Message, bodyErr.Fields})
+}
@@ -0,0 +1,227 @@
+package jsonbody
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type category struct {
+	Name  string   `json:"name" validate:"required,min=2,max=10"`
+	Color string   `json:"color" validate:"omitempty,hexcolor"`
+	Tags  []string `json:"tags" validate:"max=2,dive,required"`
+	Count int      `json:"count" validate:"gte=0"`
+}
+
+func request(body, contentType string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req
+}
+
+func TestDecode(t *testing.T) {
+	var dst category
+	err := Decode(httptest.NewRecorder(), request(`{"name":"Go","tags":["a"]}`, "application/json; charset=utf-8"), &dst)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if dst.Name != "Go" || !reflect.DeepEqual(dst.Tags, []string{"a"}) {
+		t.Errorf("Unexpected result %+v", dst)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		maxBytes    int64
+		wantStatus  int
+		wantFields  []string
+	}{
+		{"missing content type", `{"name":"Go"}`, "", 0, http.StatusUnsupportedMediaType, nil},
+		{"wrong content type", `{"name":"Go"}`, "text/plain", 0, http.StatusUnsupportedMediaType, nil},
+		{"too large", `{"name":"` + strings.Repeat("a", 100) + `"}`, "application/json", 32, http.StatusRequestEntityTooLarge, nil},
+		{"empty", ``, "application/json", 0, http.StatusBadRequest, nil},
+		{"malformed", `{"name":`, "application/json", 0, http.StatusBadRequest, nil},
+		{"syntax", `{"name" "Go"}`, "application/json", 0, http.StatusBadRequest, nil},
+		{"unknown field", `{"name":"Go","owner":"me"}`, "application/json", 0, http.StatusUnprocessableEntity, []string{"owner"}},
+		{"trailing data", `{"name":"Go"} {}`, "application/json", 0, http.StatusBadRequest, nil},
+		{"not an object", `["Go"]`, "application/json", 0, http.StatusBadRequest, nil},
+		{"wrong type", `{"name":"Go","count":"many"}`, "application/json", 0, http.StatusUnprocessableEntity, []string{"count"}},
+		{"invalid fields", `{"color":"red","tags":["a",""],"count":-1}`, "application/json", 0, http.StatusUnprocessableEntity, []string{"name", "color", "tags[1]", "count"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxBytes := tt.maxBytes
+			if maxBytes == 0 {
+				maxBytes = DefaultMaxBytes
+			}
+			var dst category
+			err := DecodeLimit(httptest.NewRecorder(), request(tt.body, tt.contentType), &dst, maxBytes)
+
+			var bodyErr *Error
+			if !errors.As(err, &bodyErr) {
+				t.Fatalf("Expected an *Error, got %v", err)
+			}
+			if bodyErr.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d (%v)", tt.wantStatus, bodyErr.Status, err)
+			}
+			var fields []string
+			for _, f := range bodyErr.Fields {
+				fields = append(fields, f.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("Expected field errors for %v, got %+v", tt.wantFields, bodyErr.Fields)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(&category{Name: "Go", Color: "#00ADD8"}); err != nil {
+		t.Errorf("Expected a valid struct, got %v", err)
+	}
+
+	err := Validate(category{Name: "G"})
+	var bodyErr *Error
+	if !errors.As(err, &bodyErr) || len(bodyErr.Fields) != 1 {
+		t.Fatalf("Expected one field error, got %v", err)
+	}
+	want := FieldError{Field: "name", Rule: "min", Message: "must be at least 2 characters long"}
+	if bodyErr.Fields[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, bodyErr.Fields[0])
+	}
+
+	var m map[string]any
+	if err := Validate(&m); err != nil {
+		t.Errorf("Expected values other than structs to pass, got %v", err)
+	}
+}
+
+type author struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type post struct {
+	Title     string            `json:"title" validate:"required,len=5"`
+	Status    string            `json:"status" validate:"oneof=draft published"`
+	Link      *string           `json:"link,omitempty" validate:"omitempty,http_url"`
+	Content   string            `json:"content" validate:"required_if=Published true"`
+	Published bool              `json:"published"`
+	Author    author            `json:"author"`
+	Editor    *author           `json:"editor,omitempty"`
+	Scores    map[string]int    `json:"scores" validate:"dive,lte=10"`
+	Extra     map[string]string `json:"-"`
+}
+
+func TestValidateRules(t *testing.T) {
+	link := "https://example.com"
+	badLink := "example.com"
+	valid := post{Title: "Hello", Status: "draft", Link: &link, Author: author{Email: "go@example.com"}}
+
+	tests := []struct {
+		name       string
+		change     func(p *post)
+		wantFields []string
+		wantRules  []string
+	}{
+		{"valid", func(p *post) {}, nil, nil},
+		{"len", func(p *post) { p.Title = "Hi" }, []string{"title"}, []string{"len"}},
+		{"oneof", func(p *post) { p.Status = "gone" }, []string{"status"}, []string{"oneof"}},
+		{"http_url", func(p *post) { p.Link = &badLink }, []string{"link"}, []string{"http_url"}},
+		{"required_if", func(p *post) { p.Published = true }, []string{"content"}, []string{"required_if"}},
+		{"required_if met", func(p *post) { p.Published, p.Content = true, "text" }, nil, nil},
+		{"nested struct", func(p *post) { p.Author.Email = "nobody" }, []string{"author.email"}, []string{"email"}},
+		{"nested pointer", func(p *post) { p.Editor = &author{} }, []string{"editor.email"}, []string{"required"}},
+		{"dive into map", func(p *post) { p.Scores = map[string]int{"b": 11, "a": 12} }, []string{"scores[a]", "scores[b]"}, []string{"lte", "lte"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.change(&p)
+			err := Validate(&p)
+
+			var fields, rules []string
+			var bodyErr *Error
+			if errors.As(err, &bodyErr) {
+				if bodyErr.Status != http.StatusUnprocessableEntity {
+					t.Errorf("Expected status 422, got %d", bodyErr.Status)
+				}
+				for _, f := range bodyErr.Fields {
+					fields = append(fields, f.Field)
+					rules = append(rules, f.Rule)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected an *Error, got %v", err)
+			}
+			// Map elements are reported in no particular order
+			sort.Strings(fields)
+			sort.Strings(rules)
+			if !reflect.DeepEqual(fields, tt.wantFields) || !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("Expected %v failing %v, got %+v", tt.wantFields, tt.wantRules, bodyErr)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidTag(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"unknown rule", struct {
+			Name string `validate:"required,slug"`
+		}{"x"}},
+		{"rule for another kind", struct {
+			Done bool `validate:"min=1"`
+		}{true}},
+		{"bad parameter", struct {
+			Name string `validate:"max=ten"`
+		}{"x"}},
+		{"dive into a string", struct {
+			Name string `validate:"dive,required"`
+		}{"x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodyErr *Error
+			err := Validate(tt.v)
+			if !errors.As(err, &bodyErr) {
+				t.Fatalf("Expected an *Error, got %v", err)
+			}
+			if bodyErr.Status != http.StatusInternalServerError {
+				t.Errorf("Expected status 500, got %d (%v)", bodyErr.Status, err)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	WriteError(rr, Validate(category{}))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", rr.Code)
+	}
+	var body struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if body.Error != "validation failed" || len(body.Fields) != 1 || body.Fields[0].Field != "name" {
+		t.Errorf("Unexpected response %+v", body)
+	}
+}
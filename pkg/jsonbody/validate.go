@@ -0,0 +1,111 @@
+package jsonbody
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validate is shared because it caches the parsed tags of every struct type
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(jsonName)
+	return v
+}
+
+// Validate checks the validate tags of the struct v points to with
+// github.com/go-playground/validator and returns an *Error with status 422
+// listing every failing field, or nil. Values other than structs have
+// nothing to check. A tag the validator does not understand is a bug in the
+// caller and yields an *Error with status 500.
+//
+// Fields are reported by their JSON names, such as "tags[1]" or
+// "author.email". Elements of maps are reported in no particular order.
+func Validate(v any) (err error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	// The validator panics on tags it cannot parse or apply
+	defer func() {
+		if r := recover(); r != nil {
+			err = &Error{Status: http.StatusInternalServerError, Message: fmt.Sprint("invalid validate tag: ", r)}
+		}
+	}()
+
+	var failed validator.ValidationErrors
+	if err := validate.Struct(value.Interface()); !errors.As(err, &failed) {
+		if err != nil {
+			return &Error{Status: http.StatusInternalServerError, Message: "invalid validate tag: " + err.Error()}
+		}
+		return nil
+	}
+	fields := make([]FieldError, len(failed))
+	for i, fe := range failed {
+		fields[i] = FieldError{Field: fieldPath(fe.Namespace()), Rule: fe.Tag(), Message: message(fe)}
+	}
+	return &Error{Status: http.StatusUnprocessableEntity, Message: "validation failed", Fields: fields}
+}
+
+// fieldPath drops the struct type name the validator starts namespaces with
+func fieldPath(namespace string) string {
+	_, path, found := strings.Cut(namespace, ".")
+	if !found {
+		return namespace
+	}
+	return path
+}
+
+// jsonName is the name a struct field has in JSON
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// message explains a failed rule in words
+func message(fe validator.FieldError) string {
+	param := fe.Param()
+	switch fe.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "min", "max", "len":
+		bound := map[string]string{"min": "at least", "max": "at most", "len": "exactly"}[fe.Tag()]
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", bound, param)
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("must contain %s %s items", bound, param)
+		}
+		return fmt.Sprintf("must be %s %s", bound, param)
+	case "gt":
+		return "must be greater than " + param
+	case "gte":
+		return "must be greater than or equal to " + param
+	case "lt":
+		return "must be less than " + param
+	case "lte":
+		return "must be less than or equal to " + param
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "email":
+		return "must be a valid email address"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "hexcolor":
+		return "must be a hex color such as #1e90ff"
+	}
+	return "failed the " + fe.Tag() + " check"
+}